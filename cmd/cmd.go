@@ -15,9 +15,18 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
 	"github.com/palantir/godel/framework/pluginapi"
 	"github.com/palantir/pkg/cobracli"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/palantir/go-novendor/novendor"
 )
@@ -26,24 +35,135 @@ var (
 	rootCmd = &cobra.Command{
 		Use:   "novendor [flags] [packages]",
 		Short: "verifies that all vendored packages are referenced in the project",
+		// packages are provided as arguments, so arguments that are not subcommands must not be rejected
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			config := novendor.Config{
-				PkgRegexps:                pkgRegexpsFlagVal,
-				IncludeVendorInImportPath: includeVendorImportPathFlagVal,
-				IgnorePkgs:                ignorePkgsFlagVal,
+			param, err := loadParam(cmd)
+			if err != nil {
+				return err
+			}
+			if whyFlagVal != "" {
+				return novendor.RunWhy(projectDirFlagVal, args, whyFlagVal, param, cmd.OutOrStdout())
 			}
-			param, err := config.ToParam()
+			if checkFlagVal && param.Format == novendor.FormatGitHubActions {
+				// unused packages are reported as error annotations and through the exit status
+				if param.MaxUnused == nil {
+					maxUnused := 0
+					param.MaxUnused = &maxUnused
+				}
+				ctx, cancel := interruptContext()
+				defer cancel()
+				if err := novendor.RunWithWritersContext(ctx, projectDirFlagVal, args, param, cmd.OutOrStdout(), cmd.OutOrStderr()); err != nil {
+					if errors.Cause(err) == novendor.ErrUnusedPkgs {
+						return errors.New("")
+					}
+					return err
+				}
+				return nil
+			}
+			if checkFlagVal {
+				// errors that occur during the analysis are still printed, but unused packages are only reported
+				// through the exit status
+				if err := novendor.Check(projectDirFlagVal, args, param); err != nil {
+					if errors.Cause(err) == novendor.ErrUnusedPkgs {
+						return errors.New("")
+					}
+					return err
+				}
+				return nil
+			}
+			ctx, cancel := interruptContext()
+			defer cancel()
+			return novendor.RunWithWritersContext(ctx, projectDirFlagVal, args, param, cmd.OutOrStdout(), cmd.OutOrStderr())
+		},
+	}
+
+	pruneCmd = &cobra.Command{
+		Use:   "prune [flags] [packages]",
+		Short: "removes the unused vendored packages from the vendor directories",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			param, err := loadParam(cmd)
 			if err != nil {
 				return err
 			}
-			return novendor.Run(projectDirFlagVal, args, param, cmd.OutOrStdout())
+			return novendor.RunPrune(projectDirFlagVal, args, param, dryRunFlagVal, cmd.OutOrStdout())
 		},
 	}
 
 	projectDirFlagVal              string
+	configFlagVal                  string
 	pkgRegexpsFlagVal              []string
 	includeVendorImportPathFlagVal bool
 	ignorePkgsFlagVal              []string
+	includeTestImportsFlagVal      bool
+	showImportersFlagVal           bool
+	summaryFlagVal                 bool
+	reportEmptyFlagVal             bool
+	checkImportCommentsFlagVal     bool
+	vendorDirNameFlagVal           string
+	warningsFlagVal                bool
+	relativeFlagVal                bool
+	checkStdlibShadowFlagVal       bool
+	maxDepthFlagVal                int
+	groupByRepoFlagVal             bool
+	dotFlagVal                     bool
+	warnVendoredMainFlagVal        bool
+	progressFlagVal                bool
+	verboseFlagVal                 bool
+	explainIgnoresFlagVal          bool
+	followSymlinksFlagVal          bool
+	allowUnusedFlagVal             []string
+	onlyBuildIgnoredFlagVal        bool
+	skipDirsFlagVal                []string
+	cgoEnabledFlagVal              bool
+	dedupeFlagVal                  bool
+	buildTagsFlagVal               []string
+	collapseInternalFlagVal        bool
+	platformsFlagVal               []string
+	absPathFlagVal                 bool
+	ignorePrefixesFlagVal          []string
+	checkVersionMismatchFlagVal    bool
+	jsonlFlagVal                   bool
+	githubActionsFlagVal           bool
+	allowNestedVendorFlagVal       bool
+	statsFlagVal                   bool
+	trackStdlibFlagVal             bool
+	additionalPkgRegexpsFlagVal    []string
+	reportBlankOnlyFlagVal         bool
+	checkFlagVal                   bool
+	gopathFlagVal                  string
+	retainWithFilesFlagVal         []string
+	showUsedFlagVal                bool
+	timingFlagVal                  bool
+	maxOpenFilesFlagVal            int
+	reportUnbuildableFlagVal       bool
+	sortByVendorDirFlagVal         bool
+	vendorHostsFlagVal             []string
+	reportNotVendoredFlagVal       bool
+	maxUnusedFlagVal               int
+	whyFlagVal                     string
+	nullFlagVal                    bool
+	strictReachabilityFlagVal      bool
+	projectImportPathFlagVal       string
+	cacheDirFlagVal                string
+	showNameFlagVal                bool
+	modFlagVal                     string
+	strictSubpackagesFlagVal       bool
+	directOnlyFlagVal              bool
+	requireVendorFlagVal           bool
+	reportShadowedFlagVal          bool
+	quietFlagVal                   bool
+	reportVendoredTestDepsFlagVal  bool
+	continueOnWalkErrorFlagVal     bool
+	headerTemplateFlagVal          string
+	footerTemplateFlagVal          string
+	checkDirNamesFlagVal           bool
+	failOnMissingVendoringFlagVal  bool
+	goVersionFlagVal               string
+	reportRedundantIgnoresFlagVal  bool
+	reportStaleIgnoresFlagVal      bool
+	caseInsensitiveFlagVal         bool
+	dryRunFlagVal                  bool
 
 	defaultPkgRegexps = []string{
 		`github\.com/[^/]+/[^/]+`,
@@ -53,13 +173,394 @@ var (
 	}
 )
 
+// noVendorDirExitCode is the exit code used when the analysis fails because a vendor directory does not exist. Allows
+// a project without vendor directories to be distinguished from a failed analysis or a project with unused packages.
+const noVendorDirExitCode = 2
+
 func Execute() int {
-	return cobracli.ExecuteWithDefaultParams(rootCmd)
+	return cobracli.ExecuteWithDefaultParams(rootCmd, cobracli.ExitCodeExtractorParam(func(err error) int {
+		if errors.Cause(err) == novendor.ErrNoVendorDir {
+			return noVendorDirExitCode
+		}
+		return 1
+	}))
+}
+
+// rootOnlyFlags are the flags of the root command that select what the root command does with the result of the
+// analysis. All other flags of the root command configure the analysis and are also flags of the prune command.
+var rootOnlyFlags = map[string]struct{}{
+	"check":          {},
+	"why":            {},
+	"dot":            {},
+	"jsonl":          {},
+	"github-actions": {},
+	"quiet":          {},
+	"max-unused":     {},
+}
+
+// interruptContext returns a context that is cancelled when the process receives an interrupt signal, so that the
+// analysis stops promptly rather than running to completion. The returned function must be called to release the
+// resources associated with the context.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	go func() {
+		select {
+		case <-sigCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigCh)
+		cancel()
+	}
+}
+
+// loadParam returns the parameters specified by the configuration and flags of the provided command.
+func loadParam(cmd *cobra.Command) (novendor.Param, error) {
+	config, err := loadConfig(cmd.Flags())
+	if err != nil {
+		return novendor.Param{}, err
+	}
+	if err := config.Validate(); err != nil {
+		return novendor.Param{}, err
+	}
+	param, err := config.ToParam()
+	if err != nil {
+		return novendor.Param{}, err
+	}
+	// packages in the ignore file are ignored in addition to the packages specified by the configuration and flags
+	ignoreFilePkgs, err := novendor.LoadIgnoreFile(projectDirFlagVal)
+	if err != nil {
+		return novendor.Param{}, err
+	}
+	param.IgnorePkgs = append(param.IgnorePkgs, ignoreFilePkgs...)
+	if verboseFlagVal {
+		param.Logger = log.New(cmd.OutOrStderr(), "", 0)
+	}
+	if progressFlagVal {
+		param.ProgressFn = func(examined, total int) {
+			fmt.Fprintf(cmd.OutOrStderr(), "processed %d/%d vendor directories\n", examined, total)
+		}
+	}
+	if timingFlagVal {
+		param.MetricsFn = func(phase string, d time.Duration) {
+			fmt.Fprintf(cmd.OutOrStderr(), "timing: %s: %v\n", phase, d)
+		}
+	}
+	return param, nil
+}
+
+// loadConfig returns the configuration specified by the flags. If a configuration file was specified, its values are
+// used as the base configuration and the values of any flags that were explicitly set override them.
+func loadConfig(flags *pflag.FlagSet) (novendor.Config, error) {
+	var config novendor.Config
+	if configFlagVal != "" {
+		loadedConfig, err := config.LoadFromFile(configFlagVal)
+		if err != nil {
+			return novendor.Config{}, err
+		}
+		config = loadedConfig
+	}
+	// environment variables take precedence over the configuration file but not over flags
+	envConfig, err := config.WithEnv(os.LookupEnv)
+	if err != nil {
+		return novendor.Config{}, err
+	}
+	config = envConfig
+
+	if flags.Changed("pkg-regexp") || config.PkgRegexps == nil {
+		config.PkgRegexps = pkgRegexpsFlagVal
+	}
+	if flags.Changed("additional-pkg-regexp") {
+		config.AdditionalPkgRegexps = additionalPkgRegexpsFlagVal
+	}
+	if flags.Changed("full-import-path") {
+		config.IncludeVendorInImportPath = includeVendorImportPathFlagVal
+	}
+	if flags.Changed("ignore-pkg") {
+		config.IgnorePkgs = ignorePkgsFlagVal
+	}
+	if flags.Changed("include-test-imports") || config.IncludeTestImports == nil {
+		config.IncludeTestImports = &includeTestImportsFlagVal
+	}
+	if flags.Changed("show-importers") {
+		config.ShowImporters = showImportersFlagVal
+	}
+	if flags.Changed("summary") {
+		config.Summary = summaryFlagVal
+	}
+	if flags.Changed("report-empty") {
+		config.ReportEmpty = reportEmptyFlagVal
+	}
+	if flags.Changed("check-import-comments") {
+		config.CheckImportComments = checkImportCommentsFlagVal
+	}
+	if flags.Changed("vendor-dir-name") {
+		config.VendorDirName = vendorDirNameFlagVal
+	}
+	if flags.Changed("warnings") {
+		config.ReportWarnings = warningsFlagVal
+	}
+	if flags.Changed("relative") {
+		config.RelativePaths = relativeFlagVal
+	}
+	if flags.Changed("check-stdlib-shadow") {
+		config.CheckStdlibShadow = checkStdlibShadowFlagVal
+	}
+	if flags.Changed("max-depth") {
+		config.MaxDepth = maxDepthFlagVal
+	}
+	if flags.Changed("group-by-repo") {
+		config.GroupByRepo = groupByRepoFlagVal
+	}
+	if flags.Changed("warn-vendored-main") {
+		config.WarnVendoredMain = warnVendoredMainFlagVal
+	}
+	if flags.Changed("explain-ignores") {
+		config.ExplainIgnores = explainIgnoresFlagVal
+	}
+	if flags.Changed("follow-symlinks") {
+		config.FollowSymlinks = followSymlinksFlagVal
+	}
+	if flags.Changed("allow-unused") {
+		config.AllowUnused = allowUnusedFlagVal
+	}
+	if flags.Changed("only-build-ignored") {
+		config.OnlyBuildIgnored = onlyBuildIgnoredFlagVal
+	}
+	if flags.Changed("skip-dir") {
+		config.SkipDirs = skipDirsFlagVal
+	}
+	if flags.Changed("cgo-enabled") {
+		config.CgoEnabled = &cgoEnabledFlagVal
+	}
+	if flags.Changed("dedupe") {
+		config.Dedupe = dedupeFlagVal
+	}
+	if flags.Changed("tags") {
+		config.BuildTags = buildTagsFlagVal
+	}
+	if flags.Changed("collapse-internal") {
+		config.CollapseInternal = collapseInternalFlagVal
+	}
+	if flags.Changed("platform") {
+		config.Platforms = platformsFlagVal
+	}
+	if flags.Changed("abs-path") {
+		config.AbsPaths = absPathFlagVal
+	}
+	if flags.Changed("ignore-prefix") {
+		config.IgnorePrefixes = ignorePrefixesFlagVal
+	}
+	if flags.Changed("check-version-mismatch") {
+		config.CheckVersionMismatch = checkVersionMismatchFlagVal
+	}
+	if flags.Changed("allow-nested-vendor") {
+		config.AllowNestedVendor = allowNestedVendorFlagVal
+	}
+	if flags.Changed("stats") {
+		config.Stats = statsFlagVal
+	}
+	if flags.Changed("track-stdlib") {
+		config.TrackStdlib = trackStdlibFlagVal
+	}
+	if flags.Changed("report-blank-only") {
+		config.ReportBlankOnly = reportBlankOnlyFlagVal
+	}
+	if flags.Changed("gopath") {
+		config.GOPATH = gopathFlagVal
+	}
+	if flags.Changed("retain-with-file") {
+		config.RetainWithFiles = retainWithFilesFlagVal
+	}
+	if flags.Changed("show-used") {
+		config.ShowUsed = showUsedFlagVal
+	}
+	if flags.Changed("max-open-files") {
+		config.MaxOpenFiles = maxOpenFilesFlagVal
+	}
+	if flags.Changed("report-unbuildable") {
+		config.ReportUnbuildable = reportUnbuildableFlagVal
+	}
+	if flags.Changed("sort-by-vendor-dir") {
+		config.SortByVendorDir = sortByVendorDirFlagVal
+	}
+	if flags.Changed("vendor-host") {
+		config.VendorHosts = vendorHostsFlagVal
+	}
+	if flags.Changed("report-not-vendored") {
+		config.ReportNotVendored = reportNotVendoredFlagVal
+	}
+	if flags.Changed("max-unused") {
+		config.MaxUnused = &maxUnusedFlagVal
+	}
+	if flags.Changed("null") && nullFlagVal {
+		config.RecordSeparator = "\x00"
+	}
+	if flags.Changed("strict-reachability") {
+		config.StrictReachability = strictReachabilityFlagVal
+	}
+	if flags.Changed("project-import-path") {
+		config.ProjectImportPath = projectImportPathFlagVal
+	}
+	if flags.Changed("cache-dir") {
+		config.CacheDir = cacheDirFlagVal
+	}
+	if flags.Changed("show-name") {
+		config.ShowName = showNameFlagVal
+	}
+	if flags.Changed("mod") {
+		config.ModMode = novendor.ModMode(modFlagVal)
+	}
+	if flags.Changed("strict-subpackages") {
+		config.StrictSubpackages = strictSubpackagesFlagVal
+	}
+	if flags.Changed("direct-only") {
+		config.DirectOnly = directOnlyFlagVal
+	}
+	if flags.Changed("require-vendor") {
+		config.RequireVendor = requireVendorFlagVal
+	}
+	if flags.Changed("report-shadowed") {
+		config.ReportShadowed = reportShadowedFlagVal
+	}
+	if flags.Changed("quiet") {
+		config.Quiet = quietFlagVal
+	}
+	if flags.Changed("report-vendored-test-deps") {
+		config.ReportVendoredTestDeps = reportVendoredTestDepsFlagVal
+	}
+	if flags.Changed("continue-on-walk-error") {
+		config.ContinueOnWalkError = continueOnWalkErrorFlagVal
+	}
+	if flags.Changed("header-template") {
+		config.HeaderTemplate = headerTemplateFlagVal
+	}
+	if flags.Changed("footer-template") {
+		config.FooterTemplate = footerTemplateFlagVal
+	}
+	if flags.Changed("check-dir-names") {
+		config.CheckDirNames = checkDirNamesFlagVal
+	}
+	if flags.Changed("fail-on-missing-vendoring") {
+		config.FailOnMissingVendoring = failOnMissingVendoringFlagVal
+	}
+	if flags.Changed("go-version") {
+		config.GoVersion = goVersionFlagVal
+	}
+	if flags.Changed("report-redundant-ignores") {
+		config.ReportRedundantIgnores = reportRedundantIgnoresFlagVal
+	}
+	if flags.Changed("report-stale-ignores") {
+		config.ReportStaleIgnores = reportStaleIgnoresFlagVal
+	}
+	if flags.Changed("case-insensitive") {
+		config.CaseInsensitive = &caseInsensitiveFlagVal
+	}
+	if flags.Changed("dot") {
+		config.Format = novendor.FormatText
+		if dotFlagVal {
+			config.Format = novendor.FormatDOT
+		}
+	}
+	if flags.Changed("jsonl") {
+		config.Format = novendor.FormatText
+		if jsonlFlagVal {
+			config.Format = novendor.FormatJSONL
+		}
+	}
+	if flags.Changed("github-actions") {
+		config.Format = novendor.FormatText
+		if githubActionsFlagVal {
+			config.Format = novendor.FormatGitHubActions
+		}
+	}
+	return config, nil
 }
 
 func init() {
 	pluginapi.AddProjectDirPFlagPtr(rootCmd.Flags(), &projectDirFlagVal)
-	rootCmd.Flags().StringArrayVar(&pkgRegexpsFlagVal, "pkg-regexp", defaultPkgRegexps, "regular expressions used to group packages")
+	rootCmd.Flags().StringVar(&configFlagVal, "config", "", "path to a YAML or JSON configuration file")
+	rootCmd.Flags().StringArrayVar(&pkgRegexpsFlagVal, "pkg-regexp", defaultPkgRegexps, "regular expressions used to group packages (replaces the default expressions)")
+	rootCmd.Flags().StringArrayVar(&additionalPkgRegexpsFlagVal, "additional-pkg-regexp", nil, "regular expressions used to group packages in addition to the expressions specified by --pkg-regexp")
 	rootCmd.Flags().BoolVar(&includeVendorImportPathFlagVal, "full-import-path", false, "print the full import path (including the vendor directory) for unused packages")
 	rootCmd.Flags().StringSliceVar(&ignorePkgsFlagVal, "ignore-pkg", nil, "packages that should be ignored (suppressed from output)")
+	rootCmd.Flags().BoolVar(&includeTestImportsFlagVal, "include-test-imports", true, "consider imports in test files of the project packages")
+	rootCmd.Flags().BoolVar(&showImportersFlagVal, "show-importers", false, "print the project packages that import each used vendored package")
+	rootCmd.Flags().BoolVar(&summaryFlagVal, "summary", false, "print a summary line with the number of unused packages")
+	rootCmd.Flags().BoolVar(&reportEmptyFlagVal, "report-empty", false, "report vendored directories that do not contain any Go files")
+	rootCmd.Flags().BoolVar(&checkImportCommentsFlagVal, "check-import-comments", false, "warn about vendored packages whose import comments do not match their vendored import paths")
+	rootCmd.Flags().StringVar(&vendorDirNameFlagVal, "vendor-dir-name", "vendor", "name of vendor directories")
+	rootCmd.Flags().BoolVar(&warningsFlagVal, "warnings", false, "print warnings for directories that could not be parsed to stderr")
+	rootCmd.Flags().BoolVar(&relativeFlagVal, "relative", false, "when used with --full-import-path, print the paths of unused packages relative to the project directory")
+	rootCmd.Flags().BoolVar(&checkStdlibShadowFlagVal, "check-stdlib-shadow", false, "warn about vendored packages that shadow standard library packages")
+	rootCmd.Flags().IntVar(&maxDepthFlagVal, "max-depth", 0, "maximum depth of the import graph to traverse (0 for unlimited); limiting the depth may cause used packages to be reported as unused")
+	rootCmd.Flags().BoolVar(&groupByRepoFlagVal, "group-by-repo", false, "print one line per repository (as determined by --pkg-regexp) with the number of unused packages in it")
+	rootCmd.Flags().BoolVar(&warnVendoredMainFlagVal, "warn-vendored-main", false, "warn about vendored main packages instead of reporting them as unused")
+	rootCmd.Flags().BoolVar(&explainIgnoresFlagVal, "explain-ignores", false, "print the vendored packages that are used only by the packages specified by --ignore-pkg")
+	rootCmd.Flags().BoolVar(&followSymlinksFlagVal, "follow-symlinks", false, "follow symbolic links to directories in vendor directories")
+	rootCmd.Flags().StringSliceVar(&allowUnusedFlagVal, "allow-unused", nil, "import paths of vendored packages that are allowed to be unused (suppressed from output without affecting the packages that are considered used)")
+	rootCmd.Flags().BoolVar(&onlyBuildIgnoredFlagVal, "only-build-ignored", false, "print the vendored packages that are used only by files excluded from the build by build constraints")
+	rootCmd.Flags().StringSliceVar(&skipDirsFlagVal, "skip-dir", nil, `base names of directories in vendor directories that should be skipped (default "testdata" and ".git")`)
+	rootCmd.Flags().BoolVar(&cgoEnabledFlagVal, "cgo-enabled", false, "whether cgo is enabled for the analysis (default determined by the CGO_ENABLED environment variable)")
+	rootCmd.Flags().BoolVar(&dedupeFlagVal, "dedupe", false, "print import paths that are unused in multiple vendor directories only once (has no effect with --full-import-path)")
+	rootCmd.Flags().StringSliceVar(&buildTagsFlagVal, "tags", nil, "build tags that are set when build constraints are evaluated")
+	rootCmd.Flags().BoolVar(&collapseInternalFlagVal, "collapse-internal", false, "do not print unused internal packages whose enclosing package is also unused")
+	rootCmd.Flags().StringSliceVar(&platformsFlagVal, "platform", nil, "platforms (in the form GOOS/GOARCH) on which vendored packages must be used to be considered used (default considers all files regardless of build constraints)")
+	rootCmd.Flags().BoolVar(&absPathFlagVal, "abs-path", false, "print the absolute paths of the directories of unused packages")
+	rootCmd.Flags().StringSliceVar(&ignorePrefixesFlagVal, "ignore-prefix", nil, "import path prefixes of unused packages that should be suppressed from output")
+	rootCmd.Flags().BoolVar(&checkVersionMismatchFlagVal, "check-version-mismatch", false, "warn about packages that are vendored with different contents in multiple vendor directories")
+	rootCmd.Flags().BoolVar(&jsonlFlagVal, "jsonl", false, "print each unused package as a JSON object on its own line")
+	rootCmd.Flags().BoolVar(&githubActionsFlagVal, "github-actions", false, "print each unused package as a GitHub Actions annotation (errors if --check is specified, warnings otherwise)")
+	rootCmd.Flags().BoolVar(&allowNestedVendorFlagVal, "allow-nested-vendor", false, "analyze vendor directories that are nested within other analyzed vendor directories separately")
+	rootCmd.Flags().BoolVar(&statsFlagVal, "stats", false, "print the number of vendored and unused packages in each vendor directory")
+	rootCmd.Flags().BoolVar(&trackStdlibFlagVal, "track-stdlib", false, "print the standard library packages that are imported by the project")
+	rootCmd.Flags().BoolVar(&reportBlankOnlyFlagVal, "report-blank-only", false, "print the vendored packages that are only imported using blank imports")
+	rootCmd.Flags().BoolVar(&checkFlagVal, "check", false, "print nothing and exit with a non-zero status if there are unused vendored packages")
+	rootCmd.Flags().StringVar(&gopathFlagVal, "gopath", "", "GOPATH used to resolve imports (if empty, the GOPATH environment variable is used)")
+	rootCmd.Flags().StringSliceVar(&retainWithFilesFlagVal, "retain-with-file", nil, "names of files (such as LICENSE) that cause vendored packages whose directories contain them to never be reported as unused")
+	rootCmd.Flags().BoolVar(&showUsedFlagVal, "show-used", false, "print the vendored packages that are used")
+	rootCmd.Flags().BoolVar(&timingFlagVal, "timing", false, "print the time taken by each phase of the analysis")
+	rootCmd.Flags().IntVar(&maxOpenFilesFlagVal, "max-open-files", 0, "maximum number of vendored directories that are read concurrently (if 0 or 1, directories are read sequentially)")
+	rootCmd.Flags().BoolVar(&reportUnbuildableFlagVal, "report-unbuildable", false, "warn about vendored packages whose files are excluded by build constraints on every platform")
+	rootCmd.Flags().BoolVar(&sortByVendorDirFlagVal, "sort-by-vendor-dir", false, "group unused packages by vendor directory, separating the groups with a blank line")
+	rootCmd.Flags().StringArrayVar(&vendorHostsFlagVal, "vendor-host", nil, "hostnames whose packages should be grouped by repository (the host followed by two path segments)")
+	rootCmd.Flags().BoolVar(&reportNotVendoredFlagVal, "report-not-vendored", false, "report the file and line of imports of project packages that are not vendored")
+	rootCmd.Flags().IntVar(&maxUnusedFlagVal, "max-unused", 0, "exit with a non-zero status if the number of unused vendored packages exceeds this value (all unused packages are still printed)")
+	rootCmd.Flags().StringVar(&whyFlagVal, "why", "", "print the shortest import chain from the project packages to the specified vendored package instead of the unused packages")
+	rootCmd.Flags().BoolVar(&nullFlagVal, "null", false, "terminate each unused package with a NUL character instead of a newline")
+	rootCmd.Flags().BoolVar(&strictReachabilityFlagVal, "strict-reachability", false, "only consider vendored packages used if they are reachable from packages that are not vendored")
+	rootCmd.Flags().StringVar(&projectImportPathFlagVal, "project-import-path", "", "import path of the project: vendored copies of the project's own packages are excluded from the vendored packages")
+	rootCmd.Flags().StringVar(&cacheDirFlagVal, "cache-dir", "", "directory in which the packages of vendor directories are cached between runs")
+	rootCmd.Flags().BoolVar(&showNameFlagVal, "show-name", false, "print the names declared by each unused vendored package after its import path")
+	rootCmd.Flags().StringVar(&modFlagVal, "mod", string(novendor.ModVendor), "how imports of packages provided by required modules are resolved: 'vendor' uses the vendor directory, 'readonly' and 'mod' use the module cache ('readonly' fails if an import is not provided by a required module)")
+	rootCmd.Flags().BoolVar(&strictSubpackagesFlagVal, "strict-subpackages", false, "report vendored packages that are not imported even if other packages in their group (as determined by --pkg-regexp) are used")
+	rootCmd.Flags().BoolVar(&directOnlyFlagVal, "direct-only", false, "only consider the direct imports of the project packages (vendored packages that are only imported transitively are reported as unused)")
+	rootCmd.Flags().BoolVar(&requireVendorFlagVal, "require-vendor", false, fmt.Sprintf("fail with exit code %d if none of the packages have a vendor directory", noVendorDirExitCode))
+	rootCmd.Flags().BoolVar(&reportShadowedFlagVal, "report-shadowed", false, "warn about packages that are imported from outside of a vendor directory while also being vendored")
+	rootCmd.Flags().BoolVar(&quietFlagVal, "quiet", false, "print nothing if there are no unused packages; otherwise, print the output as usual and exit with a non-zero status")
+	rootCmd.Flags().BoolVar(&reportVendoredTestDepsFlagVal, "report-vendored-test-deps", false, "report unused vendored packages that are only reachable through the test files of used vendored packages")
+	rootCmd.Flags().BoolVar(&continueOnWalkErrorFlagVal, "continue-on-walk-error", false, "skip directories in vendor directories that cannot be read instead of failing (skipped directories are reported as warnings)")
+	rootCmd.Flags().StringVar(&headerTemplateFlagVal, "header-template", "", "Go template rendered before the unused packages (fields: .Count, .ProjectDir, .VendorDirs)")
+	rootCmd.Flags().StringVar(&footerTemplateFlagVal, "footer-template", "", "Go template rendered after all other output (fields: .Count, .ProjectDir, .VendorDirs)")
+	rootCmd.Flags().BoolVar(&checkDirNamesFlagVal, "check-dir-names", false, "warn about vendored packages whose directory names do not match their import paths")
+	rootCmd.Flags().BoolVar(&failOnMissingVendoringFlagVal, "fail-on-missing-vendoring", false, "fail if the packages import packages outside of the project but nothing is vendored")
+	rootCmd.Flags().StringVar(&goVersionFlagVal, "go-version", "", "Go version (for example, 1.18) whose release tags are set when build constraints are evaluated (default is the version of the toolchain)")
+	rootCmd.Flags().BoolVar(&reportRedundantIgnoresFlagVal, "report-redundant-ignores", false, "warn about packages specified using --ignore-pkg that are used")
+	rootCmd.Flags().BoolVar(&reportStaleIgnoresFlagVal, "report-stale-ignores", false, "warn about packages specified using --ignore-pkg that do not exist")
+	rootCmd.Flags().BoolVar(&caseInsensitiveFlagVal, "case-insensitive", false, "match import paths against vendored packages without regard to case (default determined by whether the filesystem is case-insensitive)")
+	rootCmd.Flags().BoolVar(&verboseFlagVal, "verbose", false, "print the steps of the analysis to stderr")
+	rootCmd.Flags().BoolVar(&progressFlagVal, "progress", false, "print the number of vendor directories that have been processed to stderr")
+	rootCmd.Flags().BoolVar(&dotFlagVal, "dot", false, "print a Graphviz DOT graph of the imports of the project in which used vendored packages are green and unused vendored packages are red")
+
+	rootCmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if _, ok := rootOnlyFlags[flag.Name]; !ok {
+			pruneCmd.Flags().AddFlag(flag)
+		}
+	})
+	pruneCmd.Flags().BoolVar(&dryRunFlagVal, "dry-run", false, "print the packages that would be removed without removing them")
+	rootCmd.AddCommand(pruneCmd)
 }
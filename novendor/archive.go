@@ -0,0 +1,204 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// archiveProjectName is the name of the directory in the GOPATH created by RunArchive into which archives are
+// extracted.
+const archiveProjectName = "novendor-archive"
+
+// RunArchive is like Run, but analyzes the project in the archive at the provided path. The archive can be a tar file
+// (".tar"), a gzip-compressed tar file (".tar.gz" or ".tgz") or a zip file (".zip"). The archive is extracted to a
+// temporary directory that is removed once the analysis is complete. The root of the archive is the project directory:
+// relative paths in pkgs are resolved against it, and the paths in the output are relative to it. The temporary
+// directory is used as the GOPATH for the analysis (Param.GOPATH is ignored), so only the packages in the archive are
+// considered.
+func RunArchive(archivePath string, pkgs []string, param Param, w io.Writer) (rErr error) {
+	gopath, err := ioutil.TempDir("", "novendor-")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temporary directory")
+	}
+	defer func() {
+		if err := os.RemoveAll(gopath); err != nil && rErr == nil {
+			rErr = errors.Wrapf(err, "failed to remove temporary directory %s", gopath)
+		}
+	}()
+
+	// extract the archive into a directory in the GOPATH so that vendored packages can be resolved
+	projectDir := filepath.Join(gopath, "src", archiveProjectName)
+	if err := extractArchive(archivePath, projectDir); err != nil {
+		return err
+	}
+
+	var archivePkgs []string
+	for _, pkg := range pkgs {
+		if !filepath.IsAbs(pkg) {
+			pkg = filepath.Join(projectDir, pkg)
+		}
+		archivePkgs = append(archivePkgs, pkg)
+	}
+	if len(archivePkgs) == 0 {
+		archivePkgs = []string{projectDir}
+	}
+	param.GOPATH = gopath
+	param.displayDir = projectDir
+	return Run(projectDir, archivePkgs, param, w)
+}
+
+// extractArchive extracts the archive at the provided path into the provided directory. The format of the archive is
+// determined based on its extension.
+func extractArchive(archivePath, destDir string) error {
+	switch {
+	case strings.HasSuffix(archivePath, ".zip"):
+		return extractZip(archivePath, destDir)
+	case strings.HasSuffix(archivePath, ".tar.gz"), strings.HasSuffix(archivePath, ".tgz"):
+		f, err := os.Open(archivePath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to open archive %s", archivePath)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		gzipReader, err := gzip.NewReader(f)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read gzip archive %s", archivePath)
+		}
+		return extractTar(archivePath, gzipReader, destDir)
+	case strings.HasSuffix(archivePath, ".tar"):
+		f, err := os.Open(archivePath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to open archive %s", archivePath)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		return extractTar(archivePath, f, destDir)
+	default:
+		return errors.Errorf("unsupported archive format for %s: must be .tar, .tar.gz, .tgz or .zip", archivePath)
+	}
+}
+
+// extractTar extracts the tar archive read from the provided reader into the provided directory. Only directories and
+// regular files are extracted.
+func extractTar(archivePath string, r io.Reader, destDir string) error {
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to read tar archive %s", archivePath)
+		}
+		destPath, err := archiveDestPath(destDir, header.Name)
+		if err != nil {
+			return errors.Wrapf(err, "invalid entry in archive %s", archivePath)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(destPath, 0755); err != nil {
+				return errors.Wrapf(err, "failed to create directory %s", destPath)
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := writeArchiveFile(destPath, tarReader); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractZip extracts the zip archive at the provided path into the provided directory. Only directories and regular
+// files are extracted.
+func extractZip(archivePath, destDir string) error {
+	zipReader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open archive %s", archivePath)
+	}
+	defer func() {
+		_ = zipReader.Close()
+	}()
+	for _, f := range zipReader.File {
+		destPath, err := archiveDestPath(destDir, f.Name)
+		if err != nil {
+			return errors.Wrapf(err, "invalid entry in archive %s", archivePath)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(destPath, 0755); err != nil {
+				return errors.Wrapf(err, "failed to create directory %s", destPath)
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		if err := extractZipFile(f, destPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(f *zip.File, destPath string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s in archive", f.Name)
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	return writeArchiveFile(destPath, rc)
+}
+
+// archiveDestPath returns the path in the provided directory to which the archive entry with the provided name should
+// be extracted. Returns an error if the entry would be extracted outside of the directory.
+func archiveDestPath(destDir, name string) (string, error) {
+	destPath := filepath.Join(destDir, filepath.FromSlash(name))
+	if destPath != destDir && !strings.HasPrefix(destPath, destDir+string(filepath.Separator)) {
+		return "", errors.Errorf("entry %s is outside of the archive root", name)
+	}
+	return destPath, nil
+}
+
+// writeArchiveFile writes the content read from the provided reader to a file at the provided path, creating its parent
+// directories if necessary.
+func writeArchiveFile(destPath string, r io.Reader) (rErr error) {
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %s", filepath.Dir(destPath))
+	}
+	f, err := os.Create(destPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create file %s", destPath)
+	}
+	defer func() {
+		if err := f.Close(); err != nil && rErr == nil {
+			rErr = errors.Wrapf(err, "failed to close file %s", destPath)
+		}
+	}()
+	if _, err := io.Copy(f, r); err != nil {
+		return errors.Wrapf(err, "failed to write file %s", destPath)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// vendorCacheVersion is the version of the format of vendor index cache files. It is part of the cache key, so it must
+// be incremented whenever the format changes.
+const vendorCacheVersion = 1
+
+// vendorCacheEntry is the content of a vendor index cache file.
+type vendorCacheEntry struct {
+	// Pkgs is the result of allVendoredPackages for the vendor directory.
+	Pkgs map[string][]*build.Package `json:"pkgs"`
+	// Retained are the import paths of the packages in the vendor directory that were retained because their
+	// directories contain one of the files in Param.RetainWithFiles.
+	Retained []string `json:"retained"`
+	// Warnings maps the directories in the vendor directory for which warnings were recorded to the warning messages.
+	Warnings map[string]string `json:"warnings"`
+}
+
+// vendoredPackages returns the packages in the provided vendor directory as determined by allVendoredPackages. If
+// param.CacheDir is non-empty, the packages are loaded from a cache file in that directory if the vendor directory has
+// not changed since the cache file was written and are saved to a cache file otherwise. Whether the vendor directory has
+// changed is determined based on the paths, sizes, modes and modification times of the files in it. Errors reading or
+// writing cache files are logged (if the resolver has a logger) but are otherwise ignored.
+func vendoredPackages(ctx context.Context, r *resolver, vendorDir string, param Param) (map[string][]*build.Package, error) {
+	if param.CacheDir == "" {
+		return allVendoredPackages(ctx, r, vendorDir)
+	}
+
+	loadStart := time.Now()
+	cachePath := ""
+	if key, err := vendorCacheKey(r, vendorDir); err != nil {
+		if r.logger != nil {
+			r.logger.Printf("failed to compute cache key for vendor directory %s: %v", vendorDir, err)
+		}
+	} else {
+		cachePath = filepath.Join(param.CacheDir, key+".json")
+		if pkgs, ok := loadVendorCache(r, cachePath); ok {
+			if r.logger != nil {
+				r.logger.Printf("loaded packages in vendor directory %s from cache file %s", vendorDir, cachePath)
+			}
+			param.reportMetric(PhaseLoadVendorCache, loadStart)
+			return pkgs, nil
+		}
+	}
+
+	pkgs, err := allVendoredPackages(ctx, r, vendorDir)
+	if err != nil {
+		return nil, err
+	}
+	if cachePath != "" {
+		if err := saveVendorCache(r, vendorDir, pkgs, cachePath); err != nil {
+			if r.logger != nil {
+				r.logger.Printf("failed to write cache file for vendor directory %s: %v", vendorDir, err)
+			}
+		}
+	}
+	return pkgs, nil
+}
+
+// vendorCacheKey returns the key of the cache file for the provided vendor directory. The key is derived from the
+// options of the resolver that affect the packages that are determined and from the path, size, mode and modification
+// time of every file and directory in the vendor directory that is examined.
+func vendorCacheKey(r *resolver, vendorDir string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "version: %d\n", vendorCacheVersion)
+	fmt.Fprintf(h, "go: %s\n", runtime.Version())
+	fmt.Fprintf(h, "dir: %s\n", vendorDir)
+	fmt.Fprintf(h, "vendorDirName: %s\n", r.vendorDirName)
+	fmt.Fprintf(h, "gopath: %s\n", r.ctx.GOPATH)
+	fmt.Fprintf(h, "goroot: %s\n", r.ctx.GOROOT)
+	fmt.Fprintf(h, "cgo: %v\n", r.ctx.CgoEnabled)
+	fmt.Fprintf(h, "buildTags: %s\n", strings.Join(r.ctx.BuildTags, ","))
+	fmt.Fprintf(h, "releaseTags: %s\n", strings.Join(r.ctx.ReleaseTags, ","))
+	fmt.Fprintf(h, "followSymlinks: %v\n", r.followSymlinks)
+	fmt.Fprintf(h, "skipDirs: %s\n", strings.Join(sortedVals(r.skipDirs), ","))
+	fmt.Fprintf(h, "retainWithFiles: %s\n", strings.Join(r.retainWithFiles, ","))
+	fmt.Fprintf(h, "projectImportPath: %s\n", r.projectImportPath)
+	fmt.Fprintf(h, "mainModule: %s\n", r.modulePath)
+	fmt.Fprintf(h, "warnings: %v\n", r.warnings != nil)
+	writeOverlayHash(h, r.overlay)
+
+	if err := walk(vendorDir, r.followSymlinks, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if _, ok := r.skipDirs[info.Name()]; ok && info.IsDir() && path != vendorDir {
+			return filepath.SkipDir
+		}
+		fmt.Fprintf(h, "%s %d %d %d\n", path, info.Size(), info.Mode(), info.ModTime().UnixNano())
+		return nil
+	}); err != nil {
+		return "", errors.Wrapf(err, "failed to walk directory %s", vendorDir)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeOverlayHash writes the paths and contents of the files in the provided overlay to the provided hash in sorted
+// order.
+func writeOverlayHash(h hash.Hash, overlay map[string][]byte) {
+	var filenames []string
+	for filename := range overlay {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		fmt.Fprintf(h, "overlay: %s %d\n", filename, len(overlay[filename]))
+		_, _ = h.Write(overlay[filename])
+	}
+}
+
+// loadVendorCache returns the packages stored in the cache file at the provided path and true if the cache file exists
+// and is valid. The retained packages and warnings stored in the cache file are recorded in the provided resolver.
+func loadVendorCache(r *resolver, cachePath string) (map[string][]*build.Package, bool) {
+	cacheBytes, err := ioutil.ReadFile(cachePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			if r.logger != nil {
+				r.logger.Printf("failed to read cache file %s: %v", cachePath, err)
+			}
+		}
+		return nil, false
+	}
+	var entry vendorCacheEntry
+	if err := json.Unmarshal(cacheBytes, &entry); err != nil {
+		if r.logger != nil {
+			r.logger.Printf("failed to parse cache file %s: %v", cachePath, err)
+		}
+		return nil, false
+	}
+	if r.retainedPkgs != nil {
+		for _, importPath := range entry.Retained {
+			r.retainedPkgs[importPath] = struct{}{}
+		}
+	}
+	for dir, msg := range entry.Warnings {
+		r.recordWarning(&build.Package{Dir: dir}, errors.New(msg))
+	}
+	return entry.Pkgs, true
+}
+
+// saveVendorCache writes the provided packages of the provided vendor directory to a cache file at the provided path
+// along with the retained packages and the warnings that the resolver recorded for the vendor directory.
+func saveVendorCache(r *resolver, vendorDir string, pkgs map[string][]*build.Package, cachePath string) error {
+	entry := vendorCacheEntry{
+		Pkgs: pkgs,
+	}
+	for importPath := range pkgs {
+		if _, ok := r.retainedPkgs[importPath]; ok {
+			entry.Retained = append(entry.Retained, importPath)
+		}
+	}
+	sort.Strings(entry.Retained)
+	if r.warnings != nil {
+		r.warningsMu.Lock()
+		for dir, err := range r.warnings {
+			if dir != vendorDir && !strings.HasPrefix(dir, vendorDir+string(filepath.Separator)) {
+				continue
+			}
+			if entry.Warnings == nil {
+				entry.Warnings = make(map[string]string)
+			}
+			if parseErr, ok := err.(*PackageParseError); ok {
+				err = parseErr.Err
+			}
+			entry.Warnings[dir] = err.Error()
+		}
+		r.warningsMu.Unlock()
+	}
+
+	cacheBytes, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal cache entry")
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create cache directory")
+	}
+	// write to a temporary file and rename it so that concurrent runs never read a partially written cache file
+	tmpFile, err := ioutil.TempFile(filepath.Dir(cachePath), filepath.Base(cachePath)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temporary cache file")
+	}
+	if _, err := tmpFile.Write(cacheBytes); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return errors.Wrapf(err, "failed to write temporary cache file")
+	}
+	if err := tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return errors.Wrapf(err, "failed to close temporary cache file")
+	}
+	if err := os.Rename(tmpFile.Name(), cachePath); err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return errors.Wrapf(err, "failed to rename temporary cache file")
+	}
+	return nil
+}
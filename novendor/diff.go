@@ -0,0 +1,99 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// ResultDiff is the difference between the unused packages of two results.
+type ResultDiff struct {
+	// NewlyUnused are the packages that are unused in the head result but were not unused in the base result.
+	NewlyUnused []DiffPkg
+	// NewlyUsed are the packages that were unused in the base result and are used in the head result.
+	NewlyUsed []DiffPkg
+	// Removed are the packages that were unused in the base result and are no longer vendored in the head result.
+	Removed []DiffPkg
+}
+
+// DiffPkg identifies a vendored package in a ResultDiff.
+type DiffPkg struct {
+	// VendorDir is the path of the vendor directory that contains the package relative to the project directory of
+	// the result, using forward slashes.
+	VendorDir string
+	// ImportPath is the import path of the package (not including the vendor directory).
+	ImportPath string
+}
+
+// DiffResults returns the difference between the unused packages of the provided base and head results. Packages are
+// identified by their import paths and the paths of their vendor directories relative to the project directories of
+// the results, so results for different copies of a project (for example, different checkouts) can be compared. The
+// packages in each field of the returned diff are sorted by vendor directory and then by import path.
+func DiffResults(base, head *Result) *ResultDiff {
+	baseUnused := diffPkgs(base, base.UnusedPkgs)
+	headUnused := diffPkgs(head, head.UnusedPkgs)
+	headUsed := diffPkgs(head, head.UsedVendored)
+
+	diff := &ResultDiff{}
+	for pkg := range headUnused {
+		if _, ok := baseUnused[pkg]; !ok {
+			diff.NewlyUnused = append(diff.NewlyUnused, pkg)
+		}
+	}
+	for pkg := range baseUnused {
+		if _, ok := headUnused[pkg]; ok {
+			continue
+		}
+		if _, ok := headUsed[pkg]; ok {
+			diff.NewlyUsed = append(diff.NewlyUsed, pkg)
+		} else {
+			diff.Removed = append(diff.Removed, pkg)
+		}
+	}
+	sortDiffPkgs(diff.NewlyUnused)
+	sortDiffPkgs(diff.NewlyUsed)
+	sortDiffPkgs(diff.Removed)
+	return diff
+}
+
+// diffPkgs returns the set of the packages in the provided map (from vendor directory to the import paths of packages
+// in that directory) of the provided result.
+func diffPkgs(result *Result, pkgsInVendorDirs map[string][]string) map[DiffPkg]struct{} {
+	pkgs := make(map[DiffPkg]struct{})
+	for vendorDir, importPaths := range pkgsInVendorDirs {
+		relVendorDir := vendorDir
+		if relPath, err := filepath.Rel(result.ProjectDir, vendorDir); err == nil {
+			relVendorDir = relPath
+		}
+		relVendorDir = filepath.ToSlash(relVendorDir)
+		for _, importPath := range importPaths {
+			pkgs[DiffPkg{
+				VendorDir:  relVendorDir,
+				ImportPath: outputImportPath(importPath, false, filepath.Base(vendorDir)),
+			}] = struct{}{}
+		}
+	}
+	return pkgs
+}
+
+func sortDiffPkgs(pkgs []DiffPkg) {
+	sort.Slice(pkgs, func(i, j int) bool {
+		if pkgs[i].VendorDir != pkgs[j].VendorDir {
+			return pkgs[i].VendorDir < pkgs[j].VendorDir
+		}
+		return pkgs[i].ImportPath < pkgs[j].ImportPath
+	})
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// EnvPkgRegexp is the environment variable that specifies the regular expressions used to group packages
+	// (Config.PkgRegexps) as a comma- or newline-separated list.
+	EnvPkgRegexp = "NOVENDOR_PKG_REGEXP"
+	// EnvIgnorePkg is the environment variable that specifies the packages that should be ignored (Config.IgnorePkgs)
+	// as a comma- or newline-separated list.
+	EnvIgnorePkg = "NOVENDOR_IGNORE_PKG"
+	// EnvFullImportPath is the environment variable that specifies whether the full import path should be printed
+	// (Config.IncludeVendorInImportPath). The value must be a boolean as accepted by strconv.ParseBool.
+	EnvFullImportPath = "NOVENDOR_FULL_IMPORT_PATH"
+)
+
+// WithEnv returns a copy of this configuration with the values specified by the EnvPkgRegexp, EnvIgnorePkg and
+// EnvFullImportPath environment variables applied to it. Environment variables are looked up using the provided
+// function (typically os.LookupEnv). Values for environment variables that are not set or are empty are retained from
+// this configuration. Returns an error if the value of an environment variable is not valid.
+func (c Config) WithEnv(lookupEnv func(key string) (string, bool)) (Config, error) {
+	if val, ok := lookupEnv(EnvPkgRegexp); ok && strings.TrimSpace(val) != "" {
+		c.PkgRegexps = splitEnvList(val)
+	}
+	if val, ok := lookupEnv(EnvIgnorePkg); ok && strings.TrimSpace(val) != "" {
+		c.IgnorePkgs = splitEnvList(val)
+	}
+	if val, ok := lookupEnv(EnvFullImportPath); ok && strings.TrimSpace(val) != "" {
+		fullImportPath, err := strconv.ParseBool(strings.TrimSpace(val))
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "invalid value for environment variable %s", EnvFullImportPath)
+		}
+		c.IncludeVendorInImportPath = fullImportPath
+	}
+	return c, nil
+}
+
+// splitEnvList splits the provided comma- or newline-separated list into its elements. Whitespace around elements is
+// trimmed and empty elements are omitted.
+func splitEnvList(val string) []string {
+	var out []string
+	for _, elem := range strings.FieldsFunc(val, func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			out = append(out, elem)
+		}
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoVendorDir is the cause of errors that occur because a vendor directory does not exist or is not valid, including
+// the error returned when Param.RequireVendor is true and none of the analyzed packages have a vendor directory. Use
+// errors.Cause to determine whether an error returned by this package has this cause.
+var ErrNoVendorDir = errors.New("no vendor directory")
+
+// ErrUnusedPkgs is the cause of the error returned by Check if there are unused vendored packages and of the error
+// returned by the Run functions if the number of unused vendored packages exceeds Param.MaxUnused.
+var ErrUnusedPkgs = errors.New("unused vendored packages")
+
+// ErrMissingVendoring is the cause of the error returned when Param.FailOnMissingVendoring is true and the analyzed
+// packages import packages outside of the project but no packages are vendored.
+var ErrMissingVendoring = errors.New("external packages are imported but nothing is vendored")
+
+// ErrNoRequiredModule is the cause of the error returned when Param.ModMode is ModReadonly and a package is imported
+// that is not provided by any of the modules required by the project.
+var ErrNoRequiredModule = errors.New("no required module provides package")
+
+// PackageParseError is an error that occurred while parsing the package in a directory.
+type PackageParseError struct {
+	// Dir is the directory of the package.
+	Dir string
+	// Err is the error that occurred while parsing the package.
+	Err error
+}
+
+func (e *PackageParseError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Dir, e.Err)
+}
+
+// Cause returns the error that occurred while parsing the package. Allows the underlying error to be retrieved using
+// errors.Cause.
+func (e *PackageParseError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the error that occurred while parsing the package.
+func (e *PackageParseError) Unwrap() error {
+	return e.Err
+}
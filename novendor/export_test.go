@@ -0,0 +1,45 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"go/build"
+)
+
+// TransformImportPath exports transformImportPath for tests.
+var TransformImportPath = transformImportPath
+
+// PkgsInDirIterations returns the names of the packages in the provided directory and the number of iterations that
+// were required to determine them.
+func PkgsInDirIterations(dir string) ([]string, int, error) {
+	pkgs, iterations, err := pkgsInDir(newResolver(Param{}), ".", dir, make(map[string]struct{}))
+	var names []string
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+	return names, iterations, err
+}
+
+// PkgNamesByImportPath returns a map from import path to the names of the provided packages with that import path as
+// determined by pkgsByImportPath.
+func PkgNamesByImportPath(pkgs []*build.Package) map[string][]string {
+	names := make(map[string][]string)
+	for importPath, pkgsForPath := range pkgsByImportPath(pkgs) {
+		for _, pkg := range pkgsForPath {
+			names[importPath] = append(names[importPath], pkg.Name)
+		}
+	}
+	return names
+}
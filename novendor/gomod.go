@@ -0,0 +1,238 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// localReplacements returns the "replace" directives of the go.mod file in the provided directory whose replacement is
+// a local directory. The returned map is from the replaced module path to the absolute path of the replacement
+// directory. Replacements that refer to other modules are not returned because packages provided by such replacements
+// are resolved (and vendored) at the import path of the replaced module. Returns an empty map if the directory does not
+// contain a go.mod file.
+func localReplacements(dir string) (map[string]string, error) {
+	goModPath := path.Join(dir, "go.mod")
+	directives, err := goModDirectives(goModPath, "replace")
+	if err != nil {
+		return nil, err
+	}
+
+	replacements := make(map[string]string)
+	for _, directive := range directives {
+		oldPath, newPath, err := parseReplacement(directive.fields)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid replace directive on line %d of %s", directive.line, goModPath)
+		}
+		if !isLocalReplacement(newPath) {
+			continue
+		}
+		if !filepath.IsAbs(newPath) {
+			newPath = path.Join(dir, newPath)
+		}
+		replacements[oldPath] = newPath
+	}
+	return replacements, nil
+}
+
+// requiredModules returns the path of the module declared by the go.mod file in the provided directory and the modules
+// that it requires. The returned map is from module path to the required version. Returns an empty module path and an
+// empty map if the directory does not contain a go.mod file.
+func requiredModules(dir string) (string, map[string]string, error) {
+	goModPath := path.Join(dir, "go.mod")
+	moduleDirectives, err := goModDirectives(goModPath, "module")
+	if err != nil {
+		return "", nil, err
+	}
+	modulePath := ""
+	for _, directive := range moduleDirectives {
+		if len(directive.fields) != 1 {
+			return "", nil, errors.Errorf("invalid module directive on line %d of %s", directive.line, goModPath)
+		}
+		if modulePath, err = unquoteModPath(directive.fields[0]); err != nil {
+			return "", nil, errors.Wrapf(err, "invalid module directive on line %d of %s", directive.line, goModPath)
+		}
+	}
+
+	requireDirectives, err := goModDirectives(goModPath, "require")
+	if err != nil {
+		return "", nil, err
+	}
+	requires := make(map[string]string)
+	for _, directive := range requireDirectives {
+		if len(directive.fields) != 2 {
+			return "", nil, errors.Errorf("invalid require directive on line %d of %s: expected form 'path version', was %q", directive.line, goModPath, strings.Join(directive.fields, " "))
+		}
+		modPath, err := unquoteModPath(directive.fields[0])
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "invalid require directive on line %d of %s", directive.line, goModPath)
+		}
+		requires[modPath] = directive.fields[1]
+	}
+	return modulePath, requires, nil
+}
+
+// vendoredModules returns the sorted paths of the modules listed in the "modules.txt" file of the provided vendor
+// directory, which is created by "go mod vendor". Returns nil if the file does not exist.
+func vendoredModules(vendorDir string) ([]string, error) {
+	modulesTxtPath := filepath.Join(vendorDir, "modules.txt")
+	modulesTxtBytes, err := ioutil.ReadFile(modulesTxtPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", modulesTxtPath)
+	}
+
+	modPaths := []string{}
+	seen := make(map[string]struct{})
+	for i, line := range strings.Split(string(modulesTxtBytes), "\n") {
+		// module lines are of the form "# path version [=> replacement [version]]": lines starting with "## " are
+		// annotations and all other lines are the vendored packages of the preceding module
+		if !strings.HasPrefix(line, "# ") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "# "))
+		if len(fields) == 0 {
+			return nil, errors.Errorf("invalid module line on line %d of %s", i+1, modulesTxtPath)
+		}
+		if _, ok := seen[fields[0]]; ok {
+			continue
+		}
+		seen[fields[0]] = struct{}{}
+		modPaths = append(modPaths, fields[0])
+	}
+	sort.Strings(modPaths)
+	return modPaths, nil
+}
+
+// goModDirective is a directive of a go.mod file.
+type goModDirective struct {
+	// line is the 1-based line number of the directive.
+	line int
+	// fields are the fields of the directive without the verb and without comments.
+	fields []string
+}
+
+// goModDirectives returns the directives with the provided verb (for example, "replace") in the go.mod file at the
+// provided path. Both the single-line form and the block form of directives are supported. Returns nil if the file does
+// not exist.
+func goModDirectives(goModPath, verb string) ([]goModDirective, error) {
+	goModBytes, err := ioutil.ReadFile(goModPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", goModPath)
+	}
+
+	var directives []goModDirective
+	inBlock := false
+	for i, line := range strings.Split(string(goModBytes), "\n") {
+		if commentIdx := strings.Index(line, "//"); commentIdx != -1 {
+			line = line[:commentIdx]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case inBlock && fields[0] == ")":
+			inBlock = false
+			continue
+		case inBlock:
+		case fields[0] == verb && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+			continue
+		case fields[0] == verb:
+			fields = fields[1:]
+		default:
+			continue
+		}
+		directives = append(directives, goModDirective{
+			line:   i + 1,
+			fields: fields,
+		})
+	}
+	return directives, nil
+}
+
+// parseReplacement parses the fields of a replace directive of the form "old [version] => new [version]" and returns
+// the old and new paths.
+func parseReplacement(fields []string) (string, string, error) {
+	arrowIdx := -1
+	for i, field := range fields {
+		if field == "=>" {
+			arrowIdx = i
+			break
+		}
+	}
+	if arrowIdx < 1 || arrowIdx > 2 || len(fields)-arrowIdx < 2 || len(fields)-arrowIdx > 3 {
+		return "", "", errors.Errorf("expected form 'old [version] => new [version]', was %q", strings.Join(fields, " "))
+	}
+	oldPath, err := unquoteModPath(fields[0])
+	if err != nil {
+		return "", "", err
+	}
+	newPath, err := unquoteModPath(fields[arrowIdx+1])
+	if err != nil {
+		return "", "", err
+	}
+	return oldPath, newPath, nil
+}
+
+func unquoteModPath(modPath string) (string, error) {
+	if !strings.HasPrefix(modPath, `"`) {
+		return modPath, nil
+	}
+	unquoted, err := strconv.Unquote(modPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid quoted path %s", modPath)
+	}
+	return unquoted, nil
+}
+
+// isLocalReplacement returns true if the provided replacement path refers to a local directory. Consistent with the go
+// command, only paths that are absolute or start with "./" or "../" are considered local.
+func isLocalReplacement(replacement string) bool {
+	return filepath.IsAbs(replacement) || strings.HasPrefix(replacement, "./") || strings.HasPrefix(replacement, "../")
+}
+
+// moduleCacheDir returns the directory in the module cache rooted at the provided directory that contains the provided
+// version of the module with the provided path.
+func moduleCacheDir(modCacheDir, modPath, version string) string {
+	return filepath.Join(modCacheDir, filepath.FromSlash(escapeModulePath(modPath)+"@"+escapeModulePath(version)))
+}
+
+// escapeModulePath returns the provided module path or version escaped in the manner of the module cache: every
+// upper-case letter is replaced by an exclamation mark followed by the lower-case letter.
+func escapeModulePath(modPath string) string {
+	var buf bytes.Buffer
+	for _, r := range modPath {
+		if unicode.IsUpper(r) {
+			buf.WriteRune('!')
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
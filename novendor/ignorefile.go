@@ -0,0 +1,54 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// IgnoreFileName is the name of the file in the project directory that specifies packages that should be ignored.
+const IgnoreFileName = ".novendorignore"
+
+// LoadIgnoreFile returns the packages specified by the IgnoreFileName file in the provided project directory. The file
+// contains one package per line. Blank lines and lines that start with "#" are skipped. Relative paths are resolved
+// against the project directory so that the file can be used regardless of the working directory. Returns nil if the
+// project directory does not contain the file.
+func LoadIgnoreFile(projectDir string) ([]string, error) {
+	ignoreFilePath := filepath.Join(projectDir, IgnoreFileName)
+	ignoreFileBytes, err := ioutil.ReadFile(ignoreFilePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", ignoreFilePath)
+	}
+
+	var ignorePkgs []string
+	for _, line := range strings.Split(string(ignoreFileBytes), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(projectDir, line)
+		}
+		ignorePkgs = append(ignorePkgs, line)
+	}
+	return ignorePkgs, nil
+}
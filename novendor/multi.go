@@ -0,0 +1,154 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ProjectSpec specifies a project that is analyzed by RunMulti.
+type ProjectSpec struct {
+	// ProjectDir is the directory of the project.
+	ProjectDir string
+	// Pkgs are the packages of the project that are analyzed. Relative paths are resolved against ProjectDir. If
+	// empty, all of the packages in ProjectDir are analyzed.
+	Pkgs []string
+}
+
+// projectOutput is the output and error of running the analysis for a single project.
+type projectOutput struct {
+	buf *bytes.Buffer
+	err error
+}
+
+// RunMulti is like Run, but analyzes each of the provided projects independently using the provided parameters and
+// writes the output of all of the projects to w. Analyzing many projects in a single process avoids the cost of
+// starting a process (and of reading the standard library) for each of them. Up to param.MaxConcurrentProjects
+// projects are analyzed concurrently, so functions provided in param (such as OnUnused) must be safe for concurrent
+// use if it is greater than 1.
+//
+// The output of each project is written once the project and all of the projects before it have been analyzed, so the
+// output is always in the order of the provided projects. If the format is FormatText or FormatGitHubActions, the
+// output of each project is preceded by a line of the form "project <dir>:". If the format is FormatDOT, the line is a
+// DOT comment. If the format is FormatJSONL, no line is written and the Project field of each JSON line is set
+// instead.
+//
+// The failure of the analysis of one project does not prevent the other projects from being analyzed. If the analysis
+// of any project fails, the returned error is the first error (in the order of the provided projects) whose cause is
+// not ErrUnusedPkgs or, if there is no such error, the first error. The returned error identifies the project.
+func RunMulti(projects []ProjectSpec, param Param, w io.Writer) error {
+	param.jsonlProject = true
+	outputs := make([]projectOutput, len(projects))
+	done := make([]chan struct{}, len(projects))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	maxConcurrent := param.MaxConcurrentProjects
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	sem := make(chan struct{}, maxConcurrent)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i, project := range projects {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(i int, project ProjectSpec) {
+				defer wg.Done()
+				defer close(done[i])
+				defer func() {
+					<-sem
+				}()
+				buf := &bytes.Buffer{}
+				outputs[i] = projectOutput{
+					buf: buf,
+					err: Run(project.ProjectDir, projectPkgs(project), param, buf),
+				}
+			}(i, project)
+		}
+	}()
+
+	var writeErr error
+	for i, project := range projects {
+		<-done[i]
+		if writeErr != nil {
+			continue
+		}
+		if _, err := io.WriteString(w, projectDelimiter(project.ProjectDir, param)); err != nil {
+			writeErr = errors.Wrapf(err, "failed to write output")
+			continue
+		}
+		if _, err := outputs[i].buf.WriteTo(w); err != nil {
+			writeErr = errors.Wrapf(err, "failed to write output")
+		}
+	}
+	wg.Wait()
+	if writeErr != nil {
+		return writeErr
+	}
+
+	var firstErr error
+	for i, project := range projects {
+		err := outputs[i].err
+		if err == nil {
+			continue
+		}
+		err = errors.Wrapf(err, "project %s", project.ProjectDir)
+		if errors.Cause(err) != ErrUnusedPkgs {
+			return err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// projectPkgs returns the packages of the provided project with relative paths resolved against the project
+// directory. If the project does not specify any packages, all of the packages in the project directory are returned.
+func projectPkgs(project ProjectSpec) []string {
+	if len(project.Pkgs) == 0 {
+		return []string{filepath.Join(project.ProjectDir, "...")}
+	}
+	var pkgs []string
+	for _, pkg := range project.Pkgs {
+		if !filepath.IsAbs(pkg) {
+			pkg = filepath.Join(project.ProjectDir, pkg)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+// projectDelimiter returns the line written by RunMulti before the output of the project in the provided directory.
+func projectDelimiter(projectDir string, param Param) string {
+	switch param.Format {
+	case FormatJSONL:
+		return ""
+	case FormatDOT:
+		return fmt.Sprintf("// project %s\n", projectDir)
+	default:
+		return fmt.Sprintf("project %s:\n", projectDir)
+	}
+}
@@ -15,35 +15,219 @@
 package novendor
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"go/build"
+	"go/parser"
+	"go/token"
 	"io"
 	"io/ioutil"
+	"log"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	PkgRegexps                []string `json:"pkgRegexps"`
-	IncludeVendorInImportPath bool     `json:"includeVendorInImportPath"`
-	IgnorePkgs                []string `json:"ignorePkgs"`
+	PkgRegexps                []string                        `json:"pkgRegexps" yaml:"pkgRegexps"`
+	IncludeVendorInImportPath bool                            `json:"includeVendorInImportPath" yaml:"includeVendorInImportPath"`
+	IgnorePkgs                []string                        `json:"ignorePkgs" yaml:"ignorePkgs"`
+	IncludeTestImports        *bool                           `json:"includeTestImports" yaml:"includeTestImports"`
+	ShowImporters             bool                            `json:"showImporters" yaml:"showImporters"`
+	Summary                   bool                            `json:"summary" yaml:"summary"`
+	ReportEmpty               bool                            `json:"reportEmpty" yaml:"reportEmpty"`
+	CheckImportComments       bool                            `json:"checkImportComments" yaml:"checkImportComments"`
+	VendorDirName             string                          `json:"vendorDirName" yaml:"vendorDirName"`
+	ReportWarnings            bool                            `json:"reportWarnings" yaml:"reportWarnings"`
+	RelativePaths             bool                            `json:"relativePaths" yaml:"relativePaths"`
+	CheckStdlibShadow         bool                            `json:"checkStdlibShadow" yaml:"checkStdlibShadow"`
+	MaxDepth                  int                             `json:"maxDepth" yaml:"maxDepth"`
+	GroupByRepo               bool                            `json:"groupByRepo" yaml:"groupByRepo"`
+	WarnVendoredMain          bool                            `json:"warnVendoredMain" yaml:"warnVendoredMain"`
+	ExplainIgnores            bool                            `json:"explainIgnores" yaml:"explainIgnores"`
+	FollowSymlinks            bool                            `json:"followSymlinks" yaml:"followSymlinks"`
+	AllowUnused               []string                        `json:"allowUnused" yaml:"allowUnused"`
+	OnlyBuildIgnored          bool                            `json:"onlyBuildIgnored" yaml:"onlyBuildIgnored"`
+	SkipDirs                  []string                        `json:"skipDirs" yaml:"skipDirs"`
+	CgoEnabled                *bool                           `json:"cgoEnabled" yaml:"cgoEnabled"`
+	Dedupe                    bool                            `json:"dedupe" yaml:"dedupe"`
+	BuildTags                 []string                        `json:"buildTags" yaml:"buildTags"`
+	CollapseInternal          bool                            `json:"collapseInternal" yaml:"collapseInternal"`
+	Platforms                 []string                        `json:"platforms" yaml:"platforms"`
+	AbsPaths                  bool                            `json:"absPaths" yaml:"absPaths"`
+	IgnorePrefixes            []string                        `json:"ignorePrefixes" yaml:"ignorePrefixes"`
+	CheckVersionMismatch      bool                            `json:"checkVersionMismatch" yaml:"checkVersionMismatch"`
+	PerPkgContext             map[string]BuildContextOverride `json:"perPkgContext" yaml:"perPkgContext"`
+	AllowNestedVendor         bool                            `json:"allowNestedVendor" yaml:"allowNestedVendor"`
+	Stats                     bool                            `json:"stats" yaml:"stats"`
+	TrackStdlib               bool                            `json:"trackStdlib" yaml:"trackStdlib"`
+	AdditionalPkgRegexps      []string                        `json:"additionalPkgRegexps" yaml:"additionalPkgRegexps"`
+	ReportBlankOnly           bool                            `json:"reportBlankOnly" yaml:"reportBlankOnly"`
+	GOPATH                    string                          `json:"gopath" yaml:"gopath"`
+	RetainWithFiles           []string                        `json:"retainWithFiles" yaml:"retainWithFiles"`
+	ShowUsed                  bool                            `json:"showUsed" yaml:"showUsed"`
+	MaxOpenFiles              int                             `json:"maxOpenFiles" yaml:"maxOpenFiles"`
+	ReportUnbuildable         bool                            `json:"reportUnbuildable" yaml:"reportUnbuildable"`
+	SortByVendorDir           bool                            `json:"sortByVendorDir" yaml:"sortByVendorDir"`
+	VendorHosts               []string                        `json:"vendorHosts" yaml:"vendorHosts"`
+	ReportNotVendored         bool                            `json:"reportNotVendored" yaml:"reportNotVendored"`
+	MaxUnused                 *int                            `json:"maxUnused" yaml:"maxUnused"`
+	RecordSeparator           string                          `json:"recordSeparator" yaml:"recordSeparator"`
+	StrictReachability        bool                            `json:"strictReachability" yaml:"strictReachability"`
+	ProjectImportPath         string                          `json:"projectImportPath" yaml:"projectImportPath"`
+	CacheDir                  string                          `json:"cacheDir" yaml:"cacheDir"`
+	ShowName                  bool                            `json:"showName" yaml:"showName"`
+	ModMode                   ModMode                         `json:"modMode" yaml:"modMode"`
+	ModCacheDir               string                          `json:"modCacheDir" yaml:"modCacheDir"`
+	StrictSubpackages         bool                            `json:"strictSubpackages" yaml:"strictSubpackages"`
+	DirectOnly                bool                            `json:"directOnly" yaml:"directOnly"`
+	RequireVendor             bool                            `json:"requireVendor" yaml:"requireVendor"`
+	ReportShadowed            bool                            `json:"reportShadowed" yaml:"reportShadowed"`
+	Quiet                     bool                            `json:"quiet" yaml:"quiet"`
+	ReportVendoredTestDeps    bool                            `json:"reportVendoredTestDeps" yaml:"reportVendoredTestDeps"`
+	ContinueOnWalkError       bool                            `json:"continueOnWalkError" yaml:"continueOnWalkError"`
+	HeaderTemplate            string                          `json:"headerTemplate" yaml:"headerTemplate"`
+	FooterTemplate            string                          `json:"footerTemplate" yaml:"footerTemplate"`
+	CheckDirNames             bool                            `json:"checkDirNames" yaml:"checkDirNames"`
+	FailOnMissingVendoring    bool                            `json:"failOnMissingVendoring" yaml:"failOnMissingVendoring"`
+	GoVersion                 string                          `json:"goVersion" yaml:"goVersion"`
+	ReportRedundantIgnores    bool                            `json:"reportRedundantIgnores" yaml:"reportRedundantIgnores"`
+	ReportStaleIgnores        bool                            `json:"reportStaleIgnores" yaml:"reportStaleIgnores"`
+	CaseInsensitive           *bool                           `json:"caseInsensitive" yaml:"caseInsensitive"`
+	Format                    Format                          `json:"format" yaml:"format"`
+}
+
+// LoadFromFile returns a copy of this configuration with the values specified in the YAML or JSON file at the provided
+// path applied to it. Values that are not specified in the file retain their values from this configuration.
+func (c Config) LoadFromFile(path string) (Config, error) {
+	cfgBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, errors.Wrapf(err, "failed to read configuration file %s", path)
+	}
+	if err := yaml.Unmarshal(cfgBytes, &c); err != nil {
+		return Config{}, errors.Wrapf(err, "failed to unmarshal configuration file %s", path)
+	}
+	return c, nil
 }
 
 func (c *Config) ToParam() (Param, error) {
-	regexps, err := regexpsForPkgMatchers(c.PkgRegexps)
+	var pkgRegexps []string
+	pkgRegexps = append(pkgRegexps, c.PkgRegexps...)
+	pkgRegexps = append(pkgRegexps, c.AdditionalPkgRegexps...)
+	for _, host := range c.VendorHosts {
+		pkgRegexps = append(pkgRegexps, vendorHostRegexp(host))
+	}
+	regexps, err := regexpsForPkgMatchers(pkgRegexps)
 	if err != nil {
 		return Param{}, err
 	}
+	switch c.Format {
+	case "", FormatText, FormatDOT, FormatJSONL, FormatGitHubActions:
+	default:
+		return Param{}, errors.Errorf("unknown format %q", c.Format)
+	}
+	switch c.ModMode {
+	case "", ModVendor, ModReadonly, ModMod:
+	default:
+		return Param{}, errors.Errorf("unknown module mode %q", c.ModMode)
+	}
+	if _, err := parseReportTemplate("header", c.HeaderTemplate); err != nil {
+		return Param{}, err
+	}
+	if _, err := parseReportTemplate("footer", c.FooterTemplate); err != nil {
+		return Param{}, err
+	}
+	if c.GoVersion != "" {
+		if _, err := goReleaseTags(c.GoVersion); err != nil {
+			return Param{}, err
+		}
+	}
+	var platforms [][2]string
+	for _, platform := range c.Platforms {
+		parts := strings.Split(platform, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return Param{}, errors.Errorf("platform must be of the form GOOS/GOARCH, was %q", platform)
+		}
+		platforms = append(platforms, [2]string{parts[0], parts[1]})
+	}
 	return Param{
 		PkgRegexps:                regexps,
 		IncludeVendorInImportPath: c.IncludeVendorInImportPath,
 		IgnorePkgs:                c.IgnorePkgs,
+		ExcludeTestImports:        c.IncludeTestImports != nil && !*c.IncludeTestImports,
+		ShowImporters:             c.ShowImporters,
+		Summary:                   c.Summary,
+		ReportEmpty:               c.ReportEmpty,
+		CheckImportComments:       c.CheckImportComments,
+		VendorDirName:             c.VendorDirName,
+		ReportWarnings:            c.ReportWarnings,
+		RelativePaths:             c.RelativePaths,
+		CheckStdlibShadow:         c.CheckStdlibShadow,
+		MaxDepth:                  c.MaxDepth,
+		GroupByRepo:               c.GroupByRepo,
+		WarnVendoredMain:          c.WarnVendoredMain,
+		ExplainIgnores:            c.ExplainIgnores,
+		FollowSymlinks:            c.FollowSymlinks,
+		AllowUnused:               c.AllowUnused,
+		OnlyBuildIgnored:          c.OnlyBuildIgnored,
+		SkipDirs:                  c.SkipDirs,
+		CgoEnabled:                c.CgoEnabled,
+		Dedupe:                    c.Dedupe,
+		BuildTags:                 c.BuildTags,
+		CollapseInternal:          c.CollapseInternal,
+		Platforms:                 platforms,
+		AbsPaths:                  c.AbsPaths,
+		IgnorePrefixes:            c.IgnorePrefixes,
+		CheckVersionMismatch:      c.CheckVersionMismatch,
+		PerPkgContext:             c.PerPkgContext,
+		AllowNestedVendor:         c.AllowNestedVendor,
+		Stats:                     c.Stats,
+		TrackStdlib:               c.TrackStdlib,
+		ReportBlankOnly:           c.ReportBlankOnly,
+		GOPATH:                    c.GOPATH,
+		RetainWithFiles:           c.RetainWithFiles,
+		ShowUsed:                  c.ShowUsed,
+		MaxOpenFiles:              c.MaxOpenFiles,
+		ReportUnbuildable:         c.ReportUnbuildable,
+		SortByVendorDir:           c.SortByVendorDir,
+		ReportNotVendored:         c.ReportNotVendored,
+		MaxUnused:                 c.MaxUnused,
+		RecordSeparator:           c.RecordSeparator,
+		StrictReachability:        c.StrictReachability,
+		ProjectImportPath:         c.ProjectImportPath,
+		CacheDir:                  c.CacheDir,
+		ShowName:                  c.ShowName,
+		ModMode:                   c.ModMode,
+		ModCacheDir:               c.ModCacheDir,
+		StrictSubpackages:         c.StrictSubpackages,
+		DirectOnly:                c.DirectOnly,
+		RequireVendor:             c.RequireVendor,
+		ReportShadowed:            c.ReportShadowed,
+		Quiet:                     c.Quiet,
+		ReportVendoredTestDeps:    c.ReportVendoredTestDeps,
+		ContinueOnWalkError:       c.ContinueOnWalkError,
+		HeaderTemplate:            c.HeaderTemplate,
+		FooterTemplate:            c.FooterTemplate,
+		CheckDirNames:             c.CheckDirNames,
+		FailOnMissingVendoring:    c.FailOnMissingVendoring,
+		GoVersion:                 c.GoVersion,
+		ReportRedundantIgnores:    c.ReportRedundantIgnores,
+		ReportStaleIgnores:        c.ReportStaleIgnores,
+		CaseInsensitive:           c.CaseInsensitive,
+		Format:                    c.Format,
 	}, nil
 }
 
@@ -51,34 +235,976 @@ type Param struct {
 	PkgRegexps                []*regexp.Regexp
 	IncludeVendorInImportPath bool
 	IgnorePkgs                []string
+	// ExcludeTestImports specifies whether imports in the test files of the project packages should be ignored. If true,
+	// vendored packages that are only imported by test files are reported as unused.
+	ExcludeTestImports bool
+	// ShowImporters specifies whether the project packages that import each used vendored package should be recorded
+	// in the result and printed.
+	ShowImporters bool
+	// Summary specifies whether a summary line is printed after the unused packages. The summary line starts with
+	// SummaryPrefix.
+	Summary bool
+	// ReportEmpty specifies whether directories in vendor directories that do not contain any Go files should be
+	// reported.
+	ReportEmpty bool
+	// CheckImportComments specifies whether vendored packages whose canonical import path comments do not match their
+	// vendored import paths should be reported as warnings.
+	CheckImportComments bool
+	// VendorDirName is the name of vendor directories. If empty, "vendor" is used.
+	VendorDirName string
+	// ReportWarnings specifies whether errors encountered while importing packages should be collected and returned as
+	// warnings in the result. The warnings are not written by Run: use Analyze and WriteResult to write the result and
+	// the warnings separately.
+	ReportWarnings bool
+	// RelativePaths specifies whether the paths of unused packages should be printed as paths relative to the project
+	// directory (for example, "vendor/github.com/org/library"). Only has an effect if IncludeVendorInImportPath is true.
+	RelativePaths bool
+	// CheckStdlibShadow specifies whether vendored packages whose first path element is the name of a standard library
+	// package (for example, "vendor/net/http") should be reported as warnings.
+	CheckStdlibShadow bool
+	// MaxDepth is the maximum depth of the import graph that is traversed when determining the packages imported by
+	// the project. The packages of the project have depth 0 and their direct imports have depth 1. Packages at the
+	// maximum depth are considered used, but their imports are not examined. If 0, the entire import graph is
+	// traversed. Limiting the depth may cause packages that are used transitively to be reported as unused.
+	MaxDepth int
+	// GroupByRepo specifies whether unused packages should be determined at the granularity of individual packages and
+	// then grouped using PkgRegexps when printed. If true, one line is printed for each repository that contains unused
+	// packages along with the number of unused packages in the repository. If false, unused packages are determined and
+	// printed at the granularity specified by PkgRegexps.
+	GroupByRepo bool
+	// WarnVendoredMain specifies whether vendored packages named "main" should be reported as warnings. Such packages
+	// cannot be imported, so if this is true they are reported separately and are not reported as unused.
+	WarnVendoredMain bool
+	// ExplainIgnores specifies whether the vendored packages that are used only by the packages in IgnorePkgs should be
+	// recorded in the result and printed. Such packages would be reported as unused if the packages were not ignored.
+	ExplainIgnores bool
+	// FollowSymlinks specifies whether symbolic links to directories in vendor directories (or vendor directories that
+	// are themselves symbolic links) should be followed when determining vendored packages. Packages in the linked
+	// directories are considered to be vendored at the path of the link. Directories that have already been visited are
+	// not visited again, so cycles of links are not followed.
+	FollowSymlinks bool
+	// AllowUnused are the import paths (not including the vendor directory) of vendored packages that are allowed to be
+	// unused. Unused packages that match one of the import paths or are subpackages of one of them are removed from the
+	// result. The import paths are also matched in the form normalized by PkgRegexps, so an entry for any package in a
+	// group (for example, a subpackage of a repository) allows the whole group to be unused. Unlike IgnorePkgs, the
+	// packages are not considered when determining the packages that are used.
+	AllowUnused []string
+	// OnlyBuildIgnored specifies whether the vendored packages that are used only by files that are excluded from the
+	// build by the default build context (for example, files with a "// +build ignore" constraint or files for other
+	// platforms) should be recorded in the result and printed. Requires determining the used packages a second time.
+	OnlyBuildIgnored bool
+	// SkipDirs are the base names of directories in vendor directories that are skipped when determining vendored
+	// packages. If nil, "testdata" and ".git" directories are skipped.
+	SkipDirs []string
+	// CgoEnabled overrides whether cgo is enabled in the build context used for the analysis. If false, the imports of
+	// files that import "C" are not considered. If nil, the default of the build context (which is determined by the
+	// CGO_ENABLED environment variable) is used.
+	CgoEnabled *bool
+	// Dedupe specifies whether identical import paths of unused packages in different vendor directories should be
+	// printed only once. Has no effect if IncludeVendorInImportPath is true because the paths are distinct.
+	Dedupe bool
+	// BuildTags are the build tags that are set in the build context used for the analysis. Because the analysis
+	// considers all files regardless of build constraints by default, the tags only have an effect when build
+	// constraints are evaluated (for example, when OnlyBuildIgnored is true).
+	BuildTags []string
+	// CollapseInternal specifies whether unused packages whose import path contains an "internal" element should be
+	// collapsed into the enclosing non-internal package. An unused internal package is removed from the result if the
+	// package that encloses its "internal" directory is also unused. Unused internal packages whose enclosing package is
+	// used are still reported.
+	CollapseInternal bool
+	// Platforms are the GOOS/GOARCH pairs for which the packages that are used are determined. If non-empty, the
+	// packages used by the project are determined separately for each platform using only the files that match the
+	// build constraints for the platform, and a vendored package is considered used if it is used on any of the
+	// platforms. If empty, the packages used by the project are determined using all files regardless of build
+	// constraints.
+	Platforms [][2]string
+	// AbsPaths specifies whether the absolute paths of the directories of unused packages should be printed instead of
+	// their import paths. Takes precedence over IncludeVendorInImportPath and RelativePaths.
+	AbsPaths bool
+	// IgnorePrefixes are import path prefixes of unused packages that should be removed from the result. An unused
+	// package is removed if its import path (not including the vendor directory) starts with one of the prefixes. Like
+	// AllowUnused, the prefixes do not affect the packages that are considered used.
+	IgnorePrefixes []string
+	// CheckVersionMismatch specifies whether packages that are vendored at the same import path in multiple vendor
+	// directories with different contents should be reported as warnings. The contents of a package are the names and
+	// contents of the Go files in its directory. Packages that are vendored with identical contents are not reported.
+	CheckVersionMismatch bool
+	// PerPkgContext maps the paths of packages (in the same form as the provided packages) to the build context
+	// overrides that are used when determining the imports of those packages. This allows packages in the same project
+	// that target different platforms to be analyzed together. A path must match the path of a package exactly: the
+	// override does not apply to its subpackages. The imports of a package with an override are determined using only
+	// the files that match the build constraints of the overridden context, and Platforms is not used for the package.
+	// Packages without an override use the global build context.
+	PerPkgContext map[string]BuildContextOverride
+	// AllowNestedVendor specifies whether vendor directories of the provided packages that are nested within another
+	// vendor directory of the provided packages should be analyzed separately. The packages in a nested vendor
+	// directory are also packages of the enclosing vendor directory, so by default nested vendor directories are
+	// skipped to avoid reporting the same unused package for both directories.
+	AllowNestedVendor bool
+	// Stats specifies whether the number of vendored packages and the number of unused packages in each vendor
+	// directory should be printed after the unused packages.
+	Stats bool
+	// TrackStdlib specifies whether the standard library packages that are imported by the project (directly, through
+	// vendored packages or through other standard library packages) should be recorded in Result.StdlibImports and
+	// printed after the unused packages. Does not affect the packages that are reported as unused.
+	TrackStdlib bool
+	// ReportBlankOnly specifies whether the vendored packages that are only imported using blank imports (for example,
+	// `import _ "github.com/org/driver"`) should be reported. Such packages are used only for their side effects, which
+	// can indicate a forgotten registration or a leftover import.
+	ReportBlankOnly bool
+	// GOPATH is the GOPATH used to resolve imports. If empty, the GOPATH of the default build context (which is
+	// determined by the GOPATH environment variable) is used. Setting this allows projects that are not in the ambient
+	// GOPATH to be analyzed. Vendored packages can only be resolved for projects that are in the GOPATH.
+	GOPATH string
+	// RetainWithFiles are the names of files (for example, "LICENSE" or "NOTICE") that cause the vendored packages whose
+	// directories contain them to be retained: such packages are never reported as unused. If PkgRegexps is used to
+	// group packages, the entire group of a retained package is retained.
+	RetainWithFiles []string
+	// ShowUsed specifies whether the vendored packages that are used should be printed after the unused packages. If
+	// ShowImporters is also true, the used packages are only printed with their importers.
+	ShowUsed bool
+	// MaxOpenFiles is the maximum number of directories in a vendor directory that are read concurrently when the
+	// packages in the vendor directory are determined. Reading a directory opens the directory and its Go files, so
+	// this bounds the number of files that are open at once. If less than or equal to 1, directories are read
+	// sequentially.
+	MaxOpenFiles int
+	// ReportUnbuildable specifies whether vendored packages that cannot be built on any platform should be reported as
+	// warnings. A package cannot be built on any platform if none of its non-test Go files match the build constraints
+	// of any of the platforms in UnbuildablePlatforms (with cgo enabled and the build tags in BuildTags). Such
+	// packages appear to be present when all files are considered, but can never be compiled.
+	ReportUnbuildable bool
+	// SortByVendorDir specifies whether the unused packages should be printed grouped by vendor directory. If true, the
+	// vendor directories are printed in sorted order, the unused packages in each vendor directory are sorted by import
+	// path and an empty record is printed between the groups. If false, the output for all vendor directories is sorted as
+	// a single list. Ignored if GroupByRepo is true.
+	SortByVendorDir bool
+	// Overlay is a map from file path to file contents. The contents of the files in the overlay are used instead of the
+	// contents of the files on disk when determining imports, and files in the overlay that do not exist on disk are
+	// treated as if they existed. This allows the analysis to consider unsaved edits. Relative paths are resolved
+	// against the working directory. Files in the overlay must be in directories that exist on disk.
+	Overlay map[string][]byte
+	// ReportNotVendored specifies whether the imports of project packages that are not vendored (imports of
+	// non-standard library packages that do not resolve to a package in a vendor directory or in the project) should be
+	// reported along with the file and line of each import statement.
+	ReportNotVendored bool
+	// MaxUnused is the maximum number of unused vendored packages that are allowed. If non-nil and the number of unused
+	// vendored packages exceeds it, the Run functions return an error with the cause ErrUnusedPkgs after all of the
+	// output (including all of the unused packages) has been written. This allows the number of unused packages in a
+	// project to be reduced gradually without allowing new unused packages. If nil, the Run functions do not return an
+	// error because of unused packages.
+	MaxUnused *int
+	// RecordSeparator is the string written after each unused package when the format is FormatText. If empty, "\n" is
+	// used. Setting it to "\x00" allows the output to be consumed safely by tools such as "xargs -0". All other output
+	// (such as warnings and the summary) is still written as newline-terminated lines.
+	RecordSeparator string
+	// StrictReachability specifies whether vendored packages are only considered used if they are reachable from the
+	// project packages. If true, the provided packages that are themselves in a vendor directory are not used as roots
+	// of the analysis, so the packages that are imported only by such packages (and the packages themselves) are
+	// reported as unused unless a project package imports them. If false, every provided package is treated as a
+	// project package.
+	StrictReachability bool
+	// ProjectImportPath is the import path of the project (for example, "github.com/org/project"). If set, the
+	// project's own packages are determined based on their import paths rather than on whether their directories are
+	// within the project directory, and vendored copies of the project's own packages (which can occur if a
+	// dependency that imports the project is vendored) are excluded from the vendored packages and are never reported
+	// as unused.
+	ProjectImportPath string
+	// CacheDir is the directory in which the packages determined for each vendor directory are cached between runs. If
+	// set, the packages of a vendor directory are loaded from the cache if none of the files in the vendor directory
+	// have changed (based on their paths, sizes, modes and modification times) since the cache was written. Only the
+	// scan of the vendor directories is cached: the imports of the analyzed packages are always determined. If empty,
+	// no cache is used.
+	CacheDir string
+	// ShowName specifies whether the names declared by the unused vendored packages should be printed after their
+	// import paths in the form " (package <name>)". If a directory contains multiple packages, all of their names are
+	// printed. Has no effect if GroupByRepo is true.
+	ShowName bool
+	// ModMode specifies how imports of packages provided by the modules required by the go.mod file of the project are
+	// resolved, analogous to the "-mod" flag of the go command. If empty, ModVendor is used.
+	ModMode ModMode
+	// ModCacheDir is the module cache used to resolve imports when ModMode is ModReadonly or ModMod. If empty, the
+	// directory specified by the GOMODCACHE environment variable is used or, if it is not set, the "pkg/mod" directory
+	// in the first entry of the GOPATH.
+	ModCacheDir string
+	// StrictSubpackages specifies whether packages are considered used individually rather than by the groups
+	// determined by PkgRegexps. If true, a vendored package that is not imported is reported as unused even if other
+	// packages in its group (for example, its subpackages) are used. Groups in which every package is unused are still
+	// reported as a single group. Has no effect if GroupByRepo is true (GroupByRepo already determines unused packages
+	// individually).
+	StrictSubpackages bool
+	// DirectOnly specifies whether only the direct imports of the analyzed packages are considered used. If true, the
+	// imports of the imported packages are not examined (equivalent to a MaxDepth of 1, and takes precedence over
+	// MaxDepth). Vendored packages that are only imported transitively are reported as unused.
+	DirectOnly bool
+	// RequireVendor specifies whether the analysis should fail if none of the analyzed packages have a vendor directory.
+	// If true, an error whose cause is ErrNoVendorDir is returned in that case. If false, a project without vendor
+	// directories is treated as a project without unused vendored packages.
+	RequireVendor bool
+	// FailOnMissingVendoring specifies whether the analysis should fail if the analyzed packages import packages that
+	// are outside of the project (and are not provided by required modules) but no packages are vendored in any of the
+	// vendor directories. If true, an error whose cause is ErrMissingVendoring is returned in that case. This usually
+	// indicates that the analysis was run on the wrong directory or that the dependencies of the project have not
+	// been vendored.
+	FailOnMissingVendoring bool
+	// GoVersion is the Go version (for example, "1.18" or "go1.18") whose release tags are set in the build context
+	// used for the analysis. If empty, the release tags of the toolchain that performs the analysis are used. Like
+	// BuildTags, the release tags only have an effect when build constraints are evaluated.
+	GoVersion string
+	// MaxConcurrentProjects is the maximum number of projects that RunMulti analyzes concurrently. If less than or
+	// equal to 1, the projects are analyzed one at a time. Has no effect on the other functions.
+	MaxConcurrentProjects int
+	// ReportRedundantIgnores specifies whether the entries of IgnorePkgs that refer to vendored packages that are used by
+	// the project packages should be recorded in the result and printed as warnings. Ignoring such packages has no
+	// effect because they would not be reported as unused.
+	ReportRedundantIgnores bool
+	// ReportStaleIgnores specifies whether the entries of IgnorePkgs whose directories do not exist or do not contain a
+	// Go package should be recorded in the result and printed as warnings. Such entries usually refer to vendored
+	// packages that have since been removed and can be deleted.
+	ReportStaleIgnores bool
+	// CaseInsensitive specifies whether import paths should be matched against the vendored packages without regard to
+	// case. On case-insensitive filesystems (the default on macOS and Windows), an import of "github.com/org/lib" can
+	// resolve to a vendored directory named "Github.com/Org/Lib", which would otherwise be reported as unused. If nil,
+	// whether the filesystem that contains the project directory is case-insensitive is detected automatically.
+	CaseInsensitive *bool
+	// ReportShadowed specifies whether packages that are imported from outside of a vendor directory (for example,
+	// from the GOPATH) while also being vendored in one of the analyzed vendor directories should be reported as
+	// warnings. Such packages are built twice from different sources, which usually indicates a vendoring mistake.
+	ReportShadowed bool
+	// Quiet specifies whether the Run functions should only write output if there are unused packages. If true and
+	// there are no unused packages, nothing (including warnings and the summary) is written. If true and there are
+	// unused packages, the output is written as usual and an error with the cause ErrUnusedPkgs is returned.
+	Quiet bool
+	// ReportVendoredTestDeps specifies whether the unused vendored packages that are only reachable through the test
+	// files of vendored packages used by the project should be reported. The imports of the test files of vendored
+	// packages are never considered when determining the packages that are used, so these packages are still reported
+	// as unused: the report is informational and can be used to audit why such packages were vendored.
+	ReportVendoredTestDeps bool
+	// ContinueOnWalkError specifies whether directories in vendor directories that cannot be read (for example, because
+	// of insufficient permissions) should be skipped rather than causing the analysis to fail. Skipped directories are
+	// returned in the result. Packages in skipped directories are not considered, so the result may be incomplete.
+	ContinueOnWalkError bool
+	// HeaderTemplate is a text/template template that is rendered before the unused packages by the Run functions if
+	// the format is FormatText. The template is executed with a ReportTemplateData. If empty, nothing is rendered.
+	HeaderTemplate string
+	// FooterTemplate is a text/template template that is rendered after all other output by the Run functions if the
+	// format is FormatText. The template is executed with a ReportTemplateData. If empty, nothing is rendered.
+	FooterTemplate string
+	// CheckDirNames specifies whether vendored packages whose directory names do not match the last element of their
+	// import paths (either the import path at which they are vendored or the canonical import path declared by their
+	// import comments) should be reported as warnings. Such a mismatch usually indicates that the directory of the
+	// package was renamed manually, in which case imports of the package will not resolve to it.
+	CheckDirNames bool
+	// Format is the format in which results are written. If empty, FormatText is used. If FormatDOT, the import graph
+	// of the project is recorded in the result.
+	Format Format
+	// Logger is used to log the steps of the analysis (the vendor directories that are scanned, the imports that are
+	// resolved, the packages that are examined and the vendored packages that are determined to be used). If nil,
+	// nothing is logged.
+	Logger *log.Logger
+	// ProgressFn is called after each vendor directory is processed with the number of vendor directories that have
+	// been processed and the total number of vendor directories. If nil, progress is not reported.
+	ProgressFn func(examined, total int)
+	// MetricsFn is called after each phase of the analysis (and after the result is written by the Run functions) with
+	// the name of the phase and the time it took. The phases are PhaseScanVendorDirs, PhaseCollectImports and
+	// PhaseWriteResult. If CacheDir is set, PhaseLoadVendorCache is also reported for each vendor directory whose packages
+	// are loaded from the cache. If nil, metrics are not reported.
+	MetricsFn func(phase string, d time.Duration)
+	// OnUnused is called for each unused vendored package once the unused packages are determined and before any output
+	// is written. It is called with the import path of the package without the vendor directory, the path of the
+	// vendor directory that contains the package and the full import path of the package (including the vendor
+	// directory). Vendor directories and the packages within them are reported in sorted order. If nil, it is not
+	// called.
+	OnUnused func(importPath, vendorDir, fullPath string)
+
+	// collectImports specifies whether the import graph should be collected even if Format is not FormatDOT.
+	collectImports bool
+	// jsonlProject specifies whether the JSON lines written when the format is FormatJSONL should include the
+	// project directory. Set by RunMulti so that the lines of different projects can be distinguished.
+	jsonlProject bool
+	// displayDir is the directory relative to which the paths of packages and directories are written in the output.
+	// If empty, the paths are written as absolute paths. Set by RunArchive so that the paths in the output are relative
+	// to the root of the archive rather than the temporary directory into which it is extracted.
+	displayDir string
+}
+
+const (
+	// PhaseScanVendorDirs is the phase in which the packages in the vendor directories are determined.
+	PhaseScanVendorDirs = "scan-vendor-dirs"
+	// PhaseCollectImports is the phase in which the imports of the analyzed packages are determined.
+	PhaseCollectImports = "collect-imports"
+	// PhaseWriteResult is the phase in which the result is written.
+	PhaseWriteResult = "write-result"
+	// PhaseLoadVendorCache is the phase in which the packages of a vendor directory are loaded from the cache. It is
+	// reported once for each vendor directory whose packages are loaded from the cache.
+	PhaseLoadVendorCache = "load-vendor-cache"
+)
+
+// reportMetric calls MetricsFn (if it is non-nil) with the provided phase and the time elapsed since start.
+func (p Param) reportMetric(phase string, start time.Time) {
+	if p.MetricsFn != nil {
+		p.MetricsFn(phase, time.Since(start))
+	}
+}
+
+// reportUnused calls OnUnused (if it is non-nil) for each of the provided unused import paths in the provided vendor
+// directory.
+func (p Param) reportUnused(vendorDir string, importPaths []string) {
+	if p.OnUnused == nil {
+		return
+	}
+	for _, importPath := range importPaths {
+		p.OnUnused(outputImportPath(importPath, false, p.vendorDirName()), vendorDir, toSlashImportPath(importPath))
+	}
+}
+
+func (p Param) recordSeparator() string {
+	if p.RecordSeparator == "" {
+		return "\n"
+	}
+	return p.RecordSeparator
+}
+
+func (p Param) maxDepth() int {
+	if p.DirectOnly {
+		return 1
+	}
+	return p.MaxDepth
+}
+
+func (p Param) modMode() ModMode {
+	if p.ModMode == "" {
+		return ModVendor
+	}
+	return p.ModMode
+}
+
+// modCacheDir returns the module cache directory for the provided GOPATH.
+func (p Param) modCacheDir(gopath string) string {
+	if p.ModCacheDir != "" {
+		return p.ModCacheDir
+	}
+	if modCacheDir := os.Getenv("GOMODCACHE"); modCacheDir != "" {
+		return modCacheDir
+	}
+	return filepath.Join(filepath.SplitList(gopath)[0], "pkg", "mod")
+}
+
+// caseInsensitive returns whether import paths should be matched against vendored packages without regard to case for
+// the project in the provided directory.
+func (p Param) caseInsensitive(projectDir string) bool {
+	if p.CaseInsensitive != nil {
+		return *p.CaseInsensitive
+	}
+	return isCaseInsensitiveFS(projectDir)
+}
+
+func (p Param) vendorDirName() string {
+	if p.VendorDirName == "" {
+		return "vendor"
+	}
+	return p.VendorDirName
+}
+
+func (p Param) skipDirs() map[string]struct{} {
+	skipDirs := p.SkipDirs
+	if skipDirs == nil {
+		skipDirs = []string{"testdata", ".git"}
+	}
+	out := make(map[string]struct{})
+	for _, dir := range skipDirs {
+		out[dir] = struct{}{}
+	}
+	return out
+}
+
+// BuildContextOverride specifies values that override the build context used to determine the imports of a package.
+// Empty values do not override the corresponding values of the build context.
+type BuildContextOverride struct {
+	GOOS      string   `json:"goos" yaml:"goos"`
+	GOARCH    string   `json:"goarch" yaml:"goarch"`
+	BuildTags []string `json:"buildTags" yaml:"buildTags"`
+}
+
+// Format is a format in which results can be written.
+type Format string
+
+const (
+	// FormatText writes the unused packages one per line, followed by any additional information specified by the
+	// parameters.
+	FormatText Format = "text"
+	// FormatDOT writes a Graphviz DOT graph in which the nodes are packages and the edges are imports. Used vendored
+	// packages are colored green and unused vendored packages are colored red.
+	FormatDOT Format = "dot"
+	// FormatJSONL writes one JSON object per line for each unused package (or for each repository that contains unused
+	// packages, if GroupByRepo is true), sorted by vendor directory and then by package. Only the JSON lines are written
+	// to the output: Run does not write diagnostics such as warnings and RunWithWriters writes them to its errOut.
+	FormatJSONL Format = "jsonl"
+	// FormatGitHubActions writes a GitHub Actions workflow command for each unused package so that the unused packages
+	// are shown as annotations in the GitHub Actions UI. The file of each annotation is the directory of the package
+	// relative to the project directory. The annotations are errors if the unused packages cause the Run functions to
+	// return an error (for example, because Param.MaxUnused is exceeded) and warnings otherwise. No other output is
+	// written.
+	FormatGitHubActions Format = "github-actions"
+)
+
+// ModMode is a mode in which imports of packages provided by modules are resolved.
+type ModMode string
+
+const (
+	// ModVendor resolves imports using the vendor directories of the project. The modules required by the go.mod file
+	// of the project are not considered. If the project has a go.mod file and its vendor directory has a "modules.txt"
+	// file (as created by "go mod vendor"), imports are resolved using module semantics: imports of packages of the
+	// main module are resolved in the project directory, imports of packages of the modules listed in "modules.txt" are
+	// resolved in the vendor directory of the project and vendor directories other than the one of the project are
+	// ignored. In this case, the project does not need to be in a GOPATH.
+	ModVendor ModMode = "vendor"
+	// ModReadonly resolves imports of packages provided by the modules required by the go.mod file of the project
+	// against the required versions of the modules in the module cache rather than the vendor directory. The vendored
+	// packages whose import paths are not imported (directly or transitively) by the project when imports are resolved
+	// in this manner are unused. The analysis fails with an error whose cause is ErrNoRequiredModule if a package is
+	// imported that is not provided by the project, the standard library or any of the required modules.
+	ModReadonly ModMode = "readonly"
+	// ModMod is like ModReadonly, but imports of packages that are not provided by the project, the standard library
+	// or any of the required modules are ignored (the go command would add requirements for them to the go.mod file).
+	ModMod ModMode = "mod"
+)
+
+// Result is the result of analyzing the vendored packages of a project.
+type Result struct {
+	// ProjectDir is the absolute path of the project directory that was analyzed.
+	ProjectDir string
+	// UnusedPkgs maps the path of each vendor directory that was analyzed to the sorted import paths of the unused
+	// packages in that directory. The import paths include the vendor directory.
+	UnusedPkgs map[string][]string
+	// UnusedPkgDirs maps the import path (including the vendor directory) of each package in UnusedPkgs to the
+	// directory of the package.
+	UnusedPkgDirs map[string]string
+	// GroupKeys maps the import path (including the vendor directory) of each package in UnusedPkgs to the import path
+	// (without the vendor directory) of the group of the package as determined by Param.PkgRegexps: the portion of the
+	// import path matched by the regular expressions or, if none match, the import path itself.
+	GroupKeys map[string]string
+	// Importers maps the import path (including the vendor directory) of each vendored package that is used to the
+	// sorted import paths of the project packages that import it. Only populated if Param.ShowImporters is true.
+	Importers map[string][]string
+	// EmptyDirs maps the path of each vendor directory that was analyzed to the sorted paths of the directories within
+	// it that do not contain any Go files. Only populated if Param.ReportEmpty is true.
+	EmptyDirs map[string][]string
+	// ImportCommentMismatches are the vendored packages whose canonical import path comment does not match the import
+	// path at which they are vendored. Only populated if Param.CheckImportComments is true.
+	ImportCommentMismatches []ImportCommentMismatch
+	// DirNameMismatches are the vendored packages whose directory names do not match their import paths, sorted by
+	// directory. Only populated if Param.CheckDirNames is true.
+	DirNameMismatches []DirNameMismatch
+	// Warnings are the errors that were encountered while importing packages (for example, because a directory contains
+	// malformed Go files), sorted by directory. Packages that could not be imported may cause the result to be
+	// incomplete. Only populated if Param.ReportWarnings is true.
+	Warnings []Warning
+	// SkippedDirs are the directories in vendor directories that could not be read and were skipped, sorted by
+	// directory. Only populated if Param.ContinueOnWalkError is true.
+	SkippedDirs []Warning
+	// StdlibShadows are the sorted import paths (including the vendor directory) of the vendored packages whose first
+	// path element is the name of a standard library package. Only populated if Param.CheckStdlibShadow is true.
+	StdlibShadows []string
+	// UnbuildablePkgs are the sorted import paths (including the vendor directory) of the vendored packages that cannot
+	// be built on any platform. Only populated if Param.ReportUnbuildable is true.
+	UnbuildablePkgs []string
+	// VendoredMainPkgs are the sorted import paths (including the vendor directory) of the vendored packages named
+	// "main". Only populated if Param.WarnVendoredMain is true.
+	VendoredMainPkgs []string
+	// UsedOnlyByIgnoredPkgs are the sorted import paths (including the vendor directory) of the vendored packages that
+	// are used only by the packages in Param.IgnorePkgs. Only populated if Param.ExplainIgnores is true.
+	UsedOnlyByIgnoredPkgs []string
+	// RedundantIgnores are the sorted entries of Param.IgnorePkgs that refer to vendored packages that are used by the
+	// analyzed packages. Ignoring such packages has no effect because they would not be reported as unused. Only
+	// populated if Param.ReportRedundantIgnores is true.
+	RedundantIgnores []string
+	// StaleIgnores are the sorted entries of Param.IgnorePkgs whose directories do not exist or do not contain a Go
+	// package. Only populated if Param.ReportStaleIgnores is true.
+	StaleIgnores []string
+	// BlankOnlyPkgs are the sorted import paths (including the vendor directory) of the vendored packages that are only
+	// imported using blank imports. Only populated if Param.ReportBlankOnly is true.
+	BlankOnlyPkgs []string
+	// OnlyBuildIgnoredPkgs are the sorted import paths (including the vendor directory) of the vendored packages that
+	// are used only by files that are excluded from the build by the default build context. Only populated if
+	// Param.OnlyBuildIgnored is true.
+	OnlyBuildIgnoredPkgs []string
+	// VersionMismatches are the packages that are vendored at the same import path in multiple vendor directories with
+	// different contents, sorted by import path. Only populated if Param.CheckVersionMismatch is true.
+	VersionMismatches []VersionMismatch
+	// StdlibImports are the sorted import paths of the standard library packages that are imported by the project.
+	// Only populated if Param.TrackStdlib is true.
+	StdlibImports []string
+	// NotVendoredImports are the imports of project packages that are not vendored, sorted by import path. Only
+	// populated if Param.ReportNotVendored is true.
+	NotVendoredImports []NotVendoredImport
+	// ShadowedPkgs are the packages that are imported from outside of a vendor directory while also being vendored in
+	// one of the analyzed vendor directories, sorted by import path. Only populated if Param.ReportShadowed is true.
+	ShadowedPkgs []ShadowedPkg
+	// VendoredTestDeps are the sorted import paths (including the vendor directory) of the unused vendored packages
+	// that are reachable through the test files of vendored packages used by the project. Only populated if
+	// Param.ReportVendoredTestDeps is true.
+	VendoredTestDeps []string
+	// PkgNames maps the import path (including the vendor directory) of each vendored package to the sorted names
+	// declared by the packages in its directory. Only populated if Param.ShowName is true.
+	PkgNames map[string][]string
+	// UsedVendored maps the path of each vendor directory that was analyzed to the sorted import paths of the packages
+	// in that directory that are used. The import paths include the vendor directory.
+	UsedVendored map[string][]string
+	// Stats maps the path of each vendor directory that was analyzed to the number of packages in that directory.
+	Stats map[string]VendorDirStats
+	// Imports maps the import path of each non-standard library package that was examined to the sorted import paths
+	// of the non-standard library packages that it imports. Only populated if Param.Format is FormatDOT.
+	Imports map[string][]string
+}
+
+// Warning is an error that was encountered while importing the package in a directory. Warnings in a Result have an Err
+// of type *PackageParseError.
+type Warning struct {
+	Dir string
+	Err error
+}
+
+func (w Warning) String() string {
+	if parseErr, ok := w.Err.(*PackageParseError); ok && parseErr.Dir == w.Dir {
+		return parseErr.Error()
+	}
+	return fmt.Sprintf("%s: %v", w.Dir, w.Err)
+}
+
+// VendorDirStats are the package counts of a vendor directory.
+type VendorDirStats struct {
+	// TotalVendored is the number of packages in the vendor directory.
+	TotalVendored int
+	// TotalUnused is the number of packages in the vendor directory that are not used. Unlike Result.UnusedPkgs, this
+	// includes packages that are removed from the result by Param.AllowUnused, Param.IgnorePrefixes and
+	// Param.CollapseInternal.
+	TotalUnused int
+}
+
+// VersionMismatch describes a package that is vendored at the same import path in multiple vendor directories with
+// different contents. This usually indicates that nested vendor directories contain incompatible versions of a package.
+type VersionMismatch struct {
+	// ImportPath is the import path of the vendored package (not including the vendor directory).
+	ImportPath string
+	// Dirs are the sorted directories in which the package is vendored.
+	Dirs []string
+}
+
+// ShadowedPkg describes a package that is imported from outside of a vendor directory while also being vendored.
+type ShadowedPkg struct {
+	// ImportPath is the import path of the package.
+	ImportPath string
+	// Dir is the directory from which the non-vendored package is imported.
+	Dir string
+	// VendorDirs are the sorted vendor directories in which the package is also vendored.
+	VendorDirs []string
+}
+
+// NotVendoredImport describes an import of a project package that is not vendored.
+type NotVendoredImport struct {
+	// ImportPath is the import path of the import.
+	ImportPath string
+	// Positions are the locations of the import statements for the import in the form "file:line", where the file is
+	// relative to the project directory. Sorted by file and then by line.
+	Positions []string
+}
+
+// ImportCommentMismatch describes a vendored package whose canonical import path comment (for example,
+// `package foo // import "github.com/org/foo"`) does not match the import path at which it is vendored. This usually
+// indicates that the package was vendored incorrectly.
+type ImportCommentMismatch struct {
+	// Dir is the directory of the vendored package.
+	Dir string
+	// ImportPath is the import path of the vendored package (not including the vendor directory).
+	ImportPath string
+	// ImportComment is the canonical import path declared by the package.
+	ImportComment string
+}
+
+// DirNameMismatch describes a vendored package whose directory name does not match the last element of its import
+// path. This usually indicates that the directory of the package was renamed manually.
+type DirNameMismatch struct {
+	// Dir is the directory of the vendored package.
+	Dir string
+	// ImportPath is the import path of the vendored package (not including the vendor directory).
+	ImportPath string
+	// ExpectedImportPath is the import path whose last element does not match the name of the directory: either the
+	// import path of the package or the canonical import path declared by its import comment.
+	ExpectedImportPath string
 }
 
 func Run(projectDir string, pkgs []string, param Param, w io.Writer) error {
-	unusedPkgs, err := unusedVendoredPackages(projectDir, pkgs, param.IgnorePkgs, param.PkgRegexps)
+	return RunContext(context.Background(), projectDir, pkgs, param, w)
+}
+
+// RunToFile is like Run, but writes the output to the file at the provided path. The file is created if it does not
+// exist and truncated if it does. The output is buffered and the file is flushed and closed before this function
+// returns. If the analysis fails, the file is removed so that partial output is never left behind. If the only error is
+// that the number of unused packages exceeds Param.MaxUnused (the cause of the error is ErrUnusedPkgs), the output is
+// complete and the file is retained.
+func RunToFile(projectDir string, pkgs []string, param Param, outputPath string) (rErr error) {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create output file %s", outputPath)
+	}
+	defer func() {
+		if err := f.Close(); err != nil && rErr == nil {
+			rErr = errors.Wrapf(err, "failed to close output file %s", outputPath)
+		}
+		if rErr != nil && errors.Cause(rErr) != ErrUnusedPkgs {
+			_ = os.Remove(outputPath)
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	runErr := Run(projectDir, pkgs, param, w)
+	if err := w.Flush(); err != nil {
+		return errors.Wrapf(err, "failed to write output file %s", outputPath)
+	}
+	return runErr
+}
+
+// RunContext is like Run, but returns the error of the provided context if the context is cancelled before the
+// analysis completes.
+func RunContext(ctx context.Context, projectDir string, pkgs []string, param Param, w io.Writer) error {
+	errOut := w
+	if param.Format == FormatJSONL {
+		// diagnostics would make the output invalid JSON lines
+		errOut = ioutil.Discard
+	}
+	return runWithWriters(ctx, projectDir, pkgs, param, w, errOut)
+}
+
+// RunWithWriters is like Run, but writes only the unused packages (or the graph, if the format is FormatDOT) to out and
+// writes all other output (such as warnings, the packages that are used, empty directories and the summary) to errOut.
+// Warnings encountered while importing packages are also written to errOut. This ensures that the output written to
+// out can be safely consumed by other tools.
+func RunWithWriters(projectDir string, pkgs []string, param Param, out, errOut io.Writer) error {
+	return RunWithWritersContext(context.Background(), projectDir, pkgs, param, out, errOut)
+}
+
+// RunWithWritersContext is like RunWithWriters, but returns the error of the provided context if the context is
+// cancelled before the analysis completes.
+func RunWithWritersContext(ctx context.Context, projectDir string, pkgs []string, param Param, out, errOut io.Writer) error {
+	return runWithWriters(ctx, projectDir, pkgs, param, out, errOut)
+}
+
+// runWithWriters analyzes the provided packages and writes the unused packages (or the graph, if the format is
+// FormatDOT) to out and all other output, including the warnings and skipped directories in the result, to errOut.
+func runWithWriters(ctx context.Context, projectDir string, pkgs []string, param Param, out, errOut io.Writer) error {
+	result, err := AnalyzeContext(ctx, projectDir, pkgs, param)
+	if err != nil {
+		return err
+	}
+	numUnused := numUnusedPkgs(result)
+	if param.Quiet && numUnused == 0 {
+		return nil
+	}
+	header, footer, err := renderReportTemplates(result, param)
 	if err != nil {
 		return err
 	}
+	writeStart := time.Now()
+	fmt.Fprint(out, header)
+	if err := writeResult(result, param, out, errOut); err != nil {
+		return err
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(errOut, "warning: %v\n", warning)
+	}
+	for _, skipped := range result.SkippedDirs {
+		fmt.Fprintf(errOut, "warning: skipped directory %v\n", skipped)
+	}
+	fmt.Fprint(out, footer)
+	param.reportMetric(PhaseWriteResult, writeStart)
+	return checkUnused(numUnused, param)
+}
 
-	var out []string
-	for _, v := range unusedPkgs {
-		out = append(out, sortedVals(v)...)
+// Check analyzes the provided packages and returns an error with the cause ErrUnusedPkgs if there are any unused vendored
+// packages. Nothing is written: this is intended for callers that only need to know whether all vendored packages are
+// used.
+func Check(projectDir string, pkgs []string, param Param) error {
+	result, err := Analyze(projectDir, pkgs, param)
+	if err != nil {
+		return err
+	}
+	if numUnused := numUnusedPkgs(result); numUnused > 0 {
+		return errors.Wrapf(ErrUnusedPkgs, "%d unused vendored package(s)", numUnused)
+	}
+	return nil
+}
+
+// numUnusedPkgs returns the total number of unused packages across all of the vendor directories in the provided
+// result.
+func numUnusedPkgs(result *Result) int {
+	numUnused := 0
+	for _, unused := range result.UnusedPkgs {
+		numUnused += len(unused)
+	}
+	return numUnused
+}
+
+// checkUnused returns an error with the cause ErrUnusedPkgs if param.Quiet is true and there are unused packages or if
+// the provided number of unused packages exceeds param.MaxUnused (as determined by checkMaxUnused).
+func checkUnused(numUnused int, param Param) error {
+	if param.Quiet && numUnused > 0 {
+		return errors.Wrapf(ErrUnusedPkgs, "%d unused vendored package(s)", numUnused)
+	}
+	return checkMaxUnused(numUnused, param)
+}
+
+// checkMaxUnused returns an error with the cause ErrUnusedPkgs if param.MaxUnused is non-nil and the provided number of
+// unused packages exceeds it.
+func checkMaxUnused(numUnused int, param Param) error {
+	if param.MaxUnused != nil && numUnused > *param.MaxUnused {
+		return errors.Wrapf(ErrUnusedPkgs, "%d unused vendored package(s) exceeds the maximum of %d", numUnused, *param.MaxUnused)
+	}
+	return nil
+}
+
+// Analyze determines the vendored packages in the vendor directories of the provided packages that are not used by the
+// provided packages and returns the result. Packages may be specified as directories or, like the arguments of the Go
+// tooling, as import paths (for example, "github.com/org/repo/cmd/tool" or "github.com/org/repo/...").
+func Analyze(projectDir string, pkgs []string, param Param) (*Result, error) {
+	return AnalyzeContext(context.Background(), projectDir, pkgs, param)
+}
+
+// AnalyzeContext is like Analyze, but returns the error of the provided context if the context is cancelled before the
+// analysis completes.
+func AnalyzeContext(ctx context.Context, projectDir string, pkgs []string, param Param) (*Result, error) {
+	analysis, err := unusedVendoredPackages(ctx, projectDir, pkgs, param)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		UnusedPkgs:              make(map[string][]string),
+		UnusedPkgDirs:           make(map[string]string),
+		GroupKeys:               make(map[string]string),
+		Stats:                   make(map[string]VendorDirStats),
+		UsedVendored:            make(map[string][]string),
+		StdlibImports:           analysis.stdlibImports,
+		NotVendoredImports:      analysis.notVendoredImports,
+		EmptyDirs:               analysis.emptyDirs,
+		ImportCommentMismatches: analysis.importCommentMismatches,
+		DirNameMismatches:       analysis.dirNameMismatches,
+		Warnings:                analysis.warnings,
+		SkippedDirs:             analysis.skippedDirs,
+		ProjectDir:              analysis.projectDir,
+		StdlibShadows:           analysis.stdlibShadows,
+		VendoredMainPkgs:        analysis.vendoredMainPkgs,
+		UnbuildablePkgs:         analysis.unbuildablePkgs,
+		UsedOnlyByIgnoredPkgs:   analysis.usedOnlyByIgnoredPkgs,
+		BlankOnlyPkgs:           analysis.blankOnlyPkgs,
+		RedundantIgnores:        analysis.redundantIgnores,
+		StaleIgnores:            analysis.staleIgnores,
+		ShadowedPkgs:            analysis.shadowedPkgs,
+		VendoredTestDeps:        analysis.vendoredTestDeps,
+		VersionMismatches:       analysis.versionMismatches,
+		OnlyBuildIgnoredPkgs:    analysis.onlyBuildIgnoredPkgs,
+	}
+	if analysis.pkgNames != nil {
+		result.PkgNames = make(map[string][]string)
+		for pkg, v := range analysis.pkgNames {
+			result.PkgNames[pkg] = sortedVals(v)
+		}
+	}
+	for vendorDir, v := range analysis.unused {
+		result.Stats[vendorDir] = VendorDirStats{
+			TotalVendored: len(analysis.vendored[vendorDir]),
+			TotalUnused:   len(v),
+		}
+		filterUnused(v, param)
+		result.UnusedPkgs[vendorDir] = sortedVals(v)
+		result.UsedVendored[vendorDir] = sortedVals(analysis.used[vendorDir])
+		for _, pkg := range result.UnusedPkgs[vendorDir] {
+			importPath := outputImportPath(pkg, false, param.vendorDirName())
+			result.UnusedPkgDirs[pkg] = filepath.Join(vendorDir, filepath.FromSlash(importPath))
+			result.GroupKeys[pkg] = outputImportPath(transformImportPath(pkg, param.PkgRegexps, param.vendorDirName()), false, param.vendorDirName())
+		}
+	}
+	for _, vendorDir := range sortedKeys(result.UnusedPkgs) {
+		param.reportUnused(vendorDir, result.UnusedPkgs[vendorDir])
+	}
+	if analysis.importers != nil {
+		result.Importers = make(map[string][]string)
+		for pkg, v := range analysis.importers {
+			result.Importers[pkg] = sortedVals(v)
+		}
+	}
+	if analysis.imports != nil {
+		result.Imports = make(map[string][]string)
+		for pkg, v := range analysis.imports {
+			result.Imports[pkg] = sortedVals(v)
+		}
+	}
+	return result, nil
+}
+
+// IsVendoredPackageUsed returns true if the vendored package with the provided import path is used by the provided
+// packages. The import path may either include the vendor directory (in which case only the package in that vendor
+// directory is considered) or not include it (in which case the package is considered used if it is used in any of the
+// vendor directories). The import path is normalized using param.PkgRegexps in the same manner as the vendored
+// packages, so the normalized form of the import path (for example, the repository of the package) may also be
+// provided. Returns an error if the import path does not match any vendored package.
+func IsVendoredPackageUsed(projectDir string, pkgs []string, vendoredImportPath string, param Param) (bool, error) {
+	// grouping only affects output, but it also disables normalization during the analysis
+	param.GroupByRepo = false
+	analysis, err := unusedVendoredPackages(context.Background(), projectDir, pkgs, param)
+	if err != nil {
+		return false, err
 	}
 
-	if !param.IncludeVendorInImportPath {
-		for i, importPath := range out {
-			vendorIdx := strings.LastIndex(importPath, "/vendor/")
-			if vendorIdx == -1 {
+	normalizedPath := transformImportPath(vendoredImportPath, param.PkgRegexps, param.vendorDirName())
+	found := false
+	for vendorDir, vendored := range analysis.vendored {
+		for pkg := range vendored {
+			if pkg != normalizedPath && outputImportPath(pkg, false, param.vendorDirName()) != normalizedPath {
 				continue
 			}
-			out[i] = importPath[vendorIdx+len("/vendor/"):]
+			found = true
+			if _, ok := analysis.unused[vendorDir][pkg]; !ok {
+				return true, nil
+			}
 		}
 	}
-	sort.Strings(out)
+	if !found {
+		return false, errors.Errorf("%s is not a vendored package", vendoredImportPath)
+	}
+	return false, nil
+}
 
-	for _, pkg := range out {
-		fmt.Fprintln(w, pkg)
+// filterUnused removes the packages that should not be reported from the provided set of unused import paths (including
+// the vendor directory) of a single vendor directory.
+func filterUnused(unused map[string]struct{}, param Param) {
+	for pkg := range unused {
+		if isAllowedUnused(pkg, param) || hasIgnoredPrefix(pkg, param) {
+			delete(unused, pkg)
+		}
 	}
-	return nil
+	if param.CollapseInternal {
+		for pkg := range unused {
+			enclosingPkg := enclosingNonInternalPkg(pkg, param.vendorDirName())
+			if _, ok := unused[enclosingPkg]; ok && enclosingPkg != pkg {
+				delete(unused, pkg)
+			}
+		}
+	}
+}
+
+// isAllowedUnused returns true if the provided import path (including the vendor directory) matches one of the import
+// paths in param.AllowUnused or is a subpackage of one of them. Both the import path and the allowed import paths are
+// also compared in the form normalized by param.PkgRegexps, so an entry for any package of a group allows the unused
+// package for the whole group (and an entry for a group allows all of the packages in it).
+func isAllowedUnused(importPath string, param Param) bool {
+	importPath = outputImportPath(importPath, false, param.vendorDirName())
+	importPaths := []string{importPath, transformImportPath(importPath, param.PkgRegexps, param.vendorDirName())}
+	for _, allowed := range param.AllowUnused {
+		for _, allowedPath := range []string{allowed, transformImportPath(allowed, param.PkgRegexps, param.vendorDirName())} {
+			for _, currImportPath := range importPaths {
+				if currImportPath == allowedPath || strings.HasPrefix(currImportPath, allowedPath+"/") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hasIgnoredPrefix returns true if the provided import path (including the vendor directory) starts with one of the
+// prefixes in param.IgnorePrefixes once the vendor directory is removed.
+func hasIgnoredPrefix(importPath string, param Param) bool {
+	importPath = outputImportPath(importPath, false, param.vendorDirName())
+	for _, prefix := range param.IgnorePrefixes {
+		if strings.HasPrefix(importPath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// enclosingNonInternalPkg returns the import path of the package that encloses the first "internal" directory in the
+// portion of the provided import path after the vendor directory. For example, the enclosing package of
+// "github.com/org/project/vendor/github.com/org/library/internal/impl" is
+// "github.com/org/project/vendor/github.com/org/library". Returns the provided import path if it does not contain an
+// "internal" element after the vendor directory.
+func enclosingNonInternalPkg(importPath, vendorDirName string) string {
+	vendoredPath := outputImportPath(importPath, false, vendorDirName)
+	vendorPrefix := strings.TrimSuffix(importPath, vendoredPath)
+	if idx := strings.Index(vendoredPath, "/internal/"); idx != -1 {
+		return vendorPrefix + vendoredPath[:idx]
+	}
+	if strings.HasSuffix(vendoredPath, "/internal") {
+		return vendorPrefix + strings.TrimSuffix(vendoredPath, "/internal")
+	}
+	return importPath
+}
+
+// DiscoverVendorDirs returns the sorted absolute paths of the vendor directories that are analyzed for the provided
+// packages when the default parameters are used. The packages are not analyzed, so this is a cheap way to verify which
+// vendor directories are covered by the provided packages. If pkgs is empty, the package in projectDir is used.
+func DiscoverVendorDirs(projectDir string, pkgs []string) ([]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine working directory")
+	}
+	if len(pkgs) == 0 {
+		pkgs = []string{projectDir}
+	}
+	r := newResolver(Param{})
+	pkgs, err = resolveImportPathPkgs(r, pkgs, wd)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err = expandPkgs(pkgs, r.vendorDirName)
+	if err != nil {
+		return nil, err
+	}
+	vendorDirPaths := withoutNestedDirs(vendorDirsForPkgs(toAbsPaths(pkgs, wd), toAbsPaths([]string{projectDir}, wd)[0], r.vendorDirName), nil)
+	sort.Strings(vendorDirPaths)
+	return vendorDirPaths, nil
+}
+
+// ListVendoredPackages returns a map from the path of each vendor directory of the provided packages to the sorted
+// import paths (including the vendor directory) of all of the packages in that vendor directory. If pkgs is empty, the
+// package in projectDir is used. Returns an error with the cause ErrNoVendorDir if none of the packages have a vendor
+// directory.
+func ListVendoredPackages(projectDir string, pkgs []string) (map[string][]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine working directory")
+	}
+	if len(pkgs) == 0 {
+		pkgs = []string{projectDir}
+	}
+	r := newResolver(Param{})
+	pkgs, err = resolveImportPathPkgs(r, pkgs, wd)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err = expandPkgs(pkgs, r.vendorDirName)
+	if err != nil {
+		return nil, err
+	}
+
+	vendoredPkgs := make(map[string][]string)
+	vendorDirPaths := vendorDirsForPkgs(toAbsPaths(pkgs, wd), toAbsPaths([]string{projectDir}, wd)[0], r.vendorDirName)
+	if len(vendorDirPaths) == 0 {
+		return nil, errors.Wrapf(ErrNoVendorDir, "no vendor directories found for packages %v", pkgs)
+	}
+	for _, vendorDirPath := range vendorDirPaths {
+		pkgsInVendorDir, err := allVendoredPackages(context.Background(), r, vendorDirPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to determine packages in vendor directory %s", vendorDirPath)
+		}
+		var importPaths []string
+		for importPath := range pkgsInVendorDir {
+			importPaths = append(importPaths, importPath)
+		}
+		sort.Strings(importPaths)
+		vendoredPkgs[vendorDirPath] = importPaths
+	}
+	return vendoredPkgs, nil
 }
 
 func sortedVals(in map[string]struct{}) []string {
@@ -90,64 +1216,1133 @@ func sortedVals(in map[string]struct{}) []string {
 	return out
 }
 
-func unusedVendoredPackages(projectDir string, pkgs, ignorePkgs []string, regexps []*regexp.Regexp) (map[string]map[string]struct{}, error) {
+func sortedKeys(in map[string][]string) []string {
+	var out []string
+	for k := range in {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// vendorAnalysis stores the output of unusedVendoredPackages.
+type vendorAnalysis struct {
+	// projectDir is the absolute path of the project directory.
+	projectDir string
+	// unused is a map from vendor directory to the normalized import paths of the unused packages in that directory.
+	unused map[string]map[string]struct{}
+	// stdlibImports are the sorted import paths of the standard library packages imported by the project. Only
+	// populated if param.TrackStdlib is true.
+	stdlibImports []string
+	// notVendoredImports are the imports of project packages that are not vendored. Only populated if
+	// param.ReportNotVendored is true.
+	notVendoredImports []NotVendoredImport
+	// used is a map from vendor directory to the normalized import paths of the packages in that directory that are
+	// imported by the analyzed packages.
+	used map[string]map[string]struct{}
+	// vendored is a map from vendor directory to the normalized import paths of all of the packages in that directory.
+	vendored map[string]map[string]struct{}
+	// importers is a map from the normalized import path of every used vendored package to the import paths of the
+	// analyzed packages that import it. Only non-nil if param.ShowImporters is true.
+	importers map[string]map[string]struct{}
+	// emptyDirs is a map from vendor directory to the directories within it that do not contain any Go files. Only
+	// non-nil if param.ReportEmpty is true.
+	emptyDirs map[string][]string
+	// importCommentMismatches are the vendored packages whose import comments do not match their vendored import
+	// path. Only populated if param.CheckImportComments is true.
+	importCommentMismatches []ImportCommentMismatch
+	// dirNameMismatches are the vendored packages whose directory names do not match their import paths. Only
+	// populated if param.CheckDirNames is true.
+	dirNameMismatches []DirNameMismatch
+	// warnings are the errors encountered while importing packages. Only populated if param.ReportWarnings is true.
+	warnings []Warning
+	// skippedDirs are the directories in vendor directories that could not be read. Only populated if
+	// param.ContinueOnWalkError is true.
+	skippedDirs []Warning
+	// stdlibShadows are the import paths of the vendored packages whose first path element is the name of a standard
+	// library package. Only populated if param.CheckStdlibShadow is true.
+	stdlibShadows []string
+	// unbuildablePkgs are the import paths of the vendored packages that cannot be built on any platform. Only
+	// populated if param.ReportUnbuildable is true.
+	unbuildablePkgs []string
+	// vendoredMainPkgs are the import paths of the vendored packages named "main". Only populated if
+	// param.WarnVendoredMain is true.
+	vendoredMainPkgs []string
+	// usedOnlyByIgnoredPkgs are the import paths of the vendored packages that are used only by ignored packages. Only
+	// populated if param.ExplainIgnores is true.
+	usedOnlyByIgnoredPkgs []string
+	// blankOnlyPkgs are the import paths of the vendored packages that are only imported using blank imports. Only
+	// populated if param.ReportBlankOnly is true.
+	blankOnlyPkgs []string
+	// redundantIgnores are the entries of param.IgnorePkgs whose packages are used by the project packages. Only
+	// populated if param.ReportRedundantIgnores is true.
+	redundantIgnores []string
+	// staleIgnores are the entries of param.IgnorePkgs whose directories do not exist or do not contain a Go package.
+	// Only populated if param.ReportStaleIgnores is true.
+	staleIgnores []string
+	// shadowedPkgs are the packages that are imported from outside of a vendor directory while also being vendored.
+	// Only populated if param.ReportShadowed is true.
+	shadowedPkgs []ShadowedPkg
+	// vendoredTestDeps are the import paths of the unused vendored packages that are reachable through the test files
+	// of used vendored packages. Only populated if param.ReportVendoredTestDeps is true.
+	vendoredTestDeps []string
+	// versionMismatches are the packages vendored in multiple vendor directories with different contents. Only
+	// populated if param.CheckVersionMismatch is true.
+	versionMismatches []VersionMismatch
+	// onlyBuildIgnoredPkgs are the import paths of the vendored packages that are used only by files that are excluded
+	// by the default build context. Only populated if param.OnlyBuildIgnored is true.
+	onlyBuildIgnoredPkgs []string
+	// imports maps the import path of each examined package to the import paths of the packages it imports. Only
+	// populated if param.Format is FormatDOT or the import graph is otherwise collected.
+	imports map[string]map[string]struct{}
+	// pkgNames maps the normalized import path of every vendored package to the names declared by the packages in its
+	// directory. Only non-nil if param.ShowName is true.
+	pkgNames map[string]map[string]struct{}
+	// projectPkgs are the sorted import paths of the analyzed project packages (not including the ignored packages).
+	// Only populated if imports is populated.
+	projectPkgs []string
+}
+
+func unusedVendoredPackages(ctx context.Context, projectDir string, pkgs []string, param Param) (*vendorAnalysis, error) {
 	wd, err := os.Getwd()
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to determine working directory")
 	}
 
 	if !filepath.IsAbs(projectDir) {
-		projectDir = path.Join(wd, projectDir)
+		projectDir = filepath.Join(wd, projectDir)
 	}
 
-	absPkgPaths := toAbsPaths(pkgs, wd)
-	vendorDirs := make(map[string]map[string]struct{})
-	for _, pkgPath := range absPkgPaths {
-		vendorDirPath := path.Join(pkgPath, "vendor")
-		if fi, err := os.Stat(vendorDirPath); err != nil || !fi.IsDir() {
-			continue
+	if param.GoVersion != "" {
+		if _, err := goReleaseTags(param.GoVersion); err != nil {
+			return nil, err
 		}
+	}
 
-		pkgsInVendorDir, err := allVendoredPackages(vendorDirPath)
+	r := newResolver(param)
+	if r.replacements, err = localReplacements(projectDir); err != nil {
+		return nil, errors.Wrapf(err, "failed to determine replacements for project %s", projectDir)
+	}
+	if r.modMode != ModVendor {
+		if err := r.setModules(projectDir, param); err != nil {
+			return nil, err
+		}
+	} else if err := r.setVendoredModules(projectDir); err != nil {
+		return nil, err
+	}
+	normalizeRegexps := param.PkgRegexps
+	if param.GroupByRepo || param.StrictSubpackages {
+		// determine unused packages at the granularity of individual packages: grouping is done when printed or, for
+		// strict subpackages, once the unused packages are known
+		normalizeRegexps = nil
+	}
+	pkgs, err = resolveImportPathPkgs(r, pkgs, wd)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err = expandPkgs(pkgs, r.vendorDirName)
+	if err != nil {
+		return nil, err
+	}
+	absPkgPaths := uniquePaths(toAbsPaths(pkgs, wd))
+	vendorDirs := make(map[string]map[string]struct{})
+	vendoredInDirs := make(map[string]map[string]struct{})
+	usedInDirs := make(map[string]map[string]struct{})
+	vendoredPkgs := make(map[string]struct{})
+	var emptyDirs map[string][]string
+	if param.ReportEmpty {
+		emptyDirs = make(map[string][]string)
+	}
+	var importCommentMismatches []ImportCommentMismatch
+	var dirNameMismatches []DirNameMismatch
+	var stdlibShadows []string
+	var vendoredMainPkgs []string
+	var unbuildablePkgs []string
+	var pkgNames map[string]map[string]struct{}
+	if param.ShowName {
+		pkgNames = make(map[string]map[string]struct{})
+	}
+	// map from import path (not including the vendor directory) to the vendor directories in which it is vendored
+	var vendoredAt map[string][]string
+	if param.ReportShadowed {
+		vendoredAt = make(map[string][]string)
+	}
+	// map from vendored import path to content hash to directories with that content
+	var pkgHashes map[string]map[string][]string
+	if param.CheckVersionMismatch {
+		pkgHashes = make(map[string]map[string][]string)
+	}
+	scanStart := time.Now()
+	allVendorDirPaths := vendorDirsForPkgs(absPkgPaths, projectDir, r.vendorDirName)
+	if !param.AllowNestedVendor {
+		allVendorDirPaths = withoutNestedDirs(allVendorDirPaths, r.logger)
+	}
+	if r.mainModuleDir != "" {
+		// in module mode, only the vendor directory of the main module is used to resolve imports
+		allVendorDirPaths = onlyMainModuleVendorDir(allVendorDirPaths, filepath.Join(r.mainModuleDir, r.vendorDirName), r.logger)
+	}
+	if param.RequireVendor && len(allVendorDirPaths) == 0 {
+		return nil, errors.Wrapf(ErrNoVendorDir, "none of the analyzed packages in project %s have a '%s' directory", projectDir, r.vendorDirName)
+	}
+	for i, vendorDirPath := range allVendorDirPaths {
+		if r.logger != nil {
+			r.logger.Printf("scanning vendor directory %s", vendorDirPath)
+		}
+		pkgsInVendorDir, err := vendoredPackages(ctx, r, vendorDirPath, param)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to determine packages in vendor directory %s", vendorDirPath)
 		}
+		if param.CheckImportComments {
+			importCommentMismatches = append(importCommentMismatches, checkImportComments(pkgsInVendorDir, r.vendorDirName)...)
+		}
+		if param.CheckDirNames {
+			dirNameMismatches = append(dirNameMismatches, checkDirNames(pkgsInVendorDir, r.vendorDirName)...)
+		}
+		if param.CheckStdlibShadow {
+			stdlibShadows = append(stdlibShadows, checkStdlibShadows(r, pkgsInVendorDir)...)
+		}
+		if emptyDirs != nil {
+			emptyDirsInVendorDir, err := emptyVendoredDirs(ctx, vendorDirPath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to determine empty directories in vendor directory %s", vendorDirPath)
+			}
+			emptyDirs[vendorDirPath] = emptyDirsInVendorDir
+		}
+		if pkgHashes != nil {
+			for importPath, pkgs := range pkgsInVendorDir {
+				hash, err := hashGoFiles(pkgs[0].Dir)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to compute hash of package in %s", pkgs[0].Dir)
+				}
+				vendoredPath := outputImportPath(importPath, false, r.vendorDirName)
+				if pkgHashes[vendoredPath] == nil {
+					pkgHashes[vendoredPath] = make(map[string][]string)
+				}
+				pkgHashes[vendoredPath][hash] = append(pkgHashes[vendoredPath][hash], pkgs[0].Dir)
+			}
+		}
+		if param.ReportUnbuildable {
+			unbuildablePkgs = append(unbuildablePkgs, unbuildablePackages(r, pkgsInVendorDir)...)
+		}
+		if param.WarnVendoredMain {
+			mainPkgs := vendoredMainPackages(pkgsInVendorDir)
+			vendoredMainPkgs = append(vendoredMainPkgs, mainPkgs...)
+			for _, mainPkg := range mainPkgs {
+				// main packages cannot be imported, so do not consider them when determining unused packages
+				delete(pkgsInVendorDir, mainPkg)
+			}
+		}
 		normalizedPkgImportPaths := make(map[string]struct{})
-		for pkg := range pkgsInVendorDir {
-			normalizedPkgImportPaths[transformImportPath(pkg, regexps)] = struct{}{}
+		for pkg, buildPkgs := range pkgsInVendorDir {
+			normalizedPkg := transformImportPath(pkg, normalizeRegexps, r.vendorDirName)
+			normalizedPkgImportPaths[normalizedPkg] = struct{}{}
+			vendoredPkgs[normalizedPkg] = struct{}{}
+			if pkgNames != nil {
+				recordPkgNames(pkgNames, normalizedPkg, buildPkgs)
+			}
+			if vendoredAt != nil {
+				vendoredPath := outputImportPath(pkg, false, r.vendorDirName)
+				vendoredAt[vendoredPath] = append(vendoredAt[vendoredPath], vendorDirPath)
+			}
 		}
 		vendorDirs[vendorDirPath] = normalizedPkgImportPaths
+		vendoredInDirs[vendorDirPath] = combineMaps(nil, normalizedPkgImportPaths)
+		for pkg := range pkgsInVendorDir {
+			if _, ok := r.retainedPkgs[pkg]; !ok {
+				continue
+			}
+			normalizedPkg := transformImportPath(pkg, normalizeRegexps, r.vendorDirName)
+			if r.logger != nil {
+				r.logger.Printf("%s is retained: removing from unused packages of vendor directory %s", normalizedPkg, vendorDirPath)
+			}
+			delete(normalizedPkgImportPaths, normalizedPkg)
+		}
+		if param.ProgressFn != nil {
+			param.ProgressFn(i+1, len(allVendorDirPaths))
+		}
 	}
 
-	// add ignore packages to absPkgPaths so that packages to ignore (and all their dependencies) are not considered.
-	// Done here instead of earlier because vendor directories in the ignore packages should not be considered.
-	absPkgPaths = append(absPkgPaths, toAbsPaths(ignorePkgs, wd)...)
-	for _, pkgPath := range absPkgPaths {
-		importsInPkg, err := allImportsInPkg(pkgPath, projectDir)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to determine imports in package %s", pkgPath)
-		}
-		for currImportPath := range importsInPkg {
-			normalizedImportPath := transformImportPath(currImportPath, regexps)
-			for _, vendorDirPkgs := range vendorDirs {
-				delete(vendorDirPkgs, normalizedImportPath)
+	param.reportMetric(PhaseScanVendorDirs, scanStart)
+
+	// map from the case-folded import path of each vendored package to its import path. Only non-nil if import paths
+	// should be matched without regard to case.
+	var foldedVendoredPkgs map[string]string
+	if param.caseInsensitive(projectDir) {
+		foldedVendoredPkgs = make(map[string]string)
+		for _, pkg := range sortedVals(vendoredPkgs) {
+			if _, ok := foldedVendoredPkgs[strings.ToLower(pkg)]; !ok {
+				foldedVendoredPkgs[strings.ToLower(pkg)] = pkg
 			}
 		}
 	}
-	return vendorDirs, nil
-}
 
-func toAbsPaths(in []string, wd string) []string {
-	var out []string
+	var importers map[string]map[string]struct{}
+	if param.ShowImporters {
+		importers = make(map[string]map[string]struct{})
+	}
+
+	if param.StrictReachability {
+		absPkgPaths = withoutVendoredPkgs(projectDir, absPkgPaths, r.vendorDirName, r.logger)
+	}
+
+	// add ignore packages to absPkgPaths so that packages to ignore (and all their dependencies) are not considered.
+	// Done here instead of earlier because vendor directories in the ignore packages should not be considered.
+	numProjectPkgs := len(absPkgPaths)
+	var projectPkgs []string
+	if r.imports != nil {
+		projectPkgSet := make(map[string]struct{})
+		for _, pkgPath := range absPkgPaths {
+			projectPkgSet[pkgImportPath(r, pkgPath)] = struct{}{}
+		}
+		projectPkgs = sortedVals(projectPkgSet)
+	}
+	absPkgPaths = append(absPkgPaths, toAbsPaths(param.IgnorePkgs, wd)...)
+
+	// track the vendored packages used by project packages so that ignored packages that are used anyway can be
+	// determined. If ignores should be explained, also track the vendored packages used by ignored packages separately
+	// so that the packages that are used only by ignored packages can be determined.
+	var usedByProject, usedByIgnored map[string]struct{}
+	if param.ExplainIgnores || len(param.IgnorePkgs) > 0 {
+		usedByProject = make(map[string]struct{})
+	}
+	if param.ExplainIgnores {
+		usedByIgnored = make(map[string]struct{})
+	}
+	var used map[string]struct{}
+	if param.OnlyBuildIgnored {
+		used = make(map[string]struct{})
+	}
+	var nonVendoredImports map[string]struct{}
+	if param.ReportShadowed {
+		nonVendoredImports = make(map[string]struct{})
+	}
+	// imports of the project packages (including the project packages themselves)
+	var projectImports map[string]struct{}
+	if param.FailOnMissingVendoring {
+		projectImports = make(map[string]struct{})
+	}
+	// import paths of the vendored packages used by the project packages (not normalized)
+	var usedVendoredImports map[string]struct{}
+	if param.ReportVendoredTestDeps {
+		usedVendoredImports = make(map[string]struct{})
+	}
+	importResolvers := []*resolver{r}
+	if len(param.Platforms) > 0 {
+		importResolvers = nil
+		for _, platform := range param.Platforms {
+			importResolvers = append(importResolvers, r.forPlatform(platform[0], platform[1]))
+		}
+	}
+	pkgResolvers := make(map[string]*resolver)
+	for pkgPath, override := range param.PerPkgContext {
+		pkgResolvers[toAbsPaths([]string{pkgPath}, wd)[0]] = r.withOverride(override)
+	}
+	importsStart := time.Now()
+	for i, pkgPath := range absPkgPaths {
+		importsInPkg := make(map[string]struct{})
+		currImportResolvers := importResolvers
+		if pkgResolver, ok := pkgResolvers[pkgPath]; ok {
+			currImportResolvers = []*resolver{pkgResolver}
+		}
+		for _, importResolver := range currImportResolvers {
+			currImportsInPkg, err := allImportsInPkg(ctx, importResolver, pkgPath, projectDir, !param.ExcludeTestImports)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to determine imports in package %s", pkgPath)
+			}
+			for k, v := range currImportsInPkg {
+				importsInPkg[k] = v
+			}
+		}
+
+		usedSet := usedByProject
+		if i >= numProjectPkgs {
+			usedSet = usedByIgnored
+		}
+
+		var importer string
+		if importers != nil {
+			importer = pkgImportPath(r, pkgPath)
+		}
+		for currImportPath := range importsInPkg {
+			if nonVendoredImports != nil {
+				if vendorPrefix, _ := splitVendorPrefix(toSlashImportPath(currImportPath), r.vendorDirName); vendorPrefix == "" {
+					nonVendoredImports[currImportPath] = struct{}{}
+				}
+			}
+			normalizedImportPath := matchCase(transformImportPath(currImportPath, normalizeRegexps, r.vendorDirName), vendoredPkgs, foldedVendoredPkgs)
+			if projectImports != nil && i < numProjectPkgs {
+				projectImports[currImportPath] = struct{}{}
+			}
+			if _, ok := vendoredPkgs[normalizedImportPath]; ok && usedVendoredImports != nil && i < numProjectPkgs {
+				usedVendoredImports[currImportPath] = struct{}{}
+			}
+			for vendorDirPath, vendorDirPkgs := range vendorDirs {
+				if _, ok := vendorDirPkgs[normalizedImportPath]; ok {
+					if r.logger != nil {
+						r.logger.Printf("%s is used by package %s: removing from unused packages of vendor directory %s", normalizedImportPath, pkgPath, vendorDirPath)
+					}
+					if usedInDirs[vendorDirPath] == nil {
+						usedInDirs[vendorDirPath] = make(map[string]struct{})
+					}
+					usedInDirs[vendorDirPath][normalizedImportPath] = struct{}{}
+				}
+				delete(vendorDirPkgs, normalizedImportPath)
+			}
+			if _, ok := vendoredPkgs[normalizedImportPath]; ok && usedSet != nil {
+				usedSet[normalizedImportPath] = struct{}{}
+			}
+			if _, ok := vendoredPkgs[normalizedImportPath]; ok && used != nil {
+				used[normalizedImportPath] = struct{}{}
+			}
+			if _, ok := vendoredPkgs[normalizedImportPath]; ok && importers != nil && importer != normalizedImportPath {
+				if importers[normalizedImportPath] == nil {
+					importers[normalizedImportPath] = make(map[string]struct{})
+				}
+				importers[normalizedImportPath][importer] = struct{}{}
+			}
+		}
+	}
+
+	param.reportMetric(PhaseCollectImports, importsStart)
+
+	if param.FailOnMissingVendoring && len(vendoredPkgs) == 0 {
+		if external := externalImports(r, sortedVals(projectImports), projectDir); len(external) > 0 {
+			return nil, errors.Wrapf(ErrMissingVendoring, "packages in project %s import %d package(s) outside of the project (%s)", projectDir, len(external), summarizedList(external, 3))
+		}
+	}
+
+	var shadowedPkgs []ShadowedPkg
+	for _, importPath := range sortedVals(nonVendoredImports) {
+		vendorDirPaths, ok := vendoredAt[importPath]
+		if !ok {
+			continue
+		}
+		pkgDir := ""
+		if pkg, _ := doImport(r, importPath, "", build.FindOnly, nil); pkg != nil {
+			pkgDir = pkg.Dir
+		}
+		shadowedPkgs = append(shadowedPkgs, ShadowedPkg{
+			ImportPath: importPath,
+			Dir:        pkgDir,
+			VendorDirs: sortedUnique(vendorDirPaths),
+		})
+	}
+
+	var vendoredTestDeps []string
+	if param.ReportVendoredTestDeps {
+		testDeps, err := vendoredTestDepPkgs(ctx, r, sortedVals(usedVendoredImports), projectDir)
+		if err != nil {
+			return nil, err
+		}
+		unusedTestDeps := make(map[string]struct{})
+		for testDep := range testDeps {
+			normalizedTestDep := transformImportPath(testDep, normalizeRegexps, r.vendorDirName)
+			for _, vendorDirPkgs := range vendorDirs {
+				if _, ok := vendorDirPkgs[normalizedTestDep]; ok {
+					unusedTestDeps[normalizedTestDep] = struct{}{}
+				}
+			}
+		}
+		vendoredTestDeps = sortedVals(unusedTestDeps)
+	}
+
+	var redundantIgnores []string
+	if param.ReportRedundantIgnores {
+		for i, ignorePkg := range param.IgnorePkgs {
+			ignoredImportPath := matchCase(transformImportPath(pkgImportPath(r, absPkgPaths[numProjectPkgs+i]), normalizeRegexps, r.vendorDirName), vendoredPkgs, foldedVendoredPkgs)
+			if _, ok := usedByProject[ignoredImportPath]; ok {
+				if r.logger != nil {
+					r.logger.Printf("ignored package %s is used by the project packages: ignore is redundant", ignorePkg)
+				}
+				redundantIgnores = append(redundantIgnores, ignorePkg)
+			}
+		}
+		sort.Strings(redundantIgnores)
+	}
+
+	var staleIgnorePkgs []string
+	if param.ReportStaleIgnores {
+		staleIgnorePkgs = staleIgnores(r, param.IgnorePkgs, absPkgPaths[numProjectPkgs:])
+	}
+
+	var onlyBuildIgnoredPkgs []string
+	if param.OnlyBuildIgnored {
+		// determine the packages that are used when the default build context is used and the packages that are
+		// used only by excluded files are those that are not in that set
+		buildResolver := *r
+		buildResolver.ctx.UseAllFiles = false
+		buildResolver.warnings = nil
+		buildResolver.imports = nil
+		usedInBuild := make(map[string]struct{})
+		for _, pkgPath := range absPkgPaths {
+			importsInPkg, err := allImportsInPkg(ctx, &buildResolver, pkgPath, projectDir, !param.ExcludeTestImports)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to determine imports in package %s using default build context", pkgPath)
+			}
+			for currImportPath := range importsInPkg {
+				usedInBuild[matchCase(transformImportPath(currImportPath, normalizeRegexps, r.vendorDirName), vendoredPkgs, foldedVendoredPkgs)] = struct{}{}
+			}
+		}
+		onlyBuildIgnoredPkgs = sortedDifference(used, usedInBuild)
+	}
+
+	var blankOnlyPkgs []string
+	if param.ReportBlankOnly {
+		blankImported := make(map[string]struct{})
+		namedImported := make(map[string]struct{})
+		for importPath, named := range r.importNames {
+			normalizedImportPath := matchCase(transformImportPath(importPath, normalizeRegexps, r.vendorDirName), vendoredPkgs, foldedVendoredPkgs)
+			if _, ok := vendoredPkgs[normalizedImportPath]; !ok {
+				continue
+			}
+			if named {
+				namedImported[normalizedImportPath] = struct{}{}
+			} else {
+				blankImported[normalizedImportPath] = struct{}{}
+			}
+		}
+		blankOnlyPkgs = sortedDifference(blankImported, namedImported)
+	}
+
+	if param.StrictSubpackages && !param.GroupByRepo {
+		for vendorDirPath := range vendorDirs {
+			vendorDirs[vendorDirPath] = regroupUnused(vendorDirs[vendorDirPath], vendoredInDirs[vendorDirPath], param.PkgRegexps, r.vendorDirName)
+			vendoredInDirs[vendorDirPath] = normalizedImportPaths(vendoredInDirs[vendorDirPath], param.PkgRegexps, r.vendorDirName)
+			usedInDirs[vendorDirPath] = normalizedImportPaths(usedInDirs[vendorDirPath], param.PkgRegexps, r.vendorDirName)
+		}
+	}
+
+	return &vendorAnalysis{
+		projectDir:              projectDir,
+		unused:                  vendorDirs,
+		vendored:                vendoredInDirs,
+		used:                    usedInDirs,
+		stdlibImports:           sortedVals(r.stdlibImports),
+		notVendoredImports:      r.sortedNotVendoredImports(),
+		importers:               importers,
+		emptyDirs:               emptyDirs,
+		importCommentMismatches: importCommentMismatches,
+		dirNameMismatches:       dirNameMismatches,
+		warnings:                r.sortedWarnings(),
+		skippedDirs:             sortedWarnings(r.skippedDirs),
+		stdlibShadows:           stdlibShadows,
+		vendoredMainPkgs:        vendoredMainPkgs,
+		unbuildablePkgs:         sortedUnique(unbuildablePkgs),
+		versionMismatches:       versionMismatches(pkgHashes),
+		usedOnlyByIgnoredPkgs:   sortedDifference(usedByIgnored, usedByProject),
+		redundantIgnores:        redundantIgnores,
+		staleIgnores:            staleIgnorePkgs,
+		shadowedPkgs:            shadowedPkgs,
+		vendoredTestDeps:        vendoredTestDeps,
+		blankOnlyPkgs:           blankOnlyPkgs,
+		onlyBuildIgnoredPkgs:    onlyBuildIgnoredPkgs,
+		imports:                 r.imports,
+		pkgNames:                pkgNames,
+		projectPkgs:             projectPkgs,
+	}, nil
+}
+
+// recordPkgNames records the names declared by the provided packages as names of the package with the provided import
+// path in the provided map.
+func recordPkgNames(pkgNames map[string]map[string]struct{}, importPath string, pkgs []*build.Package) {
+	for _, pkg := range pkgs {
+		if pkg.Name == "" {
+			continue
+		}
+		if pkgNames[importPath] == nil {
+			pkgNames[importPath] = make(map[string]struct{})
+		}
+		pkgNames[importPath][pkg.Name] = struct{}{}
+	}
+}
+
+// regroupUnused returns the provided unused import paths grouped using the provided regular expressions. The unused
+// import paths of a group are replaced by the group if every one of the provided vendored import paths in the group is
+// unused and are retained individually otherwise.
+func regroupUnused(unused, vendored map[string]struct{}, regexps []*regexp.Regexp, vendorDirName string) map[string]struct{} {
+	numVendoredInGroup := make(map[string]int)
+	for pkg := range vendored {
+		numVendoredInGroup[transformImportPath(pkg, regexps, vendorDirName)]++
+	}
+	unusedInGroup := make(map[string][]string)
+	for pkg := range unused {
+		group := transformImportPath(pkg, regexps, vendorDirName)
+		unusedInGroup[group] = append(unusedInGroup[group], pkg)
+	}
+	regrouped := make(map[string]struct{})
+	for group, pkgs := range unusedInGroup {
+		if len(pkgs) == numVendoredInGroup[group] {
+			regrouped[group] = struct{}{}
+			continue
+		}
+		for _, pkg := range pkgs {
+			regrouped[pkg] = struct{}{}
+		}
+	}
+	return regrouped
+}
+
+// normalizedImportPaths returns the set of the provided import paths normalized using the provided regular
+// expressions. Returns nil if the provided set is nil.
+func normalizedImportPaths(importPaths map[string]struct{}, regexps []*regexp.Regexp, vendorDirName string) map[string]struct{} {
+	if importPaths == nil {
+		return nil
+	}
+	normalized := make(map[string]struct{})
+	for pkg := range importPaths {
+		normalized[transformImportPath(pkg, regexps, vendorDirName)] = struct{}{}
+	}
+	return normalized
+}
+
+// sortedUnique returns the provided strings sorted with duplicates removed.
+func sortedUnique(in []string) []string {
+	sorted := append([]string{}, in...)
+	sort.Strings(sorted)
+	return dedupeSorted(sorted)
+}
+
+// sortedDifference returns the sorted keys of m1 that are not keys of m2.
+func sortedDifference(m1, m2 map[string]struct{}) []string {
+	var out []string
+	for pkg := range m1 {
+		if _, ok := m2[pkg]; !ok {
+			out = append(out, pkg)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// versionMismatches returns the packages in the provided map (from vendored import path to content hash to directories
+// with that content) that are vendored with more than one distinct content hash, sorted by import path.
+func versionMismatches(pkgHashes map[string]map[string][]string) []VersionMismatch {
+	var mismatches []VersionMismatch
+	for importPath, hashes := range pkgHashes {
+		if len(hashes) < 2 {
+			continue
+		}
+		var dirs []string
+		for _, hashDirs := range hashes {
+			dirs = append(dirs, hashDirs...)
+		}
+		sort.Strings(dirs)
+		mismatches = append(mismatches, VersionMismatch{
+			ImportPath: importPath,
+			Dirs:       dirs,
+		})
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].ImportPath < mismatches[j].ImportPath
+	})
+	return mismatches
+}
+
+// hashGoFiles returns a hex-encoded hash of the names and contents of the Go files in the provided directory.
+func hashGoFiles(dir string) (string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read directory %s", dir)
+	}
+	h := sha256.New()
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".go") {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read file %s", filepath.Join(dir, file.Name()))
+		}
+		fmt.Fprintf(h, "%s\n%d\n", file.Name(), len(content))
+		h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkStdlibShadows returns the sorted import paths of the packages in the provided map (whose keys are the import
+// paths of vendored packages) whose vendored import path starts with a path element that is the name of a standard
+// library package. For example, a package vendored as "vendor/net/http" shadows the standard library package "net".
+// Vendoring such packages is almost always a mistake.
+func checkStdlibShadows(r *resolver, vendoredPkgs map[string][]*build.Package) []string {
+	var shadows []string
+	for importPath := range vendoredPkgs {
+		firstElem := strings.SplitN(outputImportPath(importPath, false, r.vendorDirName), "/", 2)[0]
+		if isStdlibPkg(r, firstElem) {
+			shadows = append(shadows, importPath)
+		}
+	}
+	sort.Strings(shadows)
+	return shadows
+}
+
+// vendoredMainPackages returns the sorted import paths of the packages in the provided map (whose keys are the import
+// paths of vendored packages and values are the packages for the import path) that are named "main". Directories that
+// contain both a main package and another package are not included.
+func vendoredMainPackages(vendoredPkgs map[string][]*build.Package) []string {
+	var mainPkgs []string
+	for importPath, pkgs := range vendoredPkgs {
+		isMain := len(pkgs) > 0
+		for _, pkg := range pkgs {
+			isMain = isMain && pkg.Name == "main"
+		}
+		if isMain {
+			mainPkgs = append(mainPkgs, importPath)
+		}
+	}
+	sort.Strings(mainPkgs)
+	return mainPkgs
+}
+
+// UnbuildablePlatforms are the GOOS/GOARCH pairs on which vendored packages are checked to be buildable when
+// Param.ReportUnbuildable is true.
+var UnbuildablePlatforms = [][2]string{
+	{"android", "arm64"},
+	{"darwin", "amd64"},
+	{"darwin", "arm64"},
+	{"dragonfly", "amd64"},
+	{"freebsd", "386"},
+	{"freebsd", "amd64"},
+	{"freebsd", "arm"},
+	{"js", "wasm"},
+	{"linux", "386"},
+	{"linux", "amd64"},
+	{"linux", "arm"},
+	{"linux", "arm64"},
+	{"linux", "mips"},
+	{"linux", "mips64"},
+	{"linux", "mips64le"},
+	{"linux", "mipsle"},
+	{"linux", "ppc64"},
+	{"linux", "ppc64le"},
+	{"linux", "s390x"},
+	{"netbsd", "amd64"},
+	{"openbsd", "amd64"},
+	{"plan9", "amd64"},
+	{"solaris", "amd64"},
+	{"windows", "386"},
+	{"windows", "amd64"},
+}
+
+// unbuildablePackages returns the sorted import paths of the packages in the provided map (whose keys are the import
+// paths of vendored packages and values are the packages for the import path) that do not have any non-test Go files
+// that match the build constraints of any of the platforms in UnbuildablePlatforms.
+func unbuildablePackages(r *resolver, vendoredPkgs map[string][]*build.Package) []string {
+	var unbuildable []string
+	for importPath, pkgs := range vendoredPkgs {
+		if !isBuildableOnAnyPlatform(r, pkgs[0].Dir) {
+			unbuildable = append(unbuildable, importPath)
+		}
+	}
+	sort.Strings(unbuildable)
+	return unbuildable
+}
+
+// isBuildableOnAnyPlatform returns true if the provided directory contains non-test Go files that match the build
+// constraints of at least one of the platforms in UnbuildablePlatforms.
+func isBuildableOnAnyPlatform(r *resolver, dir string) bool {
+	for _, platform := range UnbuildablePlatforms {
+		platformCtx := r.forPlatform(platform[0], platform[1]).ctx
+		platformCtx.CgoEnabled = true
+		// ignore error because ImportDir returns partial object even on error
+		pkg, _ := platformCtx.ImportDir(dir, 0)
+		if len(pkg.GoFiles)+len(pkg.CgoFiles) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isStdlibPkg returns true if the provided import path is the import path of a package in the standard library.
+func isStdlibPkg(r *resolver, importPath string) bool {
+	if strings.Contains(importPath, ".") {
+		return false
+	}
+	pkg, err := r.ctx.Import(importPath, "", build.FindOnly)
+	return err == nil && pkg.Goroot
+}
+
+// checkImportComments returns the packages in the provided map (whose keys are the import paths of vendored packages
+// and values are the packages for the import path) that have a canonical import path comment that does not match the
+// import path at which they are vendored. The returned mismatches are sorted by directory.
+func checkImportComments(vendoredPkgs map[string][]*build.Package, vendorDirName string) []ImportCommentMismatch {
+	var mismatches []ImportCommentMismatch
+	for importPath, pkgs := range vendoredPkgs {
+		vendoredImportPath := outputImportPath(importPath, false, vendorDirName)
+		for _, pkg := range pkgs {
+			if pkg.ImportComment == "" || pkg.ImportComment == vendoredImportPath {
+				continue
+			}
+			mismatches = append(mismatches, ImportCommentMismatch{
+				Dir:           pkg.Dir,
+				ImportPath:    vendoredImportPath,
+				ImportComment: pkg.ImportComment,
+			})
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Dir < mismatches[j].Dir
+	})
+	return mismatches
+}
+
+// checkDirNames returns the packages in the provided map (whose keys are the import paths of vendored packages and
+// values are the packages for the import path) whose directory names do not match the last element of their vendored
+// import paths or of the canonical import paths declared by their import comments. The returned mismatches are sorted
+// by directory.
+func checkDirNames(vendoredPkgs map[string][]*build.Package, vendorDirName string) []DirNameMismatch {
+	var mismatches []DirNameMismatch
+	for importPath, pkgs := range vendoredPkgs {
+		vendoredImportPath := outputImportPath(importPath, false, vendorDirName)
+		for _, pkg := range pkgs {
+			dirName := filepath.Base(pkg.Dir)
+			for _, expectedImportPath := range []string{vendoredImportPath, pkg.ImportComment} {
+				if expectedImportPath == "" || path.Base(expectedImportPath) == dirName {
+					continue
+				}
+				mismatches = append(mismatches, DirNameMismatch{
+					Dir:                pkg.Dir,
+					ImportPath:         vendoredImportPath,
+					ExpectedImportPath: expectedImportPath,
+				})
+				break
+			}
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Dir < mismatches[j].Dir
+	})
+	return mismatches
+}
+
+// externalImports returns the provided import paths that refer to packages outside of the provided project directory,
+// including packages that cannot be found. Vendored import paths (including the import paths of packages provided by
+// required modules) and local import paths of project packages that are not in a GOPATH are never returned.
+func externalImports(r *resolver, importPaths []string, projectDir string) []string {
+	var external []string
+	for _, importPath := range importPaths {
+		if vendorPrefix, _ := splitVendorPrefix(toSlashImportPath(importPath), r.vendorDirName); vendorPrefix != "" || strings.HasPrefix(importPath, "_/") {
+			continue
+		}
+		if pkg, err := doImport(r, importPath, projectDir, build.FindOnly, nil); err == nil && pkg.Dir != "" {
+			if rel, err := filepath.Rel(projectDir, pkg.Dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+		}
+		external = append(external, importPath)
+	}
+	return external
+}
+
+// summarizedList returns the provided values joined by ", ". If there are more than max values, only the first max are
+// included followed by the number of values that were omitted.
+func summarizedList(values []string, max int) string {
+	if len(values) <= max {
+		return strings.Join(values, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(values[:max], ", "), len(values)-max)
+}
+
+// pkgImportPath returns the import path of the package in the provided directory. If the import path cannot be
+// determined (for example, because the directory is not in a GOPATH), the directory itself is returned.
+func pkgImportPath(r *resolver, pkgDir string) string {
+	if pkg, _ := doImport(r, ".", pkgDir, build.FindOnly, nil); pkg != nil && pkg.ImportPath != "" && pkg.ImportPath != "." {
+		return pkg.ImportPath
+	}
+	return pkgDir
+}
+
+// vendorDirsForPkgs returns the paths of the vendor directories with the provided name for the provided absolute package
+// paths. Matches the vendor resolution semantics of the Go tooling: the vendor directories of a package are the vendor
+// directories in the package directory and in all of its ancestor directories up to and including the provided absolute
+// project directory. If a package is not within the project directory, only the vendor directory in the package
+// directory is considered. Each vendor directory is returned only once.
+func vendorDirsForPkgs(absPkgPaths []string, absProjectDir, vendorDirName string) []string {
+	var vendorDirs []string
+	seen := make(map[string]struct{})
+	for _, pkgPath := range absPkgPaths {
+		for _, dir := range ancestorDirs(pkgPath, absProjectDir) {
+			vendorDirPath := filepath.Join(dir, vendorDirName)
+			if _, ok := seen[vendorDirPath]; ok {
+				continue
+			}
+			seen[vendorDirPath] = struct{}{}
+			if fi, err := os.Stat(vendorDirPath); err != nil || !fi.IsDir() {
+				continue
+			}
+			vendorDirs = append(vendorDirs, vendorDirPath)
+		}
+	}
+	return vendorDirs
+}
+
+// onlyMainModuleVendorDir returns the provided vendor directories with all directories other than the vendor directory
+// of the main module removed.
+func onlyMainModuleVendorDir(vendorDirs []string, mainModuleVendorDir string, logger *log.Logger) []string {
+	var out []string
+	for _, vendorDir := range vendorDirs {
+		if vendorDir != mainModuleVendorDir {
+			if logger != nil {
+				logger.Printf("skipping vendor directory %s: only the vendor directory of the main module is used in module mode", vendorDir)
+			}
+			continue
+		}
+		out = append(out, vendorDir)
+	}
+	return out
+}
+
+// ancestorDirs returns the provided directory followed by its ancestor directories up to and including the provided
+// root directory. If the directory is not within the root directory, only the directory itself is returned.
+func ancestorDirs(dir, rootDir string) []string {
+	dir = filepath.Clean(dir)
+	rootDir = filepath.Clean(rootDir)
+	if relPath, err := filepath.Rel(rootDir, dir); err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return []string{dir}
+	}
+	dirs := []string{dir}
+	for dir != rootDir {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// withoutVendoredPkgs returns the provided absolute package paths without the paths that are in a vendor directory
+// (a directory with the provided name) of the provided project directory. Only the portion of each path relative to the
+// project directory is considered, so vendor directories that contain the project directory do not matter. The order
+// of the provided paths is preserved.
+func withoutVendoredPkgs(projectDir string, absPkgPaths []string, vendorDirName string, logger *log.Logger) []string {
+	var out []string
+	for _, pkgPath := range absPkgPaths {
+		if rel, err := filepath.Rel(projectDir, pkgPath); err == nil && strings.Contains("/"+filepath.ToSlash(rel)+"/", "/"+vendorDirName+"/") {
+			if logger != nil {
+				logger.Printf("package %s is in a vendor directory: not considering it a project package", pkgPath)
+			}
+			continue
+		}
+		out = append(out, pkgPath)
+	}
+	return out
+}
+
+// withoutNestedDirs returns the provided directories without the directories that are nested within one of the other
+// provided directories. The order of the provided directories is preserved.
+func withoutNestedDirs(dirs []string, logger *log.Logger) []string {
+	var out []string
+	for _, dir := range dirs {
+		nested := false
+		for _, otherDir := range dirs {
+			if strings.HasPrefix(filepath.ToSlash(dir), filepath.ToSlash(otherDir)+"/") {
+				nested = true
+				if logger != nil {
+					logger.Printf("skipping vendor directory %s because it is nested within vendor directory %s", dir, otherDir)
+				}
+				break
+			}
+		}
+		if !nested {
+			out = append(out, dir)
+		}
+	}
+	return out
+}
+
+// resolveImportPathPkgs returns the provided package paths with any path that is an import path (for example,
+// "github.com/org/repo/cmd/tool") replaced by the directory of the package, as determined by importing it from the
+// provided working directory. A path is considered to be an import path if it does not start with "." or "/", is not
+// absolute and does not exist relative to the working directory, so directory paths continue to work. A trailing
+// "/..." is preserved so that import path patterns are expanded like directory patterns.
+func resolveImportPathPkgs(r *resolver, pkgs []string, wd string) ([]string, error) {
+	var out []string
+	for _, pkg := range pkgs {
+		if strings.HasPrefix(pkg, ".") || strings.HasPrefix(pkg, "/") || filepath.IsAbs(pkg) {
+			out = append(out, pkg)
+			continue
+		}
+		importPath, pattern := pkg, ""
+		if strings.HasSuffix(importPath, "/...") {
+			importPath, pattern = strings.TrimSuffix(importPath, "/..."), "/..."
+		}
+		if _, err := os.Stat(filepath.Join(wd, importPath)); err == nil {
+			out = append(out, pkg)
+			continue
+		}
+		importedPkg, err := doImport(r, importPath, wd, build.FindOnly, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve import path %s", importPath)
+		}
+		out = append(out, importedPkg.Dir+pattern)
+	}
+	return out, nil
+}
+
+// expandPkgs returns the provided package paths with any path that ends in "..." replaced by the paths of all of the
+// directories that contain Go files in the directory tree rooted at the portion of the path before the "...". Matches
+// the behavior of the standard Go tooling: vendor directories (directories with the provided name), "testdata"
+// directories and directories whose names begin with "." or "_" are not included.
+func expandPkgs(pkgs []string, vendorDirName string) ([]string, error) {
+	var out []string
+	for _, pkg := range pkgs {
+		if !strings.HasSuffix(pkg, "...") {
+			out = append(out, pkg)
+			continue
+		}
+		rootDir := strings.TrimSuffix(strings.TrimSuffix(pkg, "..."), "/")
+		if rootDir == "" {
+			rootDir = "."
+		}
+		if err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return nil
+			}
+			if path != rootDir {
+				if name := info.Name(); name == vendorDirName || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+					return filepath.SkipDir
+				}
+			}
+			if containsGoFiles, err := dirContainsGoFiles(path); err != nil {
+				return err
+			} else if containsGoFiles {
+				out = append(out, path)
+			}
+			return nil
+		}); err != nil {
+			return nil, errors.Wrapf(err, "failed to expand package pattern %s", pkg)
+		}
+	}
+	return out, nil
+}
+
+// dirContainsGoFiles returns true if the provided directory directly contains a Go file.
+func dirContainsGoFiles(dir string) (bool, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read directory %s", dir)
+	}
+	for _, fi := range fis {
+		if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".go") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func toAbsPaths(in []string, wd string) []string {
+	var out []string
 	for _, pkgPath := range in {
 		if !filepath.IsAbs(pkgPath) {
-			pkgPath = path.Join(wd, pkgPath)
+			pkgPath = filepath.Join(wd, pkgPath)
 		}
 		out = append(out, pkgPath)
 	}
 	return out
 }
 
+// matchCase returns the import path of the vendored package that matches the provided import path without regard to
+// case. Returns the provided import path if foldedVendoredPkgs is nil, if the import path matches a vendored package
+// exactly or if it does not match any vendored package.
+func matchCase(importPath string, vendoredPkgs map[string]struct{}, foldedVendoredPkgs map[string]string) string {
+	if foldedVendoredPkgs == nil {
+		return importPath
+	}
+	if _, ok := vendoredPkgs[importPath]; ok {
+		return importPath
+	}
+	if vendoredPkg, ok := foldedVendoredPkgs[strings.ToLower(importPath)]; ok {
+		return vendoredPkg
+	}
+	return importPath
+}
+
+// isCaseInsensitiveFS returns true if the filesystem that contains the provided directory is case-insensitive. This is
+// determined by changing the case of the last element of the path (or of its closest ancestor) that contains letters and
+// checking whether the resulting path refers to the same file. Returns false if this cannot be determined.
+func isCaseInsensitiveFS(dir string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for {
+		parent, base := filepath.Split(filepath.Clean(dir))
+		if base == "" {
+			return false
+		}
+		swapped := swapCase(base)
+		if swapped == base {
+			dir = parent
+			continue
+		}
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return false
+		}
+		swappedFi, err := os.Stat(filepath.Join(parent, swapped))
+		return err == nil && os.SameFile(fi, swappedFi)
+	}
+}
+
+// swapCase returns the provided string with the case of each letter swapped.
+func swapCase(in string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, in)
+}
+
+// staleIgnores returns the sorted entries of ignorePkgs whose directories (the entries of ignoreDirs at the same index)
+// do not exist or do not contain a Go package.
+func staleIgnores(r *resolver, ignorePkgs, ignoreDirs []string) []string {
+	var stale []string
+	for i, ignorePkg := range ignorePkgs {
+		if fi, err := os.Stat(ignoreDirs[i]); err == nil && fi.IsDir() {
+			_, err := doImport(r, ".", ignoreDirs[i], 0, nil)
+			if _, noGo := errors.Cause(err).(*build.NoGoError); !noGo {
+				// the directory contains a package (or Go files that cannot be imported): the entry is not stale
+				continue
+			}
+		}
+		if r.logger != nil {
+			r.logger.Printf("ignored package %s does not exist: ignore is stale", ignorePkg)
+		}
+		stale = append(stale, ignorePkg)
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// uniquePaths returns the cleaned forms of the provided paths with duplicates removed. The order of the first occurrence
+// of each path is preserved. Used to ensure that a package that is specified multiple times (for example, as both
+// "./pkg" and the absolute path of the same directory, or once directly and once as part of a "..." pattern) is only
+// analyzed once.
+func uniquePaths(paths []string) []string {
+	var out []string
+	seen := make(map[string]struct{})
+	for _, currPath := range paths {
+		currPath = filepath.Clean(currPath)
+		if _, ok := seen[currPath]; ok {
+			continue
+		}
+		seen[currPath] = struct{}{}
+		out = append(out, currPath)
+	}
+	return out
+}
+
+// vendorHostRegexp returns the regular expression that matches the repository paths (the host followed by two path
+// segments) of the packages on the provided host.
+func vendorHostRegexp(host string) string {
+	return "^" + regexp.QuoteMeta(strings.TrimSuffix(host, "/")) + `/[^/]+/[^/]+`
+}
+
 // regexpsForPkgMatchers returns the compiled regular expressions for the provided inputs. If the input regular
 // expression does not start with "^", it is added to ensure that a prefix match occurs. Returns an error if any of the
 // provided expressions do not compile.
@@ -168,87 +2363,315 @@ func regexpsForPkgMatchers(exprs []string) ([]*regexp.Regexp, error) {
 
 // transformImportPath takes the provided import path and normalizes it if it matches any of the provided regular
 // expressions. This function is used to map an import path to a normalized "repository" or "project" for the input
-// path. If the import path includes "/vendor/", then the normalization occurs for the portion of the path after the
-// last occurrence of "/vendor/". If the import path matches a provided regular expression, the matching part is
-// replaced with just the match for the regular expression.
+// path. If the import path is vendored (as determined by splitVendorPrefix), then the normalization occurs for the
+// portion of the path after the vendor directory. If the import path matches any of the provided regular expressions,
+// the matching part is replaced with the longest match among the expressions, so the result does not depend on the
+// order of the expressions unless several of them produce matches of the same length (in which case the first of them
+// is used). Any backslashes in the import path (which can occur if it was derived from a Windows file path) are
+// converted to forward slashes before the path is normalized. Normalization is symmetric: for any vendor prefix,
+// normalizing the vendored form of an import path yields the prefix followed by the normalized form of the import path
+// itself.
 //
 // Examples:
 //   "github.com/org/project/inner/pkg", `^github.com/[^/]+/[^/]+` -> "github.com/org/project"
 //   "github.com/org/project/vendor/gopkg.in/yaml.v2/inner", `^gopkg.in/[^/]+` -> "github.com/org/project/vendor/gopkg.in/yaml.v2"
-func transformImportPath(importPath string, regexps []*regexp.Regexp) string {
-	vendorPrefix := ""
-	if lastVendorIdx := strings.LastIndex(importPath, "/vendor/"); lastVendorIdx != -1 {
-		idxAfterLastVendor := lastVendorIdx + len("/vendor/")
-		vendorPrefix = importPath[:idxAfterLastVendor]
-		importPath = importPath[idxAfterLastVendor:]
-	}
+func transformImportPath(importPath string, regexps []*regexp.Regexp, vendorDirName string) string {
+	vendorPrefix, importPath := splitVendorPrefix(toSlashImportPath(importPath), vendorDirName)
+	longestMatch := -1
+	normalized := importPath
 	for _, reg := range regexps {
-		if match := reg.FindStringSubmatch(importPath); len(match) > 0 {
-			importPath = match[0]
-			break
+		if loc := reg.FindStringIndex(importPath); loc != nil && loc[1]-loc[0] > longestMatch {
+			longestMatch = loc[1] - loc[0]
+			normalized = importPath[loc[0]:loc[1]]
 		}
 	}
-	return vendorPrefix + importPath
+	return vendorPrefix + normalized
+}
+
+// splitVendorPrefix splits the provided slash-separated import path into the portion up to and including the last
+// vendor directory (where "vendor" is the provided vendor directory name) and the portion after it. A vendor directory
+// is an element of the path other than the last one, so the vendor directory may be the first element of the path (for
+// example, "vendor/github.com/org/lib"). If the import path does not contain a vendor directory, the returned prefix is
+// empty.
+func splitVendorPrefix(importPath, vendorDirName string) (string, string) {
+	vendorSegment := "/" + vendorDirName + "/"
+	if lastVendorIdx := strings.LastIndex(importPath, vendorSegment); lastVendorIdx != -1 {
+		idxAfterLastVendor := lastVendorIdx + len(vendorSegment)
+		return importPath[:idxAfterLastVendor], importPath[idxAfterLastVendor:]
+	}
+	if strings.HasPrefix(importPath, vendorDirName+"/") {
+		return importPath[:len(vendorDirName)+1], importPath[len(vendorDirName)+1:]
+	}
+	return "", importPath
+}
+
+// toSlashImportPath returns the provided import path with all backslashes replaced by forward slashes. Unlike
+// filepath.ToSlash, the conversion is performed regardless of the operating system. This is safe because backslashes
+// are not valid in import paths.
+func toSlashImportPath(importPath string) string {
+	return strings.Replace(importPath, `\`, "/", -1)
 }
 
 // allVendoredPackages returns the import paths of all of the packages in the provided vendor directory. The provided
-// input must be the path to a directory named "vendor". The returned import paths include the vendor directory itself.
-// For example, if the vendor directory is in a package with the import path "github.com/org/repo" and contains
-// "github.com/org/vendored", then the returned map would contain "github.com/org/repo/vendor/github.com/org/vendored".
-// Packages in the vendor directory are determined without regard to build constraints.
-func allVendoredPackages(vendorDir string) (map[string]struct{}, error) {
+// input must be the path to a directory named "vendor" (or the vendor directory name of the resolver, if it differs).
+// The returned import paths include the vendor directory itself. For example, if the vendor directory is in a package
+// with the import path "github.com/org/repo" and contains "github.com/org/vendored", then the returned map would
+// contain "github.com/org/repo/vendor/github.com/org/vendored". The values of the map are the packages in the
+// directory for the import path. Packages in the vendor directory are determined without regard to build constraints.
+func allVendoredPackages(ctx context.Context, r *resolver, vendorDir string) (map[string][]*build.Package, error) {
 	vendorDirAbsPath := vendorDir
 	if !filepath.IsAbs(vendorDir) {
 		wd, err := os.Getwd()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to determine working directory")
 		}
-		vendorDirAbsPath = path.Join(wd, vendorDir)
+		vendorDirAbsPath = filepath.Join(wd, vendorDir)
 	}
 
-	if path.Base(vendorDirAbsPath) != "vendor" {
-		return nil, errors.Errorf("provided path must be a directory named 'vendor', was %s", vendorDirAbsPath)
+	if filepath.Base(vendorDirAbsPath) != r.vendorDirName {
+		return nil, errors.Wrapf(ErrNoVendorDir, "provided path must be a directory named '%s', was %s", r.vendorDirName, vendorDirAbsPath)
 	}
-	if fi, err := os.Stat(vendorDirAbsPath); err != nil {
+	if fi, err := os.Stat(vendorDirAbsPath); os.IsNotExist(err) {
+		return nil, errors.Wrapf(ErrNoVendorDir, "path %s does not exist", vendorDirAbsPath)
+	} else if err != nil {
 		return nil, errors.Wrapf(err, "failed to stat %s", vendorDirAbsPath)
 	} else if !fi.IsDir() {
-		return nil, errors.Errorf("path %s is not a directory", vendorDirAbsPath)
+		return nil, errors.Wrapf(ErrNoVendorDir, "path %s is not a directory", vendorDirAbsPath)
 	}
 
-	pkgImportPaths := make(map[string]struct{})
-	if err := filepath.Walk(vendorDirAbsPath, func(path string, info os.FileInfo, err error) error {
+	var dirs []string
+	if err := walk(vendorDirAbsPath, r.followSymlinks, func(path string, info os.FileInfo, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err != nil && (info == nil || info.IsDir()) {
+			// directory could not be read (errors for files, such as broken symbolic links, are not relevant)
+			if r.skippedDirs == nil || path == vendorDirAbsPath {
+				return err
+			}
+			if r.logger != nil {
+				r.logger.Printf("skipping %s: %v", path, err)
+			}
+			r.skippedDirs[path] = err
+			if len(dirs) > 0 && dirs[len(dirs)-1] == path {
+				// directory was provided before it was read: do not examine it
+				dirs = dirs[:len(dirs)-1]
+			}
+			if info == nil {
+				return nil
+			}
+			return filepath.SkipDir
+		}
 		if !info.IsDir() {
 			return nil
 		}
+		if _, ok := r.skipDirs[info.Name()]; ok && path != vendorDirAbsPath {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "failed to walk directory")
+	}
 
-		buildPkgs, err := getPkgsInDir(".", path, make(map[string]struct{}))
-		if err != nil {
-			return errors.Wrapf(err, "failed to get packages in directory %s", path)
+	pkgsInDirs, err := pkgsInDirs(ctx, r, dirs)
+	if err != nil {
+		return nil, err
+	}
+	pkgImportPaths := make(map[string][]*build.Package)
+	for i, path := range dirs {
+		retained := r.retainedPkgs != nil && dirContainsAnyFile(path, r.retainWithFiles)
+		for importPath, pkgs := range pkgsByImportPath(pkgsInDirs[i]) {
+			if r.projectImportPath != "" && isWithinImportPath(outputImportPath(importPath, false, r.vendorDirName), r.projectImportPath) {
+				if r.logger != nil {
+					r.logger.Printf("%s is a vendored copy of a package of project %s: excluding from vendored packages", importPath, r.projectImportPath)
+				}
+				continue
+			}
+			pkgImportPaths[importPath] = pkgs
+			if retained {
+				r.retainedPkgs[importPath] = struct{}{}
+			}
 		}
+	}
+	return pkgImportPaths, nil
+}
 
-		dirContainsPkg := false
-		for _, pkg := range buildPkgs {
-			if pkg.Name != "" {
-				dirContainsPkg = true
-				break
+// pkgsByImportPath returns a map from import path to the provided packages (which are the packages in a single
+// directory) with that import path. The packages in a directory usually all have the same import path, but all of the
+// distinct import paths are recorded so that the result does not depend on the order in which the packages were
+// determined. The order of the packages for an import path is preserved. Import paths for which none of the packages
+// have a name and the import path "." (which can occur in directories like "testdata") are omitted.
+func pkgsByImportPath(buildPkgs []*build.Package) map[string][]*build.Package {
+	pkgs := make(map[string][]*build.Package)
+	namedImportPaths := make(map[string]struct{})
+	for _, pkg := range buildPkgs {
+		if pkg.ImportPath == "" || pkg.ImportPath == "." {
+			continue
+		}
+		pkgs[pkg.ImportPath] = append(pkgs[pkg.ImportPath], pkg)
+		if pkg.Name != "" {
+			namedImportPaths[pkg.ImportPath] = struct{}{}
+		}
+	}
+	for importPath := range pkgs {
+		if _, ok := namedImportPaths[importPath]; !ok {
+			delete(pkgs, importPath)
+		}
+	}
+	return pkgs
+}
+
+// pkgsInDirs returns the packages in each of the provided directories. The returned slice has the same length and
+// order as the provided directories. If r.maxOpenFiles is greater than 1, up to that many directories are read
+// concurrently.
+func pkgsInDirs(ctx context.Context, r *resolver, dirs []string) ([][]*build.Package, error) {
+	pkgs := make([][]*build.Package, len(dirs))
+	errs := make([]error, len(dirs))
+	if r.maxOpenFiles <= 1 {
+		for i, dir := range dirs {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			pkgs[i], errs[i] = getPkgsInDir(r, ".", dir, make(map[string]struct{}))
+			if errs[i] != nil {
+				return nil, errors.Wrapf(errs[i], "failed to get packages in directory %s", dir)
 			}
 		}
+		return pkgs, nil
+	}
 
-		// latter can happen in directories like "testdata"
-		if !dirContainsPkg || buildPkgs[0].ImportPath == "." {
-			return nil
+	sem := make(chan struct{}, r.maxOpenFiles)
+	var wg sync.WaitGroup
+	for i, dir := range dirs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, dir string) {
+			defer wg.Done()
+			defer func() {
+				<-sem
+			}()
+			if errs[i] = ctx.Err(); errs[i] != nil {
+				return
+			}
+			pkgs[i], errs[i] = getPkgsInDir(r, ".", dir, make(map[string]struct{}))
+		}(i, dir)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for i, err := range errs {
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get packages in directory %s", dirs[i])
 		}
+	}
+	return pkgs, nil
+}
 
-		pkgImportPaths[buildPkgs[0].ImportPath] = struct{}{}
-		return nil
-	}); err != nil {
-		return nil, errors.Wrapf(err, "failed to walk directory")
+// dirContainsAnyFile returns true if the provided directory contains a file with any of the provided names.
+func dirContainsAnyFile(dir string, names []string) bool {
+	for _, name := range names {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && !fi.IsDir() {
+			return true
+		}
 	}
-	return pkgImportPaths, nil
+	return false
+}
+
+// walk walks the file tree rooted at root in the manner of filepath.Walk. If followSymlinks is true, symbolic links to
+// directories (including root) are followed and the files in the linked directory are provided to walkFn with paths
+// within the link. Directories are visited at most once, which guards against cycles of links.
+func walk(root string, followSymlinks bool, walkFn filepath.WalkFunc) error {
+	if !followSymlinks {
+		return filepath.Walk(root, walkFn)
+	}
+	var visited []os.FileInfo
+	return walkFollowingSymlinks(root, &visited, walkFn)
+}
+
+func walkFollowingSymlinks(root string, visited *[]os.FileInfo, walkFn filepath.WalkFunc) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return walkFn(root, nil, err)
+	}
+	return filepath.Walk(realRoot, func(realPath string, info os.FileInfo, err error) error {
+		path := root + strings.TrimPrefix(realPath, realRoot)
+		if err != nil {
+			return walkFn(path, info, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			targetInfo, err := os.Stat(realPath)
+			if err != nil || !targetInfo.IsDir() {
+				// broken links and links to files are provided as-is
+				return walkFn(path, info, err)
+			}
+			return walkFollowingSymlinks(path, visited, walkFn)
+		}
+		if info.IsDir() {
+			for _, visitedInfo := range *visited {
+				if os.SameFile(info, visitedInfo) {
+					return filepath.SkipDir
+				}
+			}
+			*visited = append(*visited, info)
+		}
+		return walkFn(path, info, err)
+	})
+}
+
+// emptyVendoredDirs returns the sorted paths of the directories in the provided vendor directory that do not contain any
+// Go files, either directly or in any of their subdirectories. Such directories are typically left over from partial
+// vendoring. If a directory is returned, none of its subdirectories are.
+func emptyVendoredDirs(ctx context.Context, vendorDir string) ([]string, error) {
+	var emptyDirs []string
+	if _, err := collectEmptyDirs(ctx, vendorDir, true, &emptyDirs); err != nil {
+		return nil, err
+	}
+	sort.Strings(emptyDirs)
+	return emptyDirs, nil
+}
+
+// collectEmptyDirs returns true if the provided directory or any of its subdirectories contain a Go file. The
+// subdirectories of dir that do not contain any Go files are added to emptyDirs if dir contains Go files or isRoot is
+// true (otherwise, dir itself will be recorded by the caller).
+func collectEmptyDirs(ctx context.Context, dir string, isRoot bool, emptyDirs *[]string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read directory %s", dir)
+	}
+
+	containsGoFiles := false
+	var subdirsWithoutGoFiles []string
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			if strings.HasSuffix(fi.Name(), ".go") {
+				containsGoFiles = true
+			}
+			continue
+		}
+		subdir := filepath.Join(dir, fi.Name())
+		subdirContainsGoFiles, err := collectEmptyDirs(ctx, subdir, false, emptyDirs)
+		if err != nil {
+			return false, err
+		}
+		if subdirContainsGoFiles {
+			containsGoFiles = true
+		} else {
+			subdirsWithoutGoFiles = append(subdirsWithoutGoFiles, subdir)
+		}
+	}
+	if containsGoFiles || isRoot {
+		*emptyDirs = append(*emptyDirs, subdirsWithoutGoFiles...)
+	}
+	return containsGoFiles, nil
 }
 
-func allImportsInPkg(pkgDir, projectDir string) (map[string]struct{}, error) {
-	imps, err := getAllImports(".", pkgDir, projectDir, make(map[string]struct{}), true)
+func allImportsInPkg(ctx context.Context, r *resolver, pkgDir, projectDir string, includeTests bool) (map[string]struct{}, error) {
+	imps, err := getAllImports(ctx, r, ".", pkgDir, projectDir, make(map[string]struct{}), includeTests, 0)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get all imports for package in directory %s in project %s", pkgDir, projectDir)
 	}
@@ -257,11 +2680,13 @@ func allImportsInPkg(pkgDir, projectDir string) (map[string]struct{}, error) {
 
 // getAllImports takes an import and returns all of the packages that it imports (excluding standard library packages).
 // Includes all transitive imports and the package of the import itself. Assumes that the import occurs in a package in
-// "srcDir". If the "test" parameter is "true", considers all imports in the test files for the package as well.
-func getAllImports(importPkgPath, srcDir, projectRoot string, examinedImports map[string]struct{}, includeTests bool) (map[string]struct{}, error) {
+// "srcDir". If the "test" parameter is "true", considers all imports in the test files for the package as well. "depth"
+// is the depth of the import in the import graph: if it is the maximum depth of the resolver, the packages of the
+// import are returned but their imports are not examined.
+func getAllImports(ctx context.Context, r *resolver, importPkgPath, srcDir, projectRoot string, examinedImports map[string]struct{}, includeTests bool, depth int) (map[string]struct{}, error) {
 	importedPkgs := make(map[string]struct{})
 
-	pkgs, err := getPkgsInDir(importPkgPath, srcDir, examinedImports)
+	pkgs, err := getPkgsInDir(r, importPkgPath, srcDir, examinedImports)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get packages in package %s", importPkgPath)
 	}
@@ -269,27 +2694,59 @@ func getAllImports(importPkgPath, srcDir, projectRoot string, examinedImports ma
 	origSrcDir := srcDir
 	for _, pkg := range pkgs {
 		importedPkgs[pkg.ImportPath] = struct{}{}
+		if r.maxDepth > 0 && depth >= r.maxDepth {
+			// at maximum depth: record package but do not examine its imports. Package is not marked as examined so
+			// that its imports are examined if it is encountered at a shallower depth later.
+			continue
+		}
 		examinedImports[pkg.ImportPath] = struct{}{}
+		if r.logger != nil {
+			r.logger.Printf("examining package %s in %s", pkg.ImportPath, pkg.Dir)
+		}
 
 		currPkgImports := pkg.Imports
+		currPkgIncludesTests := false
 		if rel, err := filepath.Rel(projectRoot, pkg.Dir); err == nil && !strings.HasPrefix(rel, "../") {
 			// if import is internal, update "srcDir" to be pkg.Dir to ensure that resolution is done against the
 			// last internal package that was encountered
 			srcDir = pkg.Dir
-			if includeTests {
+			if includeTests && r.isProjectPkg(pkg) {
 				// if import is internal and includeTests is true, consider imports from test files
 				currPkgImports = append(currPkgImports, pkg.TestImports...)
 				currPkgImports = append(currPkgImports, pkg.XTestImports...)
+				currPkgIncludesTests = true
 			}
 		}
 
+		var namedImports map[string]bool
+		if r.importNames != nil {
+			namedImports = importNamesInPkg(pkg, currPkgIncludesTests, r.overlay)
+		}
+
 		// add packages from imports (don't examine transitive test dependencies)
 		for _, currImport := range currPkgImports {
-			if _, ok := examinedImports[currImport]; ok {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			r.recordImport(pkg.ImportPath, currImport, srcDir)
+			if r.notVendored != nil && srcDir == pkg.Dir {
+				r.recordNotVendoredImport(pkg, currImport, projectRoot)
+			}
+			if r.importNames != nil {
+				r.recordImportName(r.canonicalImportPath(currImport, srcDir), namedImports[currImport])
+			}
+			// check whether the package that the import resolves to has been examined rather than the import itself:
+			// the import may resolve to a vendored copy of a package that was examined under the same import path
+			// (for example, if a vendored dependency imports a package of the project that is also vendored)
+			canonicalImport := r.canonicalImportPath(currImport, srcDir)
+			if r.logger != nil {
+				r.logger.Printf("resolved import %s of package %s to %s", currImport, pkg.ImportPath, canonicalImport)
+			}
+			if _, ok := examinedImports[canonicalImport]; ok {
 				continue
 			}
 
-			currImportedPkgs, err := getAllImports(currImport, srcDir, projectRoot, examinedImports, false)
+			currImportedPkgs, err := getAllImports(ctx, r, currImport, srcDir, projectRoot, examinedImports, false, depth+1)
 			if err != nil {
 				return nil, errors.Wrapf(err, "failed to get all imports for %s", currImport)
 			}
@@ -304,27 +2761,124 @@ func getAllImports(importPkgPath, srcDir, projectRoot string, examinedImports ma
 	return importedPkgs, nil
 }
 
-func getPkgsInDir(importPkgPath, srcDir string, examinedImports map[string]struct{}) ([]*build.Package, error) {
+// importNamesInPkg returns a map from the import paths imported by the files of the provided package to whether the
+// import path is imported using a named (non-blank) import in any of the files. If includeTests is true, the test
+// files of the package are also considered. The import declarations are parsed from the files because build.Package
+// does not record the names of imports. Files that cannot be parsed are skipped. The contents of the files in the
+// provided overlay are used instead of their contents on disk.
+func importNamesInPkg(pkg *build.Package, includeTests bool, overlay map[string][]byte) map[string]bool {
+	files := append(append([]string{}, pkg.GoFiles...), pkg.CgoFiles...)
+	if includeTests {
+		files = append(files, pkg.TestGoFiles...)
+		files = append(files, pkg.XTestGoFiles...)
+	}
+	namedImports := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, file := range files {
+		filename := filepath.Join(pkg.Dir, file)
+		var src interface{}
+		if content, ok := overlayContent(overlay, filename); ok {
+			src = content
+		}
+		astFile, err := parser.ParseFile(fset, filename, src, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, spec := range astFile.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			namedImports[importPath] = namedImports[importPath] || spec.Name == nil || spec.Name.Name != "_"
+		}
+	}
+	return namedImports
+}
+
+// vendoredTestDepPkgs returns the import paths of the packages that are transitively imported by the test files of
+// the provided vendored packages. The imports of the test files of the packages that are reached are not examined. The
+// imports are determined using a copy of the provided resolver that does not record any information, so the result of
+// the analysis is not affected.
+func vendoredTestDepPkgs(ctx context.Context, r *resolver, vendoredImportPaths []string, projectDir string) (map[string]struct{}, error) {
+	testResolver := *r
+	testResolver.warnings = nil
+	testResolver.imports = nil
+	testResolver.importNames = nil
+	testResolver.stdlibImports = nil
+	testResolver.notVendored = nil
+
+	testDeps := make(map[string]struct{})
+	examinedImports := make(map[string]struct{})
+	for _, importPath := range vendoredImportPaths {
+		pkgs, err := getPkgsInDir(&testResolver, importPath, "", make(map[string]struct{}))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get packages in vendored package %s", importPath)
+		}
+		for _, pkg := range pkgs {
+			for _, testImport := range append(append([]string{}, pkg.TestImports...), pkg.XTestImports...) {
+				imps, err := getAllImports(ctx, &testResolver, testImport, pkg.Dir, projectDir, examinedImports, false, 1)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to get all imports for test import %s of vendored package %s", testImport, importPath)
+				}
+				for k := range imps {
+					testDeps[k] = struct{}{}
+				}
+			}
+		}
+	}
+	return testDeps, nil
+}
+
+func getPkgsInDir(r *resolver, importPkgPath, srcDir string, examinedImports map[string]struct{}) ([]*build.Package, error) {
+	pkgs, _, err := pkgsInDir(r, importPkgPath, srcDir, examinedImports)
+	return pkgs, err
+}
+
+// pkgsInDir returns the packages for the provided import and the number of times the directory of the import was
+// imported to determine them. If the directory contains multiple packages, it is imported repeatedly while ignoring
+// the files of the packages that have already been determined. Every iteration ignores at least one more file, so the
+// number of iterations is bounded by the number of Go files in the directory: an error is returned if this bound is
+// exceeded so that an unexpected combination of files can never cause the analysis to hang.
+func pkgsInDir(r *resolver, importPkgPath, srcDir string, examinedImports map[string]struct{}) ([]*build.Package, int, error) {
 	if !strings.Contains(importPkgPath, ".") {
 		// if package is a standard package, return empty
-		return nil, nil
+		r.recordStdlibImport(importPkgPath)
+		return nil, 0, nil
 	}
 
 	var pkgs []*build.Package
 	ctxIgnoreFiles := make(map[string]struct{})
+	maxIterations := -1
+	iterations := 0
 	for {
+		if maxIterations != -1 && iterations >= maxIterations {
+			return nil, iterations, errors.Errorf("failed to determine packages for %s in %s: exceeded maximum of %d iterations", importPkgPath, srcDir, maxIterations)
+		}
+		iterations++
+
 		// ignore error because doImport returns partial object even on error. As long as an ImportPath is present,
 		// proceed with determining imports. Perform the import using the provided ctxIgnoreFiles.
-		pkg, pkgErr := doImport(importPkgPath, srcDir, build.ImportComment, ctxIgnoreFiles)
+		pkg, pkgErr := doImport(r, importPkgPath, srcDir, build.ImportComment, ctxIgnoreFiles)
+		if errors.Cause(pkgErr) == ErrNoRequiredModule && r.modMode == ModReadonly {
+			return nil, iterations, pkgErr
+		}
 		if pkg.ImportPath == "" {
 			break
 		}
+		if maxIterations == -1 {
+			// one iteration for every file plus the final iteration
+			maxIterations = len(pkg.GoFiles) + len(pkg.CgoFiles) + len(pkg.TestGoFiles) + len(pkg.XTestGoFiles) + len(pkg.InvalidGoFiles) + len(pkg.IgnoredGoFiles) + 1
+		}
 
 		// skip if package has already been examined
 		if _, ok := examinedImports[pkg.ImportPath]; ok {
 			break
 		}
 
+		if pkgErr != nil {
+			r.recordWarning(pkg, pkgErr)
+		}
+
 		if _, ok := pkgErr.(*build.MultiplePackageError); !ok {
 			// only one package in directory: add it and finish
 			pkgs = append(pkgs, pkg)
@@ -354,14 +2908,14 @@ func getPkgsInDir(importPkgPath, srcDir string, examinedImports map[string]struc
 			break
 		}
 
-		if pkg, _ := doImport(importPkgPath, srcDir, build.ImportComment, combineMaps(ctxIgnoreFiles, invalidFilesMap)); pkg.ImportPath != "" {
+		if pkg, _ := doImport(r, importPkgPath, srcDir, build.ImportComment, combineMaps(ctxIgnoreFiles, invalidFilesMap)); pkg.ImportPath != "" {
 			pkgs = append(pkgs, pkg)
 		}
 
 		// ignore files that were processed in this iteration in next iteration
 		ctxIgnoreFiles = combineMaps(ctxIgnoreFiles, validGoFiles)
 	}
-	return pkgs, nil
+	return pkgs, iterations, nil
 }
 
 func combineMaps(m1, m2 map[string]struct{}) map[string]struct{} {
@@ -375,35 +2929,568 @@ func combineMaps(m1, m2 map[string]struct{}) map[string]struct{} {
 	return out
 }
 
-// allContext is a build.Context based on build.Default that has "UseAllFiles" set to true. Makes it such that analysis
-// is done on all Go files rather than on just those that match the default build context.
-var allContext = getAllContext()
+// resolver resolves packages using the build context and vendor directory name specified by a Param.
+type resolver struct {
+	ctx           build.Context
+	vendorDirName string
+	// projectImportPath is the import path of the project. If empty, the import path of the project is not known.
+	projectImportPath string
+	// overlay is a map from cleaned absolute file path to the contents that should be used for the file instead of its
+	// contents on disk.
+	overlay map[string][]byte
+	// maxDepth is the maximum depth of the import graph that is traversed. If 0, the depth is not limited.
+	maxDepth int
+	// followSymlinks specifies whether symbolic links to directories are followed when walking vendor directories.
+	followSymlinks bool
+	// skipDirs are the base names of the directories that are skipped when walking vendor directories.
+	skipDirs map[string]struct{}
+	// warnings is a map from directory to the error that occurred when importing the package in that directory. Only
+	// non-nil if warnings should be collected.
+	warnings map[string]error
+	// skippedDirs is a map from directory to the error that occurred when reading the directory while walking a vendor
+	// directory. Only non-nil if directories that cannot be read should be skipped.
+	skippedDirs map[string]error
+	// warningsMu guards warnings, which may be recorded concurrently.
+	warningsMu *sync.Mutex
+	// maxOpenFiles is the maximum number of directories that are read concurrently. If less than or equal to 1,
+	// directories are read sequentially.
+	maxOpenFiles int
+	// imports is a map from the import path of a package to the import paths of the packages that it imports. Only
+	// non-nil if the import graph should be collected.
+	imports map[string]map[string]struct{}
+	// logger is used to log the steps of the analysis. If nil, nothing is logged.
+	logger *log.Logger
+	// importNames is a map from the import path of each imported package to whether the package is imported using a
+	// named (non-blank) import by any package. Only non-nil if the kinds of imports should be tracked.
+	importNames map[string]bool
+	// retainWithFiles are the names of the files that cause a vendored package to be retained.
+	retainWithFiles []string
+	// retainedPkgs is the set of import paths of the vendored packages whose directories contain one of the files in
+	// retainWithFiles. Only non-nil if retainWithFiles is non-empty.
+	retainedPkgs map[string]struct{}
+	// stdlibImports is the set of standard library packages that are imported. Only non-nil if standard library imports
+	// should be tracked.
+	stdlibImports map[string]struct{}
+	// notVendored is a map from import path to the positions ("file:line") of the import statements of project
+	// packages that import it when it is not vendored. Only non-nil if imports that are not vendored should be tracked.
+	notVendored map[string]map[string]token.Position
+	// replacements is a map from module path to the absolute path of the local directory that replaces it, as
+	// specified by the "replace" directives of the go.mod file of the project.
+	replacements map[string]string
+	// modMode is the mode in which imports of packages provided by modules are resolved.
+	modMode ModMode
+	// modulePath is the path of the module declared by the go.mod file of the project.
+	modulePath string
+	// moduleDirs is a map from the path of each module required by the go.mod file of the project to the directory of
+	// the required version of the module in the module cache. If modMode is ModVendor, it is instead a map from the
+	// path of each module listed in the "modules.txt" file of the vendor directory of the project to the directory of
+	// the module in the vendor directory. Only non-nil if modMode is not ModVendor or if the project vendors modules.
+	moduleDirs map[string]string
+	// modVendorImportPath is the import path of the vendor directory of the project. Packages resolved in the module
+	// cache are identified by the import path that they would have in this directory so that they can be matched
+	// against the vendored packages. Only set if modMode is not ModVendor or if the project vendors modules.
+	modVendorImportPath string
+	// mainModuleDir is the absolute path of the directory of the main module. If set, packages in the directory are
+	// identified by their import paths within modulePath rather than by their location in the GOPATH. Only set if
+	// modMode is ModVendor and the project vendors modules.
+	mainModuleDir string
+}
+
+func newResolver(param Param) *resolver {
+	r := &resolver{
+		ctx:               getAllContext(param),
+		vendorDirName:     param.vendorDirName(),
+		maxDepth:          param.maxDepth(),
+		followSymlinks:    param.FollowSymlinks,
+		skipDirs:          param.skipDirs(),
+		logger:            param.Logger,
+		warningsMu:        &sync.Mutex{},
+		maxOpenFiles:      param.MaxOpenFiles,
+		projectImportPath: strings.TrimSuffix(param.ProjectImportPath, "/"),
+		overlay:           normalizedOverlay(param.Overlay),
+		modMode:           param.modMode(),
+	}
+	if param.ReportWarnings {
+		r.warnings = make(map[string]error)
+	}
+	if param.ContinueOnWalkError {
+		r.skippedDirs = make(map[string]error)
+	}
+	if param.Format == FormatDOT || param.collectImports {
+		r.imports = make(map[string]map[string]struct{})
+	}
+	if param.TrackStdlib {
+		r.stdlibImports = make(map[string]struct{})
+	}
+	if param.ReportBlankOnly {
+		r.importNames = make(map[string]bool)
+	}
+	if param.ReportNotVendored {
+		r.notVendored = make(map[string]map[string]token.Position)
+	}
+	if len(param.RetainWithFiles) > 0 {
+		r.retainWithFiles = param.RetainWithFiles
+		r.retainedPkgs = make(map[string]struct{})
+	}
+	return r
+}
+
+// recordWarning records the provided error that occurred while importing the provided package as a warning if the
+// resolver is collecting warnings. The error is recorded as a *PackageParseError. Errors that are expected as part of
+// normal analysis (directories that do not contain Go files, directories with multiple packages and packages that could
+// not be located) are not recorded.
+func (r *resolver) recordWarning(pkg *build.Package, err error) {
+	if r.warnings == nil || pkg.Dir == "" {
+		return
+	}
+	switch err.(type) {
+	case *build.NoGoError, *build.MultiplePackageError:
+		return
+	}
+	r.warningsMu.Lock()
+	defer r.warningsMu.Unlock()
+	if _, ok := r.warnings[pkg.Dir]; !ok {
+		r.warnings[pkg.Dir] = &PackageParseError{
+			Dir: pkg.Dir,
+			Err: err,
+		}
+	}
+}
+
+// forPlatform returns a copy of the resolver whose build context uses the provided GOOS and GOARCH and only considers
+// the files that match the build constraints of the context. Warnings and imports recorded by the returned resolver are
+// recorded in this resolver.
+func (r *resolver) forPlatform(goos, goarch string) *resolver {
+	platformResolver := *r
+	platformResolver.ctx.GOOS = goos
+	platformResolver.ctx.GOARCH = goarch
+	platformResolver.ctx.UseAllFiles = false
+	return &platformResolver
+}
+
+// withOverride returns a copy of the resolver whose build context has the values specified by the provided override.
+// The returned resolver only uses the files that match the build constraints of the context.
+func (r *resolver) withOverride(override BuildContextOverride) *resolver {
+	overrideResolver := *r
+	if override.GOOS != "" {
+		overrideResolver.ctx.GOOS = override.GOOS
+	}
+	if override.GOARCH != "" {
+		overrideResolver.ctx.GOARCH = override.GOARCH
+	}
+	if override.BuildTags != nil {
+		overrideResolver.ctx.BuildTags = override.BuildTags
+	}
+	overrideResolver.ctx.UseAllFiles = false
+	return &overrideResolver
+}
+
+// setModules configures the resolver to resolve imports of packages provided by the modules required by the go.mod
+// file in the provided project directory against the module cache.
+func (r *resolver) setModules(projectDir string, param Param) error {
+	modulePath, requires, err := requiredModules(projectDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to determine required modules for project %s", projectDir)
+	}
+	if modulePath == "" {
+		return errors.Errorf("module mode %s requires a go.mod file in project %s", r.modMode, projectDir)
+	}
+	modCacheDir := param.modCacheDir(r.ctx.GOPATH)
+	r.modulePath = modulePath
+	r.moduleDirs = make(map[string]string)
+	for modPath, version := range requires {
+		r.moduleDirs[modPath] = moduleCacheDir(modCacheDir, modPath, version)
+	}
+	r.modVendorImportPath = pkgImportPath(r, filepath.Join(projectDir, r.vendorDirName))
+	return nil
+}
+
+// setVendoredModules configures the resolver to resolve imports using module semantics if the project in the provided
+// directory has a go.mod file and a vendor directory with a "modules.txt" file: imports of packages of the main module
+// are resolved in the project directory and imports of packages of the vendored modules are resolved in the vendor
+// directory of the project. Does nothing if the project does not vendor modules.
+func (r *resolver) setVendoredModules(projectDir string) error {
+	modulePath, _, err := requiredModules(projectDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to determine module of project %s", projectDir)
+	}
+	if modulePath == "" {
+		return nil
+	}
+	vendorDir := filepath.Join(projectDir, r.vendorDirName)
+	modPaths, err := vendoredModules(vendorDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to determine vendored modules of project %s", projectDir)
+	}
+	if modPaths == nil {
+		return nil
+	}
+	if r.logger != nil {
+		r.logger.Printf("resolving imports of module %s using the %d module(s) vendored in %s", modulePath, len(modPaths), vendorDir)
+	}
+	r.modulePath = modulePath
+	r.mainModuleDir = projectDir
+	r.moduleDirs = make(map[string]string)
+	for _, modPath := range modPaths {
+		r.moduleDirs[modPath] = filepath.Join(vendorDir, filepath.FromSlash(modPath))
+	}
+	r.modVendorImportPath = modulePath + "/" + r.vendorDirName
+	return nil
+}
+
+// mainModuleImportPath returns the import path of the package in the provided directory if the directory is within
+// the directory of the main module. Returns false if the resolver does not resolve imports of a main module or if the
+// directory is not within the directory of the main module.
+func (r *resolver) mainModuleImportPath(dir string) (string, bool) {
+	if r.mainModuleDir == "" || dir == "" {
+		return "", false
+	}
+	relPath, err := filepath.Rel(r.mainModuleDir, dir)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if relPath == "." {
+		return r.modulePath, true
+	}
+	return r.modulePath + "/" + filepath.ToSlash(relPath), true
+}
+
+// isModuleImport returns true if the provided import path must be provided by a module required by the project: that
+// is, if it is not a standard library package, a relative import, a vendored import path or a package of the project.
+func (r *resolver) isModuleImport(importPath string) bool {
+	if !strings.Contains(importPath, ".") || build.IsLocalImport(importPath) || strings.Contains(importPath, "/"+r.vendorDirName+"/") {
+		return false
+	}
+	return !isWithinImportPath(importPath, r.modulePath)
+}
+
+// moduleDir returns the directory in the module cache of the package with the provided import path if the import path is
+// provided by a module required by the project. Returns false if the import path is not provided by a required module.
+// If multiple required modules provide the import path, the one with the longest module path is used.
+func (r *resolver) moduleDir(importPath string) (string, bool) {
+	longestModPath := ""
+	for modPath := range r.moduleDirs {
+		if isWithinImportPath(importPath, modPath) && len(modPath) > len(longestModPath) {
+			longestModPath = modPath
+		}
+	}
+	if longestModPath == "" {
+		return "", false
+	}
+	return filepath.Join(r.moduleDirs[longestModPath], filepath.FromSlash(strings.TrimPrefix(importPath, longestModPath))), true
+}
+
+// replacedDir returns the directory of the package with the provided import path if the import path is provided by a
+// module that is replaced by a local directory. Returns false if the import path is not provided by a replaced module.
+// If multiple replaced modules provide the import path, the one with the longest module path is used.
+func (r *resolver) replacedDir(importPath string) (string, bool) {
+	longestModPath := ""
+	for modPath := range r.replacements {
+		if (importPath == modPath || strings.HasPrefix(importPath, modPath+"/")) && len(modPath) > len(longestModPath) {
+			longestModPath = modPath
+		}
+	}
+	if longestModPath == "" {
+		return "", false
+	}
+	return filepath.Join(r.replacements[longestModPath], filepath.FromSlash(strings.TrimPrefix(importPath, longestModPath))), true
+}
+
+// recordImport records that the package with the provided import path imports the provided import, where the import
+// occurs in a file in srcDir. The import is resolved to the import path of the package that it refers to (which may be
+// vendored). Does nothing if the resolver does not collect the import graph or if the import is a standard library
+// package.
+func (r *resolver) recordImport(importerPath, importPath, srcDir string) {
+	if r.imports == nil || !strings.Contains(importPath, ".") {
+		return
+	}
+	if r.imports[importerPath] == nil {
+		r.imports[importerPath] = make(map[string]struct{})
+	}
+	r.imports[importerPath][r.canonicalImportPath(importPath, srcDir)] = struct{}{}
+}
+
+// recordImportName records that the package with the provided import path is imported using a named import if named
+// is true or using a blank import otherwise. A package that is imported using a named import is never recorded as
+// imported using only blank imports.
+func (r *resolver) recordImportName(importPath string, named bool) {
+	r.importNames[importPath] = r.importNames[importPath] || named
+}
+
+// recordStdlibImport records the provided standard library import and the standard library packages that it
+// transitively imports. Does nothing if the resolver does not track standard library imports. The imports of standard
+// library packages are determined using the build constraints of the context of the resolver.
+func (r *resolver) recordStdlibImport(importPath string) {
+	if r.stdlibImports == nil || importPath == "C" {
+		return
+	}
+	if _, ok := r.stdlibImports[importPath]; ok {
+		return
+	}
+	r.stdlibImports[importPath] = struct{}{}
+
+	stdlibCtx := r.ctx
+	stdlibCtx.UseAllFiles = false
+	// ignore error because Import returns partial object even on error
+	pkg, _ := stdlibCtx.Import(importPath, "", 0)
+	for _, currImport := range pkg.Imports {
+		// imports with a "." are packages vendored in the standard library
+		if !strings.Contains(currImport, ".") {
+			r.recordStdlibImport(currImport)
+		}
+	}
+}
+
+// isProjectPkg returns true if the provided package, whose directory is within the project directory, should be
+// treated as a package of the project. If the import path of the project is not known, all such packages are treated
+// as project packages. Otherwise, only the packages whose import paths are the project import path or are within it
+// (and are not in a vendor directory) are project packages.
+func (r *resolver) isProjectPkg(pkg *build.Package) bool {
+	if r.projectImportPath == "" {
+		return true
+	}
+	return isWithinImportPath(pkg.ImportPath, r.projectImportPath) && !strings.Contains(pkg.ImportPath+"/", "/"+r.vendorDirName+"/")
+}
+
+// isWithinImportPath returns true if the provided import path is the provided parent import path or is within it.
+func isWithinImportPath(importPath, parentImportPath string) bool {
+	return importPath == parentImportPath || strings.HasPrefix(importPath, parentImportPath+"/")
+}
+
+// recordNotVendoredImport records the positions of the import statements for the provided import in the provided
+// package if the package is a project package (not a vendored package) and the import is not a standard library package
+// and does not resolve to a package in a vendor directory or in the provided project directory. Does nothing if the
+// resolver does not track imports that are not vendored. Positions are recorded as "file:line", where the file is
+// relative to the project directory.
+func (r *resolver) recordNotVendoredImport(pkg *build.Package, importPath, projectRoot string) {
+	if r.notVendored == nil || !strings.Contains(importPath, ".") {
+		return
+	}
+	if rel, err := filepath.Rel(projectRoot, pkg.Dir); err != nil || strings.Contains("/"+filepath.ToSlash(rel)+"/", "/"+r.vendorDirName+"/") {
+		// only imports of project packages are recorded
+		return
+	}
+	if importedPkg, err := doImport(r, importPath, pkg.Dir, build.FindOnly, nil); err == nil && importedPkg.Dir != "" {
+		if rel, err := filepath.Rel(projectRoot, importedPkg.Dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			// package is vendored or is a package of the project
+			return
+		}
+	}
+
+	positions := r.notVendored[importPath]
+	if positions == nil {
+		positions = make(map[string]token.Position)
+		r.notVendored[importPath] = positions
+	}
+	for _, posMap := range []map[string][]token.Position{pkg.ImportPos, pkg.TestImportPos, pkg.XTestImportPos} {
+		for _, pos := range posMap[importPath] {
+			if rel, err := filepath.Rel(projectRoot, pos.Filename); err == nil {
+				pos.Filename = rel
+			}
+			pos.Filename = filepath.ToSlash(pos.Filename)
+			positions[fmt.Sprintf("%s:%d", pos.Filename, pos.Line)] = pos
+		}
+	}
+}
+
+// sortedNotVendoredImports returns the imports that are not vendored recorded by the resolver sorted by import path.
+func (r *resolver) sortedNotVendoredImports() []NotVendoredImport {
+	if r.notVendored == nil {
+		return nil
+	}
+	var out []NotVendoredImport
+	for importPath, positions := range r.notVendored {
+		var sortedPositions []token.Position
+		for _, pos := range positions {
+			sortedPositions = append(sortedPositions, pos)
+		}
+		sort.Slice(sortedPositions, func(i, j int) bool {
+			if sortedPositions[i].Filename != sortedPositions[j].Filename {
+				return sortedPositions[i].Filename < sortedPositions[j].Filename
+			}
+			return sortedPositions[i].Line < sortedPositions[j].Line
+		})
+		notVendoredImport := NotVendoredImport{
+			ImportPath: importPath,
+		}
+		for _, pos := range sortedPositions {
+			notVendoredImport.Positions = append(notVendoredImport.Positions, fmt.Sprintf("%s:%d", pos.Filename, pos.Line))
+		}
+		out = append(out, notVendoredImport)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ImportPath < out[j].ImportPath
+	})
+	return out
+}
+
+// canonicalImportPath returns the import path of the package that the provided import resolves to when it occurs in a
+// file in srcDir. For example, if the import refers to a vendored package, the returned import path includes the vendor
+// directory. Returns the provided import path if it is a standard library package or cannot be resolved.
+func (r *resolver) canonicalImportPath(importPath, srcDir string) string {
+	if !strings.Contains(importPath, ".") {
+		return importPath
+	}
+	pkg, _ := doImport(r, importPath, srcDir, build.FindOnly, nil)
+	if pkg.ImportPath == "" {
+		return importPath
+	}
+	return pkg.ImportPath
+}
+
+// sortedWarnings returns the warnings recorded by the resolver sorted by directory.
+func (r *resolver) sortedWarnings() []Warning {
+	return sortedWarnings(r.warnings)
+}
+
+// sortedWarnings returns the warnings for the provided map from directory to error sorted by directory. Returns nil if
+// the provided map is nil.
+func sortedWarnings(errs map[string]error) []Warning {
+	if errs == nil {
+		return nil
+	}
+	var warnings []Warning
+	for dir, err := range errs {
+		warnings = append(warnings, Warning{
+			Dir: dir,
+			Err: err,
+		})
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[i].Dir < warnings[j].Dir
+	})
+	return warnings
+}
 
-func getAllContext() build.Context {
+// getAllContext returns a build.Context based on build.Default that has "UseAllFiles" set to true. Makes it such that
+// analysis is done on all Go files rather than on just those that match the default build context. If the provided
+// parameter specifies a custom vendor directory name, the context resolves vendored imports using directories with that
+// name rather than "vendor". If the provided parameter specifies whether cgo is enabled, build tags or a valid Go
+// version, those values are used.
+func getAllContext(param Param) build.Context {
 	ctx := build.Default
 	ctx.UseAllFiles = true
+	if param.CgoEnabled != nil {
+		ctx.CgoEnabled = *param.CgoEnabled
+	}
+	ctx.BuildTags = param.BuildTags
+	if param.GoVersion != "" {
+		if releaseTags, err := goReleaseTags(param.GoVersion); err == nil {
+			ctx.ReleaseTags = releaseTags
+		}
+	}
+	if param.GOPATH != "" {
+		ctx.GOPATH = param.GOPATH
+	}
+	setOverlay(&ctx, normalizedOverlay(param.Overlay))
+	if vendorDirName := param.vendorDirName(); vendorDirName != "vendor" {
+		ctx.JoinPath = func(elem ...string) string {
+			renamedElems := make([]string, len(elem))
+			for i, curr := range elem {
+				if curr == "vendor" {
+					curr = vendorDirName
+				}
+				renamedElems[i] = curr
+			}
+			return filepath.Join(renamedElems...)
+		}
+	}
 	return ctx
 }
 
-// doImport performs an "Import" operation. If "ignoreFiles" does not have any entries, it uses "allContext" to do the
-// import. Otherwise, it creates a new "all" context with a custom ReadDir function that ignores files with the names in
-// the provided map.
-func doImport(path, srcDir string, mode build.ImportMode, ignoreFiles map[string]struct{}) (*build.Package, error) {
-	if len(ignoreFiles) == 0 {
-		return allContext.Import(path, srcDir, mode)
+// goReleaseTags returns the release tags of the provided Go version: "go1.1" through "go1.N" for version "1.N". The
+// version may be prefixed with "go" and may specify a patch release, which does not affect the release tags.
+func goReleaseTags(version string) ([]string, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "go"), ".")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] != "1" {
+		return nil, errors.Errorf("Go version must be of the form 1.N or go1.N, was %q", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil || minor < 0 {
+		return nil, errors.Errorf("Go version must be of the form 1.N or go1.N, was %q", version)
 	}
+	if len(parts) == 3 {
+		if patch, err := strconv.Atoi(parts[2]); err != nil || patch < 0 {
+			return nil, errors.Errorf("Go version must be of the form 1.N or go1.N, was %q", version)
+		}
+	}
+	releaseTags := make([]string, 0, minor)
+	for i := 1; i <= minor; i++ {
+		releaseTags = append(releaseTags, fmt.Sprintf("go1.%d", i))
+	}
+	return releaseTags, nil
+}
 
-	ctx := getAllContext()
-	ctx.ReadDir = func(dir string) ([]os.FileInfo, error) {
-		files, err := ioutil.ReadDir(dir)
-		var filesToReturn []os.FileInfo
-		for _, curr := range files {
-			if _, ok := ignoreFiles[curr.Name()]; ok {
-				continue
+// doImport performs an "Import" operation using the context of the provided resolver. If "ignoreFiles" has entries,
+// the import is performed using a copy of the context with a custom ReadDir function that ignores files with the names
+// in the provided map. If the import path is provided by a module that is replaced by a local directory, the package in
+// the replacement directory is imported.
+func doImport(r *resolver, path, srcDir string, mode build.ImportMode, ignoreFiles map[string]struct{}) (*build.Package, error) {
+	ctx := r.ctx
+	if len(ignoreFiles) != 0 {
+		readDir := ioutil.ReadDir
+		if ctx.ReadDir != nil {
+			readDir = ctx.ReadDir
+		}
+		ctx.ReadDir = func(dir string) ([]os.FileInfo, error) {
+			files, err := readDir(dir)
+			var filesToReturn []os.FileInfo
+			for _, curr := range files {
+				if _, ok := ignoreFiles[curr.Name()]; ok {
+					continue
+				}
+				filesToReturn = append(filesToReturn, curr)
 			}
-			filesToReturn = append(filesToReturn, curr)
+			return filesToReturn, err
+		}
+	}
+	if r.moduleDirs != nil && r.isModuleImport(path) {
+		dir, ok := r.moduleDir(path)
+		if !ok {
+			return &build.Package{}, errors.Wrapf(ErrNoRequiredModule, "package %s", path)
 		}
-		return filesToReturn, err
+		// package is provided by a required module: import the directory in the module cache, but identify the
+		// package by the import path it would have in the vendor directory of the project
+		pkg, err := ctx.ImportDir(dir, mode)
+		pkg.ImportPath = r.modVendorImportPath + "/" + path
+		return pkg, err
+	}
+	if dir, ok := r.replacedDir(path); ok {
+		// package is provided by a module that is replaced by a local directory: import the directory, but retain the
+		// import path used in source so that the package is identified by that path
+		pkg, err := ctx.ImportDir(dir, mode)
+		pkg.ImportPath = path
+		return pkg, err
+	}
+	if r.mainModuleDir != "" && isWithinImportPath(path, r.modulePath) {
+		// package is provided by the main module: import the directory in the project directory
+		pkg, err := ctx.ImportDir(filepath.Join(r.mainModuleDir, filepath.FromSlash(strings.TrimPrefix(path, r.modulePath))), mode)
+		pkg.ImportPath = path
+		return pkg, err
+	}
+	if r.mainModuleDir != "" && build.IsLocalImport(path) {
+		// identify packages in the directory of the main module by their import paths within the main module, which
+		// the build package cannot determine for directories that are not in the GOPATH
+		pkg, err := ctx.Import(path, srcDir, mode)
+		if importPath, ok := r.mainModuleImportPath(pkg.Dir); ok {
+			pkg.ImportPath = importPath
+		}
+		return pkg, err
+	}
+	return r.fixVendoredImportPath(ctx.Import(path, srcDir, mode))
+}
+
+// fixVendoredImportPath updates the import path of the provided package to use the vendor directory name of the
+// resolver. Required because the build package always uses "vendor" in the import paths of vendored packages, even if
+// the package was resolved in a vendor directory with a different name. Returns the provided values.
+func (r *resolver) fixVendoredImportPath(pkg *build.Package, err error) (*build.Package, error) {
+	if r.vendorDirName == "vendor" || pkg == nil {
+		return pkg, err
+	}
+	vendorIdx := strings.LastIndex(pkg.ImportPath, "/vendor/")
+	if vendorIdx == -1 || !strings.Contains(filepath.ToSlash(pkg.Dir), "/"+r.vendorDirName+"/") {
+		return pkg, err
 	}
-	return ctx.Import(path, srcDir, mode)
+	pkg.ImportPath = pkg.ImportPath[:vendorIdx] + "/" + r.vendorDirName + "/" + pkg.ImportPath[vendorIdx+len("/vendor/"):]
+	return pkg, err
 }
@@ -15,14 +15,28 @@
 package novendor_test
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
+	"go/build"
 	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nmiyake/pkg/dirs"
 	"github.com/nmiyake/pkg/gofiles"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -611,3 +625,6860 @@ github.com/org/library/bar
 		assert.Equal(t, wantIncludeVendor, buf.String(), "Case %d (%s)", i, tc.name)
 	}
 }
+
+func TestNovendorExcludeTestImports(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	files := []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package foo; import _ "github.com/fooimport";`,
+		},
+		{
+			RelPath: "foo_ext_test.go",
+			Src:     `package foo_test; import _ "github.com/fooexttestimport";`,
+		},
+		{
+			RelPath: "foo_test.go",
+			Src:     `package foo; import _ "github.com/footestimport";`,
+		},
+		{
+			RelPath: "vendor/github.com/fooimport/fooimport.go",
+			Src:     `package fooimport`,
+		},
+		{
+			RelPath: "vendor/github.com/fooexttestimport/fooexttestimport.go",
+			Src:     `package fooexttestimport`,
+		},
+		{
+			RelPath: "vendor/github.com/footestimport/footestimport.go",
+			Src:     `package footestimport; import _ "github.com/transitiveimport"`,
+		},
+		{
+			RelPath: "vendor/github.com/transitiveimport/transitiveimport.go",
+			Src:     `package transitiveimport`,
+		},
+	}
+
+	for i, tc := range []struct {
+		name               string
+		includeTestImports bool
+		want               string
+	}{
+		{
+			name:               "test imports are considered",
+			includeTestImports: true,
+			want:               "",
+		},
+		{
+			name:               "test-only imports are reported as unused when test imports are excluded",
+			includeTestImports: false,
+			want: `github.com/fooexttestimport
+github.com/footestimport
+github.com/transitiveimport
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, tc.name)
+
+		_, err = gofiles.Write(projectDir, files)
+		require.NoError(t, err, "Case %d (%s)", i, tc.name)
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			ExcludeTestImports: !tc.includeTestImports,
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, tc.name)
+		assert.Equal(t, tc.want, buf.String(), "Case %d (%s)", i, tc.name)
+	}
+}
+
+func TestNovendorShowImporters(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library/bar"; import _ "{{index . "subdir/subdir.go"}}";`,
+		},
+		{
+			RelPath: "subdir/subdir.go",
+			Src:     `package subdir; import _ "github.com/org/library/baz";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/baz/baz.go",
+			Src:     `package baz`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		ShowImporters: true,
+	}
+	pkgs := []string{
+		projectDir + "/.",
+		projectDir + "/subdir",
+	}
+
+	result, err := novendor.Analyze(projectDir, pkgs, param)
+	require.NoError(t, err)
+
+	rootPkg := fmt.Sprintf("%s/%s", currPkgName, projectDir)
+	subdirPkg := fmt.Sprintf("%s/%s/subdir", currPkgName, projectDir)
+	assert.Equal(t, map[string][]string{
+		rootPkg + "/vendor/github.com/org/library/bar": {rootPkg},
+		rootPkg + "/vendor/github.com/org/library/baz": {rootPkg, subdirPkg},
+	}, result.Importers)
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, pkgs, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(`github.com/org/library/unused
+used: github.com/org/library/bar (imported by %s)
+used: github.com/org/library/baz (imported by %s, %s)
+`, rootPkg, rootPkg, subdirPkg), buf.String())
+}
+
+func TestNovendorSummary(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, tc := range []struct {
+		name  string
+		files []gofiles.GoFileSpec
+		pkgs  func(projectDir string) []string
+		want  string
+	}{
+		{
+			name: "summary for multiple vendor directories",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main`,
+				},
+				{
+					RelPath: "vendor/github.com/org/library/bar/bar.go",
+					Src:     `package bar`,
+				},
+				{
+					RelPath: "vendor/github.com/org/library/baz/baz.go",
+					Src:     `package baz`,
+				},
+				{
+					RelPath: "subdir/vendor/github.com/org/library/bar/bar.go",
+					Src:     `package bar`,
+				},
+			},
+			pkgs: func(projectDir string) []string {
+				return []string{
+					projectDir + "/.",
+					projectDir + "/subdir",
+				}
+			},
+			want: `github.com/org/library/bar
+github.com/org/library/bar
+github.com/org/library/baz
+# 3 unused vendored package(s) across 2 vendor directories
+`,
+		},
+		{
+			name: "summary is printed when there are no unused packages",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main; import _ "github.com/org/library/bar";`,
+				},
+				{
+					RelPath: "vendor/github.com/org/library/bar/bar.go",
+					Src:     `package bar`,
+				},
+			},
+			pkgs: func(projectDir string) []string {
+				return []string{
+					projectDir + "/.",
+				}
+			},
+			want: `# 0 unused vendored package(s) across 1 vendor directories
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, tc.name)
+
+		_, err = gofiles.Write(projectDir, tc.files)
+		require.NoError(t, err, "Case %d (%s)", i, tc.name)
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, tc.pkgs(projectDir), novendor.Param{
+			Summary: true,
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, tc.name)
+		assert.Equal(t, tc.want, buf.String(), "Case %d (%s)", i, tc.name)
+	}
+}
+
+func TestConfigLoadFromFile(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir("", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, tc := range []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "YAML configuration",
+			content: `pkgRegexps:
+  - github\.com/[^/]+/[^/]+
+includeVendorInImportPath: true
+ignorePkgs:
+  - vendor/github.com/org/library
+includeTestImports: false
+`,
+		},
+		{
+			name: "JSON configuration",
+			content: `{
+  "pkgRegexps": ["github\\.com/[^/]+/[^/]+"],
+  "includeVendorInImportPath": true,
+  "ignorePkgs": ["vendor/github.com/org/library"],
+  "includeTestImports": false
+}
+`,
+		},
+	} {
+		cfgFile := path.Join(tmpDir, fmt.Sprintf("config-%d", i))
+		err := ioutil.WriteFile(cfgFile, []byte(tc.content), 0644)
+		require.NoError(t, err, "Case %d (%s)", i, tc.name)
+
+		cfg, err := novendor.Config{
+			Summary: true,
+		}.LoadFromFile(cfgFile)
+		require.NoError(t, err, "Case %d (%s)", i, tc.name)
+
+		param, err := cfg.ToParam()
+		require.NoError(t, err, "Case %d (%s)", i, tc.name)
+		assert.Equal(t, novendor.Param{
+			PkgRegexps: []*regexp.Regexp{
+				regexp.MustCompile(`^github\.com/[^/]+/[^/]+`),
+			},
+			IncludeVendorInImportPath: true,
+			IgnorePkgs: []string{
+				"vendor/github.com/org/library",
+			},
+			ExcludeTestImports: true,
+			Summary:            true,
+		}, param, "Case %d (%s)", i, tc.name)
+	}
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library/bar";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar`,
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	buf := &bytes.Buffer{}
+	err = novendor.RunContext(ctx, projectDir, []string{projectDir + "/."}, novendor.Param{}, buf)
+	require.Error(t, err)
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+	assert.Equal(t, "", buf.String())
+}
+
+func TestNovendorReportEmpty(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library/bar";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/assets/logo.svg",
+			Src:     `<svg/>`,
+		},
+		{
+			RelPath: "vendor/github.com/org/leftover/LICENSE",
+			Src:     `license`,
+		},
+		{
+			RelPath: "vendor/github.com/org/leftover/docs/README.md",
+			Src:     `readme`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		ReportEmpty: true,
+	}
+
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, param)
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	vendorDir := path.Join(wd, projectDir, "vendor")
+	assert.Equal(t, map[string][]string{
+		vendorDir: {
+			vendorDir + "/github.com/org/leftover",
+			vendorDir + "/github.com/org/library/assets",
+		},
+	}, result.EmptyDirs)
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `empty: github.com/org/leftover
+empty: github.com/org/library/assets
+`, buf.String())
+}
+
+func TestNovendorCheckImportComments(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library/bar"; import _ "github.com/org/library/baz";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar // import "github.com/other-org/library/bar"`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/baz/baz.go",
+			Src:     `package baz // import "github.com/org/library/baz"`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		CheckImportComments: true,
+	}
+
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, param)
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	barDir := path.Join(wd, projectDir, "vendor", "github.com", "org", "library", "bar")
+	assert.Equal(t, []novendor.ImportCommentMismatch{
+		{
+			Dir:           barDir,
+			ImportPath:    "github.com/org/library/bar",
+			ImportComment: "github.com/other-org/library/bar",
+		},
+	}, result.ImportCommentMismatches)
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(`warning: package github.com/org/library/bar in %s has import comment "github.com/other-org/library/bar"
+`, barDir), buf.String())
+}
+
+func TestListVendoredPackages(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library/bar";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/bar/baz/baz.go",
+			Src:     `package baz`,
+		},
+		{
+			RelPath: "vendor/github.com/org/other/other.go",
+			Src:     `package other`,
+		},
+		{
+			RelPath: "vendor/github.com/org/other/main.go",
+			Src: `// +build ignore
+
+package main`,
+		},
+		{
+			RelPath: "subdir/vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar`,
+		},
+	})
+	require.NoError(t, err)
+
+	got, err := novendor.ListVendoredPackages(projectDir, []string{
+		projectDir + "/.",
+		projectDir + "/subdir",
+	})
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	pkgPrefix := fmt.Sprintf("%s/%s", currPkgName, projectDir)
+	assert.Equal(t, map[string][]string{
+		path.Join(wd, projectDir, "vendor"): {
+			pkgPrefix + "/vendor/github.com/org/library/bar",
+			pkgPrefix + "/vendor/github.com/org/library/bar/baz",
+			pkgPrefix + "/vendor/github.com/org/other",
+		},
+		path.Join(wd, projectDir, "subdir", "vendor"): {
+			pkgPrefix + "/subdir/vendor/github.com/org/library/bar",
+		},
+	}, got)
+}
+
+func TestNovendorRecursivePackages(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library/bar";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar`,
+		},
+		{
+			RelPath: "subdir/subdir.go",
+			Src:     `package subdir; import _ "github.com/org/library/baz";`,
+		},
+		{
+			RelPath: "subdir/vendor/github.com/org/library/baz/baz.go",
+			Src:     `package baz`,
+		},
+		{
+			RelPath: "subdir/vendor/github.com/org/library/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: ".hidden/hidden.go",
+			Src:     `package hidden; import _ "github.com/org/library/unused";`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		IncludeVendorInImportPath: true,
+	}
+
+	wantBuf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/.", projectDir + "/subdir"}, param, wantBuf)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(`%s/%s/subdir/vendor/github.com/org/library/unused
+`, currPkgName, projectDir), wantBuf.String())
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/..."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, wantBuf.String(), buf.String())
+}
+
+func TestNovendorCustomVendorDirName(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library/bar";`,
+		},
+		{
+			RelPath: "_vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar; import _ "github.com/org/other/used";`,
+		},
+		{
+			RelPath: "_vendor/github.com/org/library/baz/baz.go",
+			Src:     `package baz`,
+		},
+		{
+			RelPath: "_vendor/github.com/org/other/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/ignored/ignored.go",
+			Src:     `package ignored`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		VendorDirName: "_vendor",
+	}
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `github.com/org/library/baz
+`, buf.String())
+
+	param.IncludeVendorInImportPath = true
+	buf = &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(`%s/%s/_vendor/github.com/org/library/baz
+`, currPkgName, projectDir), buf.String())
+}
+
+func TestNovendorReportWarnings(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library/bar"; import _ "github.com/org/missing";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/malformed/malformed.go",
+			Src:     `packag malformed`,
+		},
+	})
+	require.NoError(t, err)
+
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, novendor.Param{
+		ReportWarnings: true,
+	})
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Warnings))
+	assert.Equal(t, path.Join(wd, projectDir, "vendor", "github.com", "org", "library", "malformed"), result.Warnings[0].Dir)
+	assert.Error(t, result.Warnings[0].Err)
+
+	// warnings are not collected unless requested
+	result, err = novendor.Analyze(projectDir, []string{projectDir + "/."}, novendor.Param{})
+	require.NoError(t, err)
+	assert.Nil(t, result.Warnings)
+}
+
+func TestNovendorRelativePaths(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/subpackage/bar.go",
+			Src:     `package bar`,
+		},
+		{
+			RelPath: "subdir/nested/vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		IncludeVendorInImportPath: true,
+		RelativePaths:             true,
+	}
+	pkgs := []string{
+		projectDir + "/.",
+		projectDir + "/subdir/nested",
+	}
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, pkgs, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `subdir/nested/vendor/github.com/org/library/bar
+vendor/github.com/org/library/subpackage
+`, buf.String())
+
+	// relative paths only apply when the full import path is printed
+	param.IncludeVendorInImportPath = false
+	buf = &bytes.Buffer{}
+	err = novendor.Run(projectDir, pkgs, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `github.com/org/library/bar
+github.com/org/library/subpackage
+`, buf.String())
+}
+
+func TestNovendorCheckStdlibShadow(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "net/http"; import _ "github.com/org/library/bar";`,
+		},
+		{
+			RelPath: "vendor/net/http/http.go",
+			Src:     `package http`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		CheckStdlibShadow: true,
+	}
+
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, param)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		fmt.Sprintf("%s/%s/vendor/net/http", currPkgName, projectDir),
+	}, result.StdlibShadows)
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `net/http
+warning: vendored package net/http shadows the standard library
+`, buf.String())
+}
+
+func TestNovendorMaxDepth(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name     string
+		maxDepth int
+		want     string
+	}{
+		{
+			name:     "unlimited depth traverses entire import graph",
+			maxDepth: 0,
+			want:     "",
+		},
+		{
+			name:     "depth 1 only considers direct imports",
+			maxDepth: 1,
+			want: `github.com/org/b
+github.com/org/c
+`,
+		},
+		{
+			name:     "depth 2 considers imports of direct imports",
+			maxDepth: 2,
+			want: `github.com/org/c
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main; import _ "github.com/org/a";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/a/a.go",
+				Src:     `package a; import _ "github.com/org/b";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/b/b.go",
+				Src:     `package b; import _ "github.com/org/c";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/c/c.go",
+				Src:     `package c`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			MaxDepth: currCase.maxDepth,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorGroupByRepo(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/c/c.go",
+			Src:     `package c`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/c/d/d.go",
+			Src:     `package d`,
+		},
+		{
+			RelPath: "vendor/github.com/org/other/other.go",
+			Src:     `package other`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		PkgRegexps: []*regexp.Regexp{
+			regexp.MustCompile(`^github\.com/[^/]+/[^/]+`),
+		},
+		GroupByRepo: true,
+		Summary:     true,
+	}
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `github.com/org/library (4 unused subpackages)
+github.com/org/other (1 unused subpackage)
+# 5 unused vendored package(s) across 1 vendor directories
+`, buf.String())
+}
+
+func TestNovendorDOT(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "fmt"; import _ "github.com/org/a"; import _ "github.com/org/b";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a; import _ "github.com/org/b";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "vendor/github.com/org/c/c.go",
+			Src:     `package c`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		Format: novendor.FormatDOT,
+	}
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+
+	nodeRegexp := regexp.MustCompile(`^\t"([^"]+)" \[label="[^"]+"(?:, color=(\w+))?\];$`)
+	edgeRegexp := regexp.MustCompile(`^\t"[^"]+" -> "[^"]+";$`)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.True(t, len(lines) >= 2, "unexpected output: %s", buf.String())
+	assert.Equal(t, "digraph novendor {", lines[0])
+	assert.Equal(t, "}", lines[len(lines)-1])
+
+	colors := make(map[string]string)
+	numEdges := 0
+	for _, line := range lines[1 : len(lines)-1] {
+		if match := nodeRegexp.FindStringSubmatch(line); match != nil {
+			colors[match[1]] = match[2]
+		} else if edgeRegexp.MatchString(line) {
+			numEdges++
+		} else {
+			assert.Fail(t, "unexpected line", line)
+		}
+	}
+
+	vendorPrefix := fmt.Sprintf("%s/%s/vendor/", currPkgName, projectDir)
+	assert.Equal(t, map[string]string{
+		fmt.Sprintf("%s/%s", currPkgName, projectDir): "",
+		vendorPrefix + "github.com/org/a":             "green",
+		vendorPrefix + "github.com/org/b":             "green",
+		vendorPrefix + "github.com/org/c":             "red",
+	}, colors)
+	assert.Equal(t, 3, numEdges)
+}
+
+func TestNovendorWarnVendoredMain(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name             string
+		warnVendoredMain bool
+		want             string
+	}{
+		{
+			name:             "vendored main package is reported as unused by default",
+			warnVendoredMain: false,
+			want: `github.com/org/library
+github.com/org/tool
+`,
+		},
+		{
+			name:             "vendored main package is reported as warning",
+			warnVendoredMain: true,
+			want: `github.com/org/library
+warning: vendored package github.com/org/tool is a main package
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main`,
+			},
+			{
+				RelPath: "vendor/github.com/org/library/library.go",
+				Src:     `package library`,
+			},
+			{
+				RelPath: "vendor/github.com/org/tool/main.go",
+				Src:     `package main; func main() {}`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			WarnVendoredMain: currCase.warnVendoredMain,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorGoModReplace(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name  string
+		goMod string
+		want  string
+	}{
+		{
+			name:  "import of replaced module resolves to replacement directory",
+			goMod: "module github.com/org/project\n\nreplace github.com/org/dep => ../dep\n",
+			want:  "",
+		},
+		{
+			name:  "replace block is supported",
+			goMod: "module github.com/org/project\n\nreplace (\n\tgithub.com/org/dep v1.0.0 => ../dep // local fork\n)\n",
+			want:  "",
+		},
+		{
+			name:  "import is not resolved without replace directive",
+			goMod: "module github.com/org/project\n",
+			want:  "github.com/org/lib\n",
+		},
+	} {
+		rootDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(rootDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "project/go.mod",
+				Src:     currCase.goMod,
+			},
+			{
+				RelPath: "project/foo.go",
+				Src:     `package main; import _ "github.com/org/dep";`,
+			},
+			{
+				RelPath: "project/vendor/github.com/org/lib/lib.go",
+				Src:     `package lib`,
+			},
+			{
+				RelPath: "dep/dep.go",
+				Src:     `package dep; import _ "github.com/org/lib";`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		projectDir := path.Join(rootDir, "project")
+		param := novendor.Param{}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorProgressFn(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "subdir/subdir.go",
+			Src:     `package subdir`,
+		},
+		{
+			RelPath: "subdir/vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+	})
+	require.NoError(t, err)
+
+	var got [][2]int
+	param := novendor.Param{
+		ProgressFn: func(examined, total int) {
+			got = append(got, [2]int{examined, total})
+		},
+	}
+
+	_, err = novendor.Analyze(projectDir, []string{projectDir + "/..."}, param)
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int{{1, 2}, {2, 2}}, got)
+}
+
+func TestRunWithWriters(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library/bar";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "vendor/github.com/org/malformed/malformed.go",
+			Src:     `packag malformed`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		PkgRegexps: []*regexp.Regexp{
+			regexp.MustCompile(`^github\.com/[^/]+/[^/]+`),
+		},
+		Summary:        true,
+		ReportWarnings: true,
+	}
+
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	err = novendor.RunWithWriters(projectDir, []string{projectDir + "/."}, param, out, errOut)
+	require.NoError(t, err)
+
+	assert.Equal(t, `github.com/org/unused
+`, out.String())
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	errLines := strings.Split(strings.TrimSuffix(errOut.String(), "\n"), "\n")
+	require.Equal(t, 2, len(errLines), "unexpected output: %s", errOut.String())
+	assert.Equal(t, "# 1 unused vendored package(s) across 1 vendor directories", errLines[0])
+	assert.True(t, strings.HasPrefix(errLines[1], fmt.Sprintf("warning: %s: ", path.Join(wd, projectDir, "vendor", "github.com", "org", "malformed"))), "unexpected warning: %s", errLines[1])
+
+	// Run writes the same output, including the warnings, to a single writer
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, out.String()+errOut.String(), buf.String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = novendor.RunWithWritersContext(ctx, projectDir, []string{projectDir + "/."}, param, &bytes.Buffer{}, &bytes.Buffer{})
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+}
+
+func TestNovendorExplainIgnores(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name           string
+		explainIgnores bool
+		want           string
+	}{
+		{
+			name:           "packages used only by ignored packages are not reported by default",
+			explainIgnores: false,
+			want: `github.com/org/unused
+`,
+		},
+		{
+			name:           "packages used only by ignored packages are reported",
+			explainIgnores: true,
+			want: `github.com/org/unused
+used only by ignored packages: github.com/org/ignored-dep
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main; import _ "github.com/org/used";`,
+			},
+			{
+				RelPath: "ignored/ignored.go",
+				Src:     `package ignored; import _ "github.com/org/used"; import _ "github.com/org/ignored-dep";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/used/used.go",
+				Src:     `package used`,
+			},
+			{
+				RelPath: "vendor/github.com/org/ignored-dep/dep.go",
+				Src:     `package dep`,
+			},
+			{
+				RelPath: "vendor/github.com/org/unused/unused.go",
+				Src:     `package unused`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			IgnorePkgs: []string{
+				projectDir + "/ignored",
+			},
+			ExplainIgnores: currCase.explainIgnores,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorFollowSymlinks(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name           string
+		followSymlinks bool
+		want           string
+	}{
+		{
+			name:           "symlinked vendor directory is not followed by default",
+			followSymlinks: false,
+			want:           "",
+		},
+		{
+			name:           "symlinked vendor directory is followed",
+			followSymlinks: true,
+			want: `github.com/org/library
+github.com/org/linked
+`,
+		},
+	} {
+		rootDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(rootDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "project/foo.go",
+				Src:     `package main; import _ "github.com/org/used";`,
+			},
+			{
+				RelPath: "shared/vendor/github.com/org/used/used.go",
+				Src:     `package used`,
+			},
+			{
+				RelPath: "shared/vendor/github.com/org/library/library.go",
+				Src:     `package library`,
+			},
+			{
+				RelPath: "other/linked.go",
+				Src:     `package linked`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		// vendor directory of project is a link to the shared vendor directory
+		require.NoError(t, os.Symlink(path.Join("..", "shared", "vendor"), path.Join(rootDir, "project", "vendor")), "Case %d (%s)", i, currCase.name)
+		// link within shared vendor directory to directory outside of it
+		require.NoError(t, os.Symlink(path.Join("..", "..", "..", "..", "other"), path.Join(rootDir, "shared", "vendor", "github.com", "org", "linked")), "Case %d (%s)", i, currCase.name)
+		// cycle of links
+		require.NoError(t, os.Symlink(path.Join("..", ".."), path.Join(rootDir, "shared", "vendor", "github.com", "org", "library", "cycle")), "Case %d (%s)", i, currCase.name)
+
+		projectDir := path.Join(rootDir, "project")
+		param := novendor.Param{
+			FollowSymlinks: currCase.followSymlinks,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorAllowUnused(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name  string
+		param func(projectDir string) novendor.Param
+		want  string
+	}{
+		{
+			name: "no packages allowed or ignored",
+			param: func(projectDir string) novendor.Param {
+				return novendor.Param{}
+			},
+			want: `github.com/org/kept
+github.com/org/kept-dep
+github.com/org/unused
+`,
+		},
+		{
+			name: "allowed package is suppressed but its dependencies are reported",
+			param: func(projectDir string) novendor.Param {
+				return novendor.Param{
+					AllowUnused: []string{
+						"github.com/org/kept",
+					},
+				}
+			},
+			want: `github.com/org/kept-dep
+github.com/org/unused
+`,
+		},
+		{
+			name: "ignored package and its dependencies are suppressed",
+			param: func(projectDir string) novendor.Param {
+				return novendor.Param{
+					IgnorePkgs: []string{
+						projectDir + "/vendor/github.com/org/kept",
+					},
+				}
+			},
+			want: `github.com/org/unused
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main`,
+			},
+			{
+				RelPath: "vendor/github.com/org/kept/kept.go",
+				Src:     `package kept; import _ "github.com/org/kept-dep";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/kept-dep/dep.go",
+				Src:     `package dep`,
+			},
+			{
+				RelPath: "vendor/github.com/org/unused/unused.go",
+				Src:     `package unused`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := currCase.param(projectDir)
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorOnlyBuildIgnored(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "main.go",
+			Src:     `package main; import _ "github.com/org/library";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/library1.go",
+			Src:     `package library; import _ "github.com/lib1import"`,
+		},
+		{
+			RelPath: "vendor/github.com/lib1import/import.go",
+			Src:     `package lib1import`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/main.go",
+			Src: `// +build ignore
+
+package main; import _ "github.com/mainimport"`,
+		},
+		{
+			RelPath: "vendor/github.com/mainimport/import.go",
+			Src:     `package mainimport`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/library2.go",
+			Src: `// +build ignore
+
+package library2; import _ "github.com/lib2import"`,
+		},
+		{
+			RelPath: "vendor/github.com/lib2import/import.go",
+			Src:     `package lib2import`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		OnlyBuildIgnored: true,
+	}
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `used only by build-ignored files: github.com/lib2import
+used only by build-ignored files: github.com/mainimport
+`, buf.String())
+}
+
+func TestNovendorSkipDirs(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name     string
+		skipDirs []string
+		want     string
+	}{
+		{
+			name:     "testdata directories are skipped by default",
+			skipDirs: nil,
+			want: `github.com/org/library
+github.com/org/library/node_modules/stray
+`,
+		},
+		{
+			name:     "specified directories are skipped",
+			skipDirs: []string{"node_modules"},
+			want: `github.com/org/library
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main`,
+			},
+			{
+				RelPath: "vendor/github.com/org/library/library.go",
+				Src:     `package library`,
+			},
+			{
+				RelPath: "vendor/github.com/org/library/testdata/stray.go",
+				Src:     `package stray`,
+			},
+			{
+				RelPath: "vendor/github.com/org/library/node_modules/stray/stray.go",
+				Src:     `package stray`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			SkipDirs: currCase.skipDirs,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorCgoEnabled(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name       string
+		cgoEnabled bool
+		want       string
+	}{
+		{
+			name:       "imports of cgo files are considered if cgo is enabled",
+			cgoEnabled: true,
+			want:       "",
+		},
+		{
+			name:       "imports of cgo files are not considered if cgo is disabled",
+			cgoEnabled: false,
+			want: `github.com/org/cgoimport
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main; import _ "github.com/org/library";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/library/library.go",
+				Src:     `package library`,
+			},
+			{
+				RelPath: "vendor/github.com/org/library/library_cgo.go",
+				Src:     `package library; import "C"; import _ "github.com/org/cgoimport";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/cgoimport/cgoimport.go",
+				Src:     `package cgoimport`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		cgoEnabled := currCase.cgoEnabled
+		param := novendor.Param{
+			CgoEnabled: &cgoEnabled,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorDedupe(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name          string
+		includeVendor bool
+		want          func(projectDir string) string
+	}{
+		{
+			name: "identical import paths are printed once",
+			want: func(projectDir string) string {
+				return `github.com/org/library/bar
+`
+			},
+		},
+		{
+			name:          "full import paths are not deduplicated",
+			includeVendor: true,
+			want: func(projectDir string) string {
+				return fmt.Sprintf(`%s/%s/subdir/vendor/github.com/org/library/bar
+%s/%s/vendor/github.com/org/library/bar
+`, currPkgName, projectDir, currPkgName, projectDir)
+			},
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main`,
+			},
+			{
+				RelPath: "vendor/github.com/org/library/bar/bar.go",
+				Src:     `package bar`,
+			},
+			{
+				RelPath: "subdir/vendor/github.com/org/library/bar/bar.go",
+				Src:     `package bar`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			IncludeVendorInImportPath: currCase.includeVendor,
+			Dedupe:                    true,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/.", projectDir + "/subdir"}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want(projectDir), buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorBuildTags(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name      string
+		buildTags []string
+		want      string
+	}{
+		{
+			name: "import gated by tag is excluded from build if tag is not set",
+			want: `used only by build-ignored files: github.com/org/integration
+`,
+		},
+		{
+			name:      "import gated by tag is included in build if tag is set",
+			buildTags: []string{"integration"},
+			want:      "",
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main`,
+			},
+			{
+				RelPath: "foo_integration.go",
+				Src: `// +build integration
+
+package main; import _ "github.com/org/integration";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/integration/integration.go",
+				Src:     `package integration`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			OnlyBuildIgnored: true,
+			BuildTags:        currCase.buildTags,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "withvendor/bar.go",
+			Src:     `package bar; import _ "github.com/org/malformed";`,
+		},
+		{
+			RelPath: "withvendor/vendor/github.com/org/malformed/malformed.go",
+			Src:     `packag malformed`,
+		},
+	})
+	require.NoError(t, err)
+
+	// project without vendor directory
+	_, err = novendor.ListVendoredPackages(projectDir, nil)
+	require.Error(t, err)
+	assert.Equal(t, novendor.ErrNoVendorDir, errors.Cause(err))
+
+	// package that cannot be parsed
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/withvendor"}, novendor.Param{
+		ReportWarnings: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Warnings))
+	parseErr, ok := result.Warnings[0].Err.(*novendor.PackageParseError)
+	require.True(t, ok, "unexpected error type: %T", result.Warnings[0].Err)
+	assert.Equal(t, result.Warnings[0].Dir, parseErr.Dir)
+	assert.Equal(t, parseErr.Err, errors.Cause(parseErr))
+	assert.Equal(t, parseErr.Error(), result.Warnings[0].String())
+}
+
+func TestNovendorCollapseInternal(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name             string
+		collapseInternal bool
+		want             string
+	}{
+		{
+			name: "internal packages are reported by default",
+			want: `github.com/org/library
+github.com/org/library/internal/impl
+github.com/org/library/internal/impl/inner
+github.com/org/used/internal/impl
+`,
+		},
+		{
+			name:             "internal packages are collapsed into unused enclosing package",
+			collapseInternal: true,
+			want: `github.com/org/library
+github.com/org/used/internal/impl
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main; import _ "github.com/org/used";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/library/library.go",
+				Src:     `package library`,
+			},
+			{
+				RelPath: "vendor/github.com/org/library/internal/impl/impl.go",
+				Src:     `package impl`,
+			},
+			{
+				RelPath: "vendor/github.com/org/library/internal/impl/inner/inner.go",
+				Src:     `package inner`,
+			},
+			{
+				RelPath: "vendor/github.com/org/used/used.go",
+				Src:     `package used`,
+			},
+			{
+				RelPath: "vendor/github.com/org/used/internal/impl/impl.go",
+				Src:     `package impl`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			CollapseInternal: currCase.collapseInternal,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorSelfImportThroughVendor(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+	projectImportPath := path.Join(currPkgName, projectDir)
+
+	// the "sub" package of the project imports a vendored dependency that imports the "sub" package of the project.
+	// The import in the dependency resolves to the copy of the project's "sub" package that is vendored in the
+	// project, so the vendored copy is used even though its import path (without the vendor directory) is the same as
+	// the import path of the project package that was already examined.
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "sub/sub.go",
+			Src:     `package sub; import _ "github.com/org/dep";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/dep/dep.go",
+			Src:     fmt.Sprintf(`package dep; import _ "%s/sub";`, projectImportPath),
+		},
+		{
+			RelPath: path.Join("vendor", projectImportPath, "sub", "sub.go"),
+			Src:     `package sub`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{}
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/.", projectDir + "/sub"}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `github.com/org/unused
+`, buf.String())
+}
+
+func TestNovendorLogger(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/library.go",
+			Src:     `package library`,
+		},
+	})
+	require.NoError(t, err)
+
+	logBuf := &bytes.Buffer{}
+	param := novendor.Param{
+		Logger: log.New(logBuf, "", 0),
+	}
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "", buf.String())
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	vendorDir := path.Join(wd, projectDir, "vendor")
+	vendoredPkg := fmt.Sprintf("%s/%s/vendor/github.com/org/library", currPkgName, projectDir)
+	logOutput := logBuf.String()
+	assert.Contains(t, logOutput, fmt.Sprintf("scanning vendor directory %s\n", vendorDir))
+	assert.Contains(t, logOutput, fmt.Sprintf("resolved import github.com/org/library of package %s/%s to %s\n", currPkgName, projectDir, vendoredPkg))
+	assert.Contains(t, logOutput, fmt.Sprintf("examining package %s in %s\n", vendoredPkg, path.Join(vendorDir, "github.com", "org", "library")))
+	assert.Contains(t, logOutput, fmt.Sprintf("%s is used by package %s: removing from unused packages of vendor directory %s\n", vendoredPkg, path.Join(wd, projectDir, "."), vendorDir))
+}
+
+func TestNovendorPlatforms(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name      string
+		platforms [][2]string
+		want      string
+	}{
+		{
+			name: "all files are considered if no platforms are specified",
+			want: `github.com/org/unused
+`,
+		},
+		{
+			name:      "packages used on any of the platforms are considered used",
+			platforms: [][2]string{{"darwin", "amd64"}, {"linux", "amd64"}},
+			want: `github.com/org/unused
+`,
+		},
+		{
+			name:      "packages used only on platforms that are not specified are unused",
+			platforms: [][2]string{{"linux", "amd64"}},
+			want: `github.com/org/darwin
+github.com/org/unused
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main`,
+			},
+			{
+				RelPath: "foo_darwin.go",
+				Src:     `package main; import _ "github.com/org/darwin";`,
+			},
+			{
+				RelPath: "foo_linux.go",
+				Src:     `package main; import _ "github.com/org/linux";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/darwin/darwin.go",
+				Src:     `package darwin`,
+			},
+			{
+				RelPath: "vendor/github.com/org/linux/linux.go",
+				Src:     `package linux`,
+			},
+			{
+				RelPath: "vendor/github.com/org/unused/unused.go",
+				Src:     `package unused`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			Platforms: currCase.platforms,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorDeterministicOutput(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	files := []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+	}
+	for _, vendorDir := range []string{"vendor", "a/vendor", "b/vendor", "c/vendor"} {
+		if vendorDir != "vendor" {
+			files = append(files, gofiles.GoFileSpec{
+				RelPath: path.Join(path.Dir(vendorDir), "pkg.go"),
+				Src:     `package pkg`,
+			})
+		}
+		for _, pkg := range []string{"github.com/org/library/a", "github.com/org/library/b", "github.com/org/other/c"} {
+			files = append(files, gofiles.GoFileSpec{
+				RelPath: path.Join(vendorDir, pkg, "pkg.go"),
+				Src:     `package pkg`,
+			})
+		}
+	}
+	_, err = gofiles.Write(projectDir, files)
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name  string
+		param novendor.Param
+	}{
+		{
+			name: "full import paths",
+			param: novendor.Param{
+				IncludeVendorInImportPath: true,
+			},
+		},
+		{
+			name: "grouped by repository",
+			param: novendor.Param{
+				PkgRegexps: []*regexp.Regexp{
+					regexp.MustCompile(`^github\.com/[^/]+/[^/]+`),
+				},
+				GroupByRepo: true,
+			},
+		},
+	} {
+		var want string
+		for run := 0; run < 10; run++ {
+			buf := &bytes.Buffer{}
+			err = novendor.Run(projectDir, []string{projectDir + "/..."}, currCase.param, buf)
+			require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+			if run == 0 {
+				want = buf.String()
+				continue
+			}
+			require.Equal(t, want, buf.String(), "Case %d (%s): output of run %d differs", i, currCase.name, run)
+		}
+	}
+}
+
+func TestNovendorAbsPaths(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/bar/bar.go",
+			Src:     `package bar`,
+		},
+		{
+			RelPath: "subdir/subdir.go",
+			Src:     `package subdir`,
+		},
+		{
+			RelPath: "subdir/vendor/github.com/org/other/other.go",
+			Src:     `package other`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		PkgRegexps: []*regexp.Regexp{
+			regexp.MustCompile(`^github\.com/[^/]+/[^/]+`),
+		},
+		AbsPaths: true,
+	}
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/..."}, param, buf)
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(`%s
+%s
+`, path.Join(wd, projectDir, "subdir", "vendor", "github.com", "org", "other"), path.Join(wd, projectDir, "vendor", "github.com", "org", "library")), buf.String())
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		assert.True(t, path.IsAbs(line), "path %s is not absolute", line)
+		fi, err := os.Stat(line)
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir(), "path %s is not a directory", line)
+	}
+}
+
+func TestNovendorIgnorePrefixes(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/b/c/c.go",
+			Src:     `package c`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library-other/other.go",
+			Src:     `package other`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		IgnorePrefixes: []string{
+			"github.com/org/library/",
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `github.com/org/library-other
+`, buf.String())
+}
+
+func TestNovendorCheckVersionMismatch(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/divergent"; import _ "github.com/org/identical";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/divergent/divergent.go",
+			Src:     `package divergent; func Foo() {}`,
+		},
+		{
+			RelPath: "vendor/github.com/org/identical/identical.go",
+			Src:     `package identical`,
+		},
+		{
+			RelPath: "subdir/subdir.go",
+			Src:     `package subdir; import _ "github.com/org/divergent"; import _ "github.com/org/identical";`,
+		},
+		{
+			RelPath: "subdir/vendor/github.com/org/divergent/divergent.go",
+			Src:     `package divergent; func Bar() {}`,
+		},
+		{
+			RelPath: "subdir/vendor/github.com/org/identical/identical.go",
+			Src:     `package identical`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		CheckVersionMismatch: true,
+	}
+
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/..."}, param)
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, []novendor.VersionMismatch{
+		{
+			ImportPath: "github.com/org/divergent",
+			Dirs: []string{
+				path.Join(wd, projectDir, "subdir", "vendor", "github.com", "org", "divergent"),
+				path.Join(wd, projectDir, "vendor", "github.com", "org", "divergent"),
+			},
+		},
+	}, result.VersionMismatches)
+}
+
+func TestNovendorJSONL(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "vendor/github.com/org/other/other.go",
+			Src:     `package other`,
+		},
+		{
+			RelPath: "subdir/subdir.go",
+			Src:     `package subdir`,
+		},
+		{
+			RelPath: "subdir/vendor/github.com/org/nested/nested.go",
+			Src:     `package nested`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		Format: novendor.FormatJSONL,
+	}
+
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/..."}, param)
+	require.NoError(t, err)
+	want := make(map[novendor.JSONLine]struct{})
+	for vendorDir, unused := range result.UnusedPkgs {
+		for _, pkg := range unused {
+			want[novendor.JSONLine{VendorDir: vendorDir, Pkg: strings.TrimPrefix(pkg, path.Join(currPkgName, projectDir, "vendor")+"/")}] = struct{}{}
+		}
+	}
+
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	err = novendor.RunWithWriters(projectDir, []string{projectDir + "/..."}, param, out, errOut)
+	require.NoError(t, err)
+
+	got := make(map[novendor.JSONLine]struct{})
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		var jsonLine novendor.JSONLine
+		require.NoError(t, json.Unmarshal([]byte(line), &jsonLine), "line is not valid JSON: %s", line)
+		got[jsonLine] = struct{}{}
+	}
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	vendorDir := path.Join(wd, projectDir, "vendor")
+	subdirVendorDir := path.Join(wd, projectDir, "subdir", "vendor")
+	assert.Equal(t, map[novendor.JSONLine]struct{}{
+		{VendorDir: vendorDir, Pkg: "github.com/org/other"}:        {},
+		{VendorDir: vendorDir, Pkg: "github.com/org/unused"}:       {},
+		{VendorDir: subdirVendorDir, Pkg: "github.com/org/nested"}: {},
+	}, got)
+	assert.Equal(t, "", errOut.String())
+}
+
+func TestNovendorJSONLRunWritesOnlyJSON(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		Format:                 novendor.FormatJSONL,
+		IgnorePkgs:             []string{path.Join(projectDir, "vendor/github.com/org/used")},
+		ReportRedundantIgnores: true,
+	}
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, param)
+	require.NoError(t, err)
+	require.NotEmpty(t, result.RedundantIgnores)
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Equal(t, 1, len(lines), "Output: %s", buf.String())
+	var jsonLine novendor.JSONLine
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &jsonLine), "line is not valid JSON: %s", lines[0])
+	assert.Equal(t, "github.com/org/unused", jsonLine.Pkg)
+}
+
+func TestNovendorJSONLOptions(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "vendor/github.com/org/lib/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "vendor/github.com/org/lib/b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "subdir/subdir.go",
+			Src:     `package subdir`,
+		},
+		{
+			RelPath: "subdir/vendor/github.com/org/lib/a/a.go",
+			Src:     `package a`,
+		},
+	})
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	vendorDir := path.Join(wd, projectDir, "vendor")
+	subdirVendorDir := path.Join(wd, projectDir, "subdir", "vendor")
+
+	for i, currCase := range []struct {
+		name  string
+		param novendor.Param
+		want  []novendor.JSONLine
+	}{
+		{
+			name: "lines are sorted by vendor directory and then by package",
+			want: []novendor.JSONLine{
+				{VendorDir: subdirVendorDir, Pkg: "github.com/org/lib/a"},
+				{VendorDir: vendorDir, Pkg: "github.com/org/lib/a"},
+				{VendorDir: vendorDir, Pkg: "github.com/org/lib/b"},
+			},
+		},
+		{
+			name: "dedupe writes only the first line for each package",
+			param: novendor.Param{
+				Dedupe: true,
+			},
+			want: []novendor.JSONLine{
+				{VendorDir: subdirVendorDir, Pkg: "github.com/org/lib/a"},
+				{VendorDir: vendorDir, Pkg: "github.com/org/lib/b"},
+			},
+		},
+		{
+			name: "group by repo writes a line for each repository",
+			param: novendor.Param{
+				PkgRegexps:  []*regexp.Regexp{regexp.MustCompile(`github\.com/[^/]+/[^/]+`)},
+				GroupByRepo: true,
+			},
+			want: []novendor.JSONLine{
+				{VendorDir: subdirVendorDir, Pkg: "github.com/org/lib", Count: 1},
+				{VendorDir: vendorDir, Pkg: "github.com/org/lib", Count: 2},
+			},
+		},
+	} {
+		param := currCase.param
+		param.Format = novendor.FormatJSONL
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/..."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		var got []novendor.JSONLine
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			var jsonLine novendor.JSONLine
+			require.NoError(t, json.Unmarshal([]byte(line), &jsonLine), "Case %d (%s): line is not valid JSON: %s", i, currCase.name, line)
+			got = append(got, jsonLine)
+		}
+		assert.Equal(t, currCase.want, got, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestWriteResultJSONLWriteError(t *testing.T) {
+	result := &novendor.Result{
+		UnusedPkgs: map[string][]string{
+			"/project/vendor": {"github.com/org/project/vendor/github.com/org/unused"},
+		},
+	}
+	err := novendor.WriteResult(result, novendor.Param{
+		Format: novendor.FormatJSONL,
+	}, errWriter{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to write output")
+}
+
+// errWriter is an io.Writer whose writes always fail.
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestLoadIgnoreFile(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name    string
+		content string
+		want    func(projectDir string) []string
+	}{
+		{
+			name: "missing ignore file",
+			want: func(projectDir string) []string {
+				return nil
+			},
+		},
+		{
+			name: "comments and blank lines are skipped",
+			content: `# generated code
+ignored
+
+  # indented comment
+  other/pkg  
+/abs/path
+`,
+			want: func(projectDir string) []string {
+				return []string{
+					path.Join(projectDir, "ignored"),
+					path.Join(projectDir, "other/pkg"),
+					"/abs/path",
+				}
+			},
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		if currCase.content != "" {
+			err = ioutil.WriteFile(path.Join(projectDir, novendor.IgnoreFileName), []byte(currCase.content), 0644)
+			require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		}
+
+		got, err := novendor.LoadIgnoreFile(projectDir)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want(projectDir), got, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorIgnoreFileWithIgnorePkgs(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "fromflag/fromflag.go",
+			Src:     `package fromflag; import _ "github.com/org/flag-dep";`,
+		},
+		{
+			RelPath: "fromfile/fromfile.go",
+			Src:     `package fromfile; import _ "github.com/org/file-dep";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/flag-dep/dep.go",
+			Src:     `package dep`,
+		},
+		{
+			RelPath: "vendor/github.com/org/file-dep/dep.go",
+			Src:     `package dep`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+	err = ioutil.WriteFile(path.Join(projectDir, novendor.IgnoreFileName), []byte("# ignored packages\nfromfile\n"), 0644)
+	require.NoError(t, err)
+
+	ignoreFilePkgs, err := novendor.LoadIgnoreFile(projectDir)
+	require.NoError(t, err)
+
+	// packages in the ignore file are ignored in addition to the packages specified directly
+	param := novendor.Param{
+		IgnorePkgs: append([]string{
+			projectDir + "/fromflag",
+		}, ignoreFilePkgs...),
+	}
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/org/unused\n", buf.String())
+}
+
+func TestNovendorPerPkgContext(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "linuxpkg/pkg_linux.go",
+			Src:     `package linuxpkg; import _ "github.com/org/linux-linux";`,
+		},
+		{
+			RelPath: "linuxpkg/pkg_windows.go",
+			Src:     `package linuxpkg; import _ "github.com/org/linux-windows";`,
+		},
+		{
+			RelPath: "windowspkg/pkg_linux.go",
+			Src:     `package windowspkg; import _ "github.com/org/windows-linux";`,
+		},
+		{
+			RelPath: "windowspkg/pkg_windows.go",
+			Src:     `package windowspkg; import _ "github.com/org/windows-windows";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/linux-linux/lib.go",
+			Src:     `package lib`,
+		},
+		{
+			RelPath: "vendor/github.com/org/linux-windows/lib.go",
+			Src:     `package lib`,
+		},
+		{
+			RelPath: "vendor/github.com/org/windows-linux/lib.go",
+			Src:     `package lib`,
+		},
+		{
+			RelPath: "vendor/github.com/org/windows-windows/lib.go",
+			Src:     `package lib`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name          string
+		perPkgContext map[string]novendor.BuildContextOverride
+		want          string
+	}{
+		{
+			name: "all files are used by default",
+			want: "",
+		},
+		{
+			name: "packages are analyzed using their own build context",
+			perPkgContext: map[string]novendor.BuildContextOverride{
+				projectDir + "/linuxpkg": {
+					GOOS: "linux",
+				},
+				projectDir + "/windowspkg": {
+					GOOS: "windows",
+				},
+			},
+			want: `github.com/org/linux-windows
+github.com/org/windows-linux
+`,
+		},
+	} {
+		param := novendor.Param{
+			PerPkgContext: currCase.perPkgContext,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/..."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestIsVendoredPackageUsed(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used"; import _ "github.com/org/grouped/a";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "vendor/github.com/org/grouped/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "vendor/github.com/org/grouped/b/b.go",
+			Src:     `package b`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name       string
+		importPath string
+		pkgRegexps []string
+		want       bool
+		wantErr    string
+	}{
+		{
+			name:       "used package",
+			importPath: "github.com/org/used",
+			want:       true,
+		},
+		{
+			name:       "unused package",
+			importPath: "github.com/org/unused",
+			want:       false,
+		},
+		{
+			name:       "used package with vendor directory in import path",
+			importPath: path.Join(currPkgName, projectDir, "vendor", "github.com/org/used"),
+			want:       true,
+		},
+		{
+			name:       "unused package with vendor directory in import path",
+			importPath: path.Join(currPkgName, projectDir, "vendor", "github.com/org/unused"),
+			want:       false,
+		},
+		{
+			name:       "unused package is used when grouped",
+			importPath: "github.com/org/grouped/b",
+			pkgRegexps: []string{`github.com/org/grouped`},
+			want:       true,
+		},
+		{
+			name:       "grouped form of package",
+			importPath: "github.com/org/grouped",
+			pkgRegexps: []string{`github.com/org/grouped`},
+			want:       true,
+		},
+		{
+			name:       "unused package is unused when not grouped",
+			importPath: "github.com/org/grouped/b",
+			want:       false,
+		},
+		{
+			name:       "package that is not vendored",
+			importPath: "github.com/org/missing",
+			wantErr:    "github.com/org/missing is not a vendored package",
+		},
+	} {
+		config := novendor.Config{
+			PkgRegexps: currCase.pkgRegexps,
+		}
+		param, err := config.ToParam()
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		got, err := novendor.IsVendoredPackageUsed(projectDir, []string{projectDir + "/."}, currCase.importPath, param)
+		if currCase.wantErr != "" {
+			assert.EqualError(t, err, currCase.wantErr, "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, got, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorNestedVendor(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/lib";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/lib/lib.go",
+			Src:     `package lib; import _ "github.com/org/nested";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/lib/vendor/github.com/org/nested/nested.go",
+			Src:     `package nested`,
+		},
+		{
+			RelPath: "vendor/github.com/org/lib/vendor/github.com/org/nested-unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name              string
+		allowNestedVendor bool
+		want              string
+	}{
+		{
+			name: "nested vendor directory is skipped by default",
+			want: `github.com/org/nested-unused
+`,
+		},
+		{
+			name:              "nested vendor directory is analyzed separately",
+			allowNestedVendor: true,
+			want: `github.com/org/nested-unused
+github.com/org/nested-unused
+`,
+		},
+	} {
+		param := novendor.Param{
+			AllowNestedVendor: currCase.allowNestedVendor,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/.", projectDir + "/vendor/github.com/org/lib"}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorStats(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/a"; import _ "github.com/org/b";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "vendor/github.com/org/c/c.go",
+			Src:     `package c`,
+		},
+		{
+			RelPath: "vendor/github.com/org/d/d.go",
+			Src:     `package d`,
+		},
+		{
+			RelPath: "vendor/github.com/org/allowed/allowed.go",
+			Src:     `package allowed`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		AllowUnused: []string{
+			"github.com/org/allowed",
+		},
+		Stats: true,
+	}
+
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, param)
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	vendorDir := path.Join(wd, projectDir, "vendor")
+	assert.Equal(t, map[string]novendor.VendorDirStats{
+		vendorDir: {
+			TotalVendored: 5,
+			TotalUnused:   3,
+		},
+	}, result.Stats)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, novendor.WriteResult(result, param, buf))
+	assert.Equal(t, fmt.Sprintf(`github.com/org/c
+github.com/org/d
+stats: %s: 5 vendored, 3 unused
+`, vendorDir), buf.String())
+}
+
+func TestTransformImportPathWindowsSeparators(t *testing.T) {
+	for i, currCase := range []struct {
+		name       string
+		importPath string
+		regexps    []string
+		want       string
+	}{
+		{
+			name:       "import path with backslashes is normalized",
+			importPath: `github.com\org\project\inner\pkg`,
+			regexps:    []string{`^github.com/[^/]+/[^/]+`},
+			want:       "github.com/org/project",
+		},
+		{
+			name:       "vendor directory is detected with backslashes",
+			importPath: `github.com\org\project\vendor\gopkg.in\yaml.v2\inner`,
+			regexps:    []string{`^gopkg.in/[^/]+`},
+			want:       "github.com/org/project/vendor/gopkg.in/yaml.v2",
+		},
+		{
+			name:       "Windows file path with vendor directory",
+			importPath: `C:\gopath\src\github.com\org\project\vendor\github.com\org\lib\sub`,
+			regexps:    []string{`^github.com/[^/]+/[^/]+`},
+			want:       "C:/gopath/src/github.com/org/project/vendor/github.com/org/lib",
+		},
+		{
+			name:       "mixed separators",
+			importPath: `github.com/org/project\vendor/github.com\org\lib`,
+			want:       "github.com/org/project/vendor/github.com/org/lib",
+		},
+	} {
+		var regexps []*regexp.Regexp
+		for _, expr := range currCase.regexps {
+			regexps = append(regexps, regexp.MustCompile(expr))
+		}
+		got := novendor.TransformImportPath(currCase.importPath, regexps, "vendor")
+		assert.Equal(t, currCase.want, got, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+// TestTransformImportPathSymmetric verifies that normalizing the vendored form of an import path yields the same key
+// as normalizing the import path itself and adding the vendor prefix.
+func TestTransformImportPathSymmetric(t *testing.T) {
+	regexpSets := map[string][]*regexp.Regexp{
+		"none": nil,
+		"anchored": {
+			regexp.MustCompile(`^github\.com/[^/]+/[^/]+`),
+			regexp.MustCompile(`^gopkg\.in/[^/]+`),
+		},
+		"unanchored": {
+			regexp.MustCompile(`github\.com/[^/]+/[^/]+`),
+			regexp.MustCompile(`golang\.org/[^/]+/[^/]+`),
+		},
+	}
+	importPaths := []string{
+		"github.com/org/lib",
+		"github.com/org/lib/sub/pkg",
+		"gopkg.in/yaml.v2",
+		"gopkg.in/yaml.v2/inner",
+		"golang.org/x/net/context",
+		"example.com/lib",
+	}
+
+	for _, vendorDirName := range []string{"vendor", "third_party"} {
+		prefixes := []string{
+			"github.com/org/project/" + vendorDirName + "/",
+			"github.com/org/project/" + vendorDirName + "/github.com/org/dep/" + vendorDirName + "/",
+			vendorDirName + "/",
+			"_/abs/path/" + vendorDirName + "/",
+		}
+		for regexpsName, regexps := range regexpSets {
+			for _, importPath := range importPaths {
+				direct := novendor.TransformImportPath(importPath, regexps, vendorDirName)
+				for _, prefix := range prefixes {
+					for _, vendored := range []string{prefix + importPath, strings.Replace(prefix+importPath, "/", `\`, -1)} {
+						got := novendor.TransformImportPath(vendored, regexps, vendorDirName)
+						assert.Equal(t, prefix+direct, got, "vendor directory %s, regexps %s, import path %s", vendorDirName, regexpsName, vendored)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestNovendorTrackStdlib(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "fmt"; import _ "net/http"; import _ "github.com/org/lib";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/lib/lib.go",
+			Src:     `package lib; import _ "encoding/csv";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused; import _ "encoding/xml";`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name        string
+		trackStdlib bool
+	}{
+		{
+			name: "standard library imports are not tracked by default",
+		},
+		{
+			name:        "standard library imports are tracked",
+			trackStdlib: true,
+		},
+	} {
+		param := novendor.Param{
+			TrackStdlib: currCase.trackStdlib,
+		}
+
+		result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, param)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, map[string][]string{
+			path.Join(result.ProjectDir, "vendor"): {
+				path.Join(currPkgName, projectDir, "vendor", "github.com/org/unused"),
+			},
+		}, result.UnusedPkgs, "Case %d (%s)", i, currCase.name)
+
+		if !currCase.trackStdlib {
+			assert.Nil(t, result.StdlibImports, "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		// direct imports, imports of vendored packages and transitive imports of standard library packages
+		for _, pkg := range []string{"fmt", "net/http", "encoding/csv", "io", "net"} {
+			assert.Contains(t, result.StdlibImports, pkg, "Case %d (%s)", i, currCase.name)
+		}
+		assert.NotContains(t, result.StdlibImports, "encoding/xml", "Case %d (%s)", i, currCase.name)
+		assert.True(t, sort.StringsAreSorted(result.StdlibImports), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestConfigAdditionalPkgRegexps(t *testing.T) {
+	defaultPkgRegexps := []string{
+		`github\.com/[^/]+/[^/]+`,
+		`gopkg\.in/[^/]+`,
+	}
+	for i, currCase := range []struct {
+		name                 string
+		pkgRegexps           []string
+		additionalPkgRegexps []string
+		want                 []string
+	}{
+		{
+			name:       "default expressions",
+			pkgRegexps: defaultPkgRegexps,
+			want: []string{
+				`^github\.com/[^/]+/[^/]+`,
+				`^gopkg\.in/[^/]+`,
+			},
+		},
+		{
+			name:       "expressions replace the default expressions",
+			pkgRegexps: []string{`gitlab\.org/[^/]+/[^/]+`},
+			want: []string{
+				`^gitlab\.org/[^/]+/[^/]+`,
+			},
+		},
+		{
+			name:                 "additional expressions are appended to the default expressions",
+			pkgRegexps:           defaultPkgRegexps,
+			additionalPkgRegexps: []string{`gitlab\.org/[^/]+/[^/]+`, `^bitbucket\.org/[^/]+/[^/]+`},
+			want: []string{
+				`^github\.com/[^/]+/[^/]+`,
+				`^gopkg\.in/[^/]+`,
+				`^gitlab\.org/[^/]+/[^/]+`,
+				`^bitbucket\.org/[^/]+/[^/]+`,
+			},
+		},
+		{
+			name:                 "additional expressions are appended to the replaced expressions",
+			pkgRegexps:           []string{`gitlab\.org/[^/]+/[^/]+`},
+			additionalPkgRegexps: []string{`bitbucket\.org/[^/]+/[^/]+`},
+			want: []string{
+				`^gitlab\.org/[^/]+/[^/]+`,
+				`^bitbucket\.org/[^/]+/[^/]+`,
+			},
+		},
+	} {
+		config := novendor.Config{
+			PkgRegexps:           currCase.pkgRegexps,
+			AdditionalPkgRegexps: currCase.additionalPkgRegexps,
+		}
+		param, err := config.ToParam()
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		var got []string
+		for _, reg := range param.PkgRegexps {
+			got = append(got, reg.String())
+		}
+		assert.Equal(t, currCase.want, got, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, defaultPkgRegexps, []string{`github\.com/[^/]+/[^/]+`, `gopkg\.in/[^/]+`}, "Case %d (%s): default expressions were modified", i, currCase.name)
+	}
+}
+
+func TestNovendorReportBlankOnly(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name            string
+		reportBlankOnly bool
+		want            string
+	}{
+		{
+			name: "packages imported only using blank imports are not reported by default",
+			want: `github.com/org/unused
+`,
+		},
+		{
+			name:            "packages imported only using blank imports are reported",
+			reportBlankOnly: true,
+			want: `github.com/org/unused
+used only by blank imports: github.com/org/blank
+used only by blank imports: github.com/org/transitive-blank
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main; import _ "github.com/org/blank"; import named "github.com/org/named"; import _ "github.com/org/mixed";`,
+			},
+			{
+				RelPath: "bar.go",
+				Src:     `package main; import "github.com/org/mixed";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/blank/blank.go",
+				Src:     `package blank`,
+			},
+			{
+				RelPath: "vendor/github.com/org/named/named.go",
+				Src:     `package named; import _ "github.com/org/transitive-blank";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/mixed/mixed.go",
+				Src:     `package mixed`,
+			},
+			{
+				RelPath: "vendor/github.com/org/transitive-blank/transitive.go",
+				Src:     `package transitive`,
+			},
+			{
+				RelPath: "vendor/github.com/org/unused/unused.go",
+				Src:     `package unused`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			ReportBlankOnly: currCase.reportBlankOnly,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name    string
+		files   []gofiles.GoFileSpec
+		wantErr string
+	}{
+		{
+			name: "all vendored packages are used",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main; import _ "github.com/org/used";`,
+				},
+				{
+					RelPath: "vendor/github.com/org/used/used.go",
+					Src:     `package used`,
+				},
+			},
+		},
+		{
+			name: "unused vendored packages",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main; import _ "github.com/org/used";`,
+				},
+				{
+					RelPath: "vendor/github.com/org/used/used.go",
+					Src:     `package used`,
+				},
+				{
+					RelPath: "vendor/github.com/org/unused/unused.go",
+					Src:     `package unused`,
+				},
+			},
+			wantErr: "1 unused vendored package(s): unused vendored packages",
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		_, err = gofiles.Write(projectDir, currCase.files)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		err = novendor.Check(projectDir, []string{projectDir + "/."}, novendor.Param{})
+		if currCase.wantErr == "" {
+			assert.NoError(t, err, "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		assert.EqualError(t, err, currCase.wantErr, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, novendor.ErrUnusedPkgs, errors.Cause(err), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorGOPATH(t *testing.T) {
+	gopathDir, cleanup, err := dirs.TempDir("", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir := path.Join(gopathDir, "src", "github.com", "org", "project")
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used"; import _ "github.com/org/external";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+	_, err = gofiles.Write(path.Join(gopathDir, "src"), []gofiles.GoFileSpec{
+		{
+			RelPath: "github.com/org/external/external.go",
+			Src:     `package external`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		GOPATH: gopathDir,
+	}
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, param)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		path.Join(projectDir, "vendor"): {
+			"github.com/org/project/vendor/github.com/org/unused",
+		},
+	}, result.UnusedPkgs)
+
+	used, err := novendor.IsVendoredPackageUsed(projectDir, []string{projectDir + "/."}, "github.com/org/used", param)
+	require.NoError(t, err)
+	assert.True(t, used)
+}
+
+func TestPkgsInDirIterationsBounded(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir("", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name      string
+		files     []gofiles.GoFileSpec
+		wantPkgs  []string
+		wantIters int
+	}{
+		{
+			name: "single package",
+			files: []gofiles.GoFileSpec{
+				{RelPath: "a.go", Src: `package a`},
+				{RelPath: "a_too.go", Src: `package a`},
+			},
+			wantPkgs:  []string{"a"},
+			wantIters: 1,
+		},
+		{
+			name: "one package per file",
+			files: []gofiles.GoFileSpec{
+				{RelPath: "a.go", Src: `package a`},
+				{RelPath: "b.go", Src: `package b`},
+				{RelPath: "c.go", Src: `package c`},
+				{RelPath: "d.go", Src: `package d`},
+				{RelPath: "e.go", Src: `package e`},
+			},
+			wantPkgs:  []string{"a", "b", "c", "d", "e"},
+			wantIters: 5,
+		},
+		{
+			name: "interleaved packages with build constraints",
+			files: []gofiles.GoFileSpec{
+				{RelPath: "a.go", Src: `package a`},
+				{RelPath: "b.go", Src: `package b`},
+				{RelPath: "a_too.go", Src: "// +build cgo\n\npackage a; import \"C\";"},
+				{RelPath: "main.go", Src: "// +build ignore\n\npackage main"},
+				{RelPath: "b_test.go", Src: `package b_test`},
+			},
+			// second pass only contains invalid files and CGo files, so iteration stops
+			wantPkgs:  []string{"a"},
+			wantIters: 2,
+		},
+	} {
+		dir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		_, err = gofiles.Write(dir, currCase.files)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		pkgs, iterations, err := novendor.PkgsInDirIterations(dir)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.wantPkgs, pkgs, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.wantIters, iterations, "Case %d (%s)", i, currCase.name)
+		assert.True(t, iterations <= len(currCase.files)+1, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorRetainWithFiles(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name            string
+		retainWithFiles []string
+		want            string
+	}{
+		{
+			name: "packages with license files are reported by default",
+			want: `github.com/org/licensed
+github.com/org/noticed
+github.com/org/unused
+`,
+		},
+		{
+			name:            "packages with license files are retained",
+			retainWithFiles: []string{"LICENSE"},
+			want: `github.com/org/noticed
+github.com/org/unused
+`,
+		},
+		{
+			name:            "packages with any of the files are retained",
+			retainWithFiles: []string{"LICENSE", "NOTICE"},
+			want: `github.com/org/unused
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main`,
+			},
+			{
+				RelPath: "vendor/github.com/org/licensed/licensed.go",
+				Src:     `package licensed`,
+			},
+			{
+				RelPath: "vendor/github.com/org/noticed/noticed.go",
+				Src:     `package noticed`,
+			},
+			{
+				RelPath: "vendor/github.com/org/unused/unused.go",
+				Src:     `package unused`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		err = ioutil.WriteFile(path.Join(projectDir, "vendor/github.com/org/licensed/LICENSE"), []byte("license"), 0644)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		err = ioutil.WriteFile(path.Join(projectDir, "vendor/github.com/org/noticed/NOTICE"), []byte("notice"), 0644)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		// directory named LICENSE does not cause a package to be retained
+		err = os.MkdirAll(path.Join(projectDir, "vendor/github.com/org/unused/LICENSE"), 0755)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			RetainWithFiles: currCase.retainWithFiles,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorUsedVendored(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/a";`,
+		},
+		{
+			RelPath: "foo_test.go",
+			Src:     `package main; import _ "github.com/org/test";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a; import _ "github.com/org/transitive";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/transitive/transitive.go",
+			Src:     `package transitive`,
+		},
+		{
+			RelPath: "vendor/github.com/org/test/test.go",
+			Src:     `package test`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused2/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		ShowUsed: true,
+	}
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, param)
+	require.NoError(t, err)
+
+	vendorDir := path.Join(result.ProjectDir, "vendor")
+	vendorPrefix := path.Join(currPkgName, projectDir, "vendor") + "/"
+	assert.Equal(t, map[string][]string{
+		vendorDir: {
+			vendorPrefix + "github.com/org/a",
+			vendorPrefix + "github.com/org/test",
+			vendorPrefix + "github.com/org/transitive",
+		},
+	}, result.UsedVendored)
+
+	// used and unused packages are disjoint and together are all of the vendored packages
+	all, err := novendor.ListVendoredPackages(projectDir, []string{projectDir + "/."})
+	require.NoError(t, err)
+	combined := append(append([]string{}, result.UsedVendored[vendorDir]...), result.UnusedPkgs[vendorDir]...)
+	sort.Strings(combined)
+	assert.Equal(t, all[vendorDir], combined)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, novendor.WriteResult(result, param, buf))
+	assert.Equal(t, `github.com/org/unused
+github.com/org/unused2
+used: github.com/org/a
+used: github.com/org/test
+used: github.com/org/transitive
+`, buf.String())
+}
+
+func TestNovendorMetricsFn(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name   string
+		format novendor.Format
+	}{
+		{
+			name:   "text format",
+			format: novendor.FormatText,
+		},
+		{
+			name:   "JSON lines format",
+			format: novendor.FormatJSONL,
+		},
+	} {
+		var phases []string
+		param := novendor.Param{
+			Format: currCase.format,
+			MetricsFn: func(phase string, d time.Duration) {
+				assert.True(t, d >= 0, "Case %d (%s): negative duration for phase %s", i, currCase.name, phase)
+				phases = append(phases, phase)
+			},
+		}
+
+		err = novendor.RunWithWriters(projectDir, []string{projectDir + "/."}, param, ioutil.Discard, ioutil.Discard)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, []string{
+			novendor.PhaseScanVendorDirs,
+			novendor.PhaseCollectImports,
+			novendor.PhaseWriteResult,
+		}, phases, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorMaxOpenFiles(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	specs := []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used0"; import _ "github.com/org/used5";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/malformed/malformed.go",
+			Src:     `package malformed; import`,
+		},
+	}
+	for i := 0; i < 10; i++ {
+		specs = append(specs,
+			gofiles.GoFileSpec{
+				RelPath: fmt.Sprintf("vendor/github.com/org/used%d/used.go", i),
+				Src:     fmt.Sprintf(`package used%d`, i),
+			},
+			gofiles.GoFileSpec{
+				RelPath: fmt.Sprintf("vendor/github.com/org/used%d/sub/sub.go", i),
+				Src:     `package sub`,
+			},
+		)
+	}
+	_, err = gofiles.Write(projectDir, specs)
+	require.NoError(t, err)
+
+	want, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, novendor.Param{
+		ReportWarnings: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, want.UnusedPkgs[path.Join(want.ProjectDir, "vendor")], 19)
+	require.Len(t, want.Warnings, 1)
+
+	for _, maxOpenFiles := range []int{1, 2, 3, 100} {
+		got, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, novendor.Param{
+			ReportWarnings: true,
+			MaxOpenFiles:   maxOpenFiles,
+		})
+		require.NoError(t, err, "MaxOpenFiles %d", maxOpenFiles)
+		assert.Equal(t, want.UnusedPkgs, got.UnusedPkgs, "MaxOpenFiles %d", maxOpenFiles)
+		assert.Equal(t, want.Warnings, got.Warnings, "MaxOpenFiles %d", maxOpenFiles)
+	}
+}
+
+func TestNovendorReportUnbuildable(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name              string
+		reportUnbuildable bool
+		want              string
+	}{
+		{
+			name: "unbuildable packages are not reported by default",
+			want: `github.com/org/unused
+`,
+		},
+		{
+			name:              "unbuildable packages are reported",
+			reportUnbuildable: true,
+			want: `github.com/org/unused
+warning: vendored package github.com/org/ignored cannot be built on any platform
+warning: vendored package github.com/org/impossible cannot be built on any platform
+warning: vendored package github.com/org/removedport cannot be built on any platform
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main; import _ "github.com/org/buildable"; import _ "github.com/org/impossible"; import _ "github.com/org/ignored"; import _ "github.com/org/removedport";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/buildable/buildable.go",
+				Src:     `package buildable`,
+			},
+			{
+				RelPath: "vendor/github.com/org/buildable/buildable_windows.go",
+				Src:     `package buildable`,
+			},
+			{
+				RelPath: "vendor/github.com/org/impossible/impossible.go",
+				Src: `// +build linux,windows
+
+package impossible`,
+			},
+			{
+				RelPath: "vendor/github.com/org/impossible/impossible_test.go",
+				Src:     `package impossible`,
+			},
+			{
+				// nacl is a port that has been removed, so a package that only builds on it cannot be built
+				RelPath: "vendor/github.com/org/removedport/removedport_nacl.go",
+				Src:     `package removedport`,
+			},
+			{
+				RelPath: "vendor/github.com/org/ignored/ignored.go",
+				Src: `// +build ignore
+
+package ignored`,
+			},
+			{
+				RelPath: "vendor/github.com/org/unused/unused.go",
+				Src:     `package unused`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			ReportUnbuildable: currCase.reportUnbuildable,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorSortByVendorDir(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "vendor/github.com/org/d/d.go",
+			Src:     `package d`,
+		},
+		{
+			RelPath: "bar/bar.go",
+			Src:     `package bar`,
+		},
+		{
+			RelPath: "bar/vendor/github.com/org/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "bar/vendor/github.com/org/c/c.go",
+			Src:     `package c`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name  string
+		param novendor.Param
+		want  string
+	}{
+		{
+			name: "output is sorted as a single list by default",
+			param: novendor.Param{
+				IncludeVendorInImportPath: true,
+				RelativePaths:             true,
+			},
+			want: `bar/vendor/github.com/org/a
+bar/vendor/github.com/org/c
+vendor/github.com/org/b
+vendor/github.com/org/d
+`,
+		},
+		{
+			name: "output is grouped by vendor directory",
+			param: novendor.Param{
+				SortByVendorDir: true,
+			},
+			want: `github.com/org/a
+github.com/org/c
+
+github.com/org/b
+github.com/org/d
+`,
+		},
+		{
+			name: "output is grouped by vendor directory with vendor directory in import path",
+			param: novendor.Param{
+				IncludeVendorInImportPath: true,
+				RelativePaths:             true,
+				SortByVendorDir:           true,
+			},
+			want: `bar/vendor/github.com/org/a
+bar/vendor/github.com/org/c
+
+vendor/github.com/org/b
+vendor/github.com/org/d
+`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/..."}, currCase.param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorAncestorVendorDir(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "a/b/c/c.go",
+			Src:     `package c; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/a/b/c"}, novendor.Param{}, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `github.com/org/unused
+`, buf.String())
+
+	vendored, err := novendor.ListVendoredPackages(projectDir, []string{projectDir + "/a/b/c"})
+	require.NoError(t, err)
+	assert.Len(t, vendored, 1)
+}
+
+func TestConfigVendorHosts(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "internal.example.com/org/used/sub";`,
+		},
+		{
+			RelPath: "vendor/internal.example.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/internal.example.com/org/used/sub/sub.go",
+			Src:     `package sub`,
+		},
+		{
+			RelPath: "vendor/internal.example.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "vendor/internal.example.com/org/unused/sub/sub.go",
+			Src:     `package sub`,
+		},
+		{
+			RelPath: "vendor/internalXexample.com/org/other/other.go",
+			Src:     `package other`,
+		},
+	})
+	require.NoError(t, err)
+
+	config := novendor.Config{
+		VendorHosts: []string{"internal.example.com"},
+	}
+	param, err := config.ToParam()
+	require.NoError(t, err)
+
+	var gotRegexps []string
+	for _, reg := range param.PkgRegexps {
+		gotRegexps = append(gotRegexps, reg.String())
+	}
+	assert.Equal(t, []string{`^internal\.example\.com/[^/]+/[^/]+`}, gotRegexps)
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `internal.example.com/org/unused
+internalXexample.com/org/other
+`, buf.String())
+}
+
+func TestNovendorOverlay(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/a";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "vendor/github.com/org/c/c.go",
+			Src:     `package c`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name    string
+		overlay map[string][]byte
+		want    string
+	}{
+		{
+			name: "no overlay",
+			want: `github.com/org/b
+github.com/org/c
+`,
+		},
+		{
+			name: "overlay replaces contents of file on disk",
+			overlay: map[string][]byte{
+				path.Join(projectDir, "foo.go"): []byte(`package main; import _ "github.com/org/a"; import _ "github.com/org/b";`),
+			},
+			want: `github.com/org/c
+`,
+		},
+		{
+			name: "overlay adds file that does not exist on disk",
+			overlay: map[string][]byte{
+				path.Join(projectDir, "bar.go"): []byte(`package main; import _ "github.com/org/c";`),
+			},
+			want: `github.com/org/b
+`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			Overlay: currCase.overlay,
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorReportNotVendored(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src: `package main
+
+import (
+	"fmt"
+
+	_ "github.com/org/missing"
+	_ "github.com/org/vendored"
+)
+
+func main() {
+	fmt.Println()
+}
+`,
+		},
+		{
+			RelPath: "bar/bar.go",
+			Src:     "package bar\n\nimport _ \"github.com/org/missing\"\n",
+		},
+		{
+			RelPath: "bar/bar_test.go",
+			Src:     "package bar\n\nimport _ \"github.com/org/missing-test\"\n",
+		},
+		{
+			RelPath: "vendor/github.com/org/vendored/vendored.go",
+			Src:     `package vendored; import _ "github.com/org/missing-from-vendored";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	param := novendor.Param{
+		ReportNotVendored: true,
+	}
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/..."}, param)
+	require.NoError(t, err)
+	assert.Equal(t, []novendor.NotVendoredImport{
+		{
+			ImportPath: "github.com/org/missing",
+			Positions:  []string{"bar/bar.go:3", "foo.go:6"},
+		},
+		{
+			ImportPath: "github.com/org/missing-test",
+			Positions:  []string{"bar/bar_test.go:3"},
+		},
+	}, result.NotVendoredImports)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, novendor.WriteResult(result, param, buf))
+	assert.Equal(t, `github.com/org/unused
+bar/bar.go:3: import of github.com/org/missing is not vendored
+foo.go:6: import of github.com/org/missing is not vendored
+bar/bar_test.go:3: import of github.com/org/missing-test is not vendored
+`, buf.String())
+}
+
+func TestNovendorMaxUnused(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+	})
+	require.NoError(t, err)
+
+	intPtr := func(i int) *int {
+		return &i
+	}
+	for i, currCase := range []struct {
+		name      string
+		maxUnused *int
+		format    novendor.Format
+		wantErr   string
+	}{
+		{
+			name: "no maximum",
+		},
+		{
+			name:      "below maximum",
+			maxUnused: intPtr(3),
+		},
+		{
+			name:      "at maximum",
+			maxUnused: intPtr(2),
+		},
+		{
+			name:      "above maximum",
+			maxUnused: intPtr(1),
+			wantErr:   "2 unused vendored package(s) exceeds the maximum of 1: unused vendored packages",
+		},
+		{
+			name:      "above maximum of 0",
+			maxUnused: intPtr(0),
+			wantErr:   "2 unused vendored package(s) exceeds the maximum of 0: unused vendored packages",
+		},
+		{
+			name:      "above maximum with JSONL format",
+			maxUnused: intPtr(1),
+			format:    novendor.FormatJSONL,
+			wantErr:   "2 unused vendored package(s) exceeds the maximum of 1: unused vendored packages",
+		},
+	} {
+		param := novendor.Param{
+			MaxUnused: currCase.maxUnused,
+			Format:    currCase.format,
+		}
+
+		out := &bytes.Buffer{}
+		err = novendor.RunWithWriters(projectDir, []string{projectDir + "/."}, param, out, ioutil.Discard)
+		if currCase.wantErr == "" {
+			require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		} else {
+			require.Error(t, err, "Case %d (%s)", i, currCase.name)
+			assert.Equal(t, currCase.wantErr, err.Error(), "Case %d (%s)", i, currCase.name)
+			assert.Equal(t, novendor.ErrUnusedPkgs, errors.Cause(err), "Case %d (%s)", i, currCase.name)
+		}
+		// all unused packages are written regardless of the maximum
+		assert.Contains(t, out.String(), "github.com/org/a", "Case %d (%s)", i, currCase.name)
+		assert.Contains(t, out.String(), "github.com/org/b", "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestRunArchive(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "bar/bar.go",
+			Src:     `package bar; import _ "github.com/org/bar-used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used; import _ "github.com/org/transitive";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/transitive/transitive.go",
+			Src:     `package transitive`,
+		},
+		{
+			RelPath: "vendor/github.com/org/bar-used/bar.go",
+			Src:     `package bar`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name        string
+		archiveName string
+		pkgs        []string
+		wantPkgs    []string
+		want        string
+	}{
+		{
+			name:        "tar.gz archive",
+			archiveName: "project.tar.gz",
+			pkgs:        []string{"./..."},
+			wantPkgs:    []string{projectDir + "/..."},
+			want: `github.com/org/unused
+# 1 unused vendored package(s) across 1 vendor directories
+`,
+		},
+		{
+			name:        "zip archive with relative package",
+			archiveName: "project.zip",
+			pkgs:        []string{"."},
+			wantPkgs:    []string{projectDir + "/."},
+			want: `github.com/org/bar-used
+github.com/org/unused
+# 2 unused vendored package(s) across 1 vendor directories
+`,
+		},
+	} {
+		archivePath := path.Join(tmpDir, currCase.archiveName)
+		writeTestArchive(t, projectDir, archivePath)
+
+		param := novendor.Param{
+			Summary: true,
+		}
+		want := &bytes.Buffer{}
+		err = novendor.Run(projectDir, currCase.wantPkgs, param, want)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		require.Equal(t, currCase.want, want.String(), "Case %d (%s)", i, currCase.name)
+
+		got := &bytes.Buffer{}
+		err = novendor.RunArchive(archivePath, currCase.pkgs, param, got)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, want.String(), got.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestRunArchivePaths(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	archivePath := path.Join(tmpDir, "project.tar.gz")
+	writeTestArchive(t, projectDir, archivePath)
+
+	for i, currCase := range []struct {
+		name  string
+		param novendor.Param
+		want  string
+	}{
+		{
+			name: "absolute paths are relative to the archive root",
+			param: novendor.Param{
+				AbsPaths: true,
+				Stats:    true,
+			},
+			want: `vendor/github.com/org/unused
+stats: vendor: 2 vendored, 1 unused
+`,
+		},
+		{
+			name: "vendor directories of JSON lines are relative to the archive root",
+			param: novendor.Param{
+				Format: novendor.FormatJSONL,
+			},
+			want: `{"vendorDir":"vendor","pkg":"github.com/org/unused"}
+`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.RunArchive(archivePath, nil, currCase.param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+// writeTestArchive writes an archive of the files in the provided directory to the provided path. The archive is a zip
+// file if the path ends in ".zip" and a gzip-compressed tar file otherwise.
+func writeTestArchive(t *testing.T, dir, archivePath string) {
+	f, err := os.Create(archivePath)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, f.Close())
+	}()
+
+	var addFile func(name string, content []byte)
+	var closeArchive func()
+	if strings.HasSuffix(archivePath, ".zip") {
+		zipWriter := zip.NewWriter(f)
+		addFile = func(name string, content []byte) {
+			w, err := zipWriter.Create(name)
+			require.NoError(t, err)
+			_, err = w.Write(content)
+			require.NoError(t, err)
+		}
+		closeArchive = func() {
+			require.NoError(t, zipWriter.Close())
+		}
+	} else {
+		gzipWriter := gzip.NewWriter(f)
+		tarWriter := tar.NewWriter(gzipWriter)
+		addFile = func(name string, content []byte) {
+			require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+				Name:     name,
+				Mode:     0644,
+				Size:     int64(len(content)),
+				Typeflag: tar.TypeReg,
+			}))
+			_, err := tarWriter.Write(content)
+			require.NoError(t, err)
+		}
+		closeArchive = func() {
+			require.NoError(t, tarWriter.Close())
+			require.NoError(t, gzipWriter.Close())
+		}
+	}
+
+	err = filepath.Walk(dir, func(currPath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, currPath)
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadFile(currPath)
+		if err != nil {
+			return err
+		}
+		addFile(filepath.ToSlash(relPath), content)
+		return nil
+	})
+	require.NoError(t, err)
+	closeArchive()
+}
+
+func TestPkgsByImportPath(t *testing.T) {
+	for i, currCase := range []struct {
+		name string
+		pkgs []*build.Package
+		want map[string][]string
+	}{
+		{
+			name: "packages with the same import path",
+			pkgs: []*build.Package{
+				{ImportPath: "github.com/org/a", Name: "a"},
+				{ImportPath: "github.com/org/a", Name: "a_test"},
+			},
+			want: map[string][]string{
+				"github.com/org/a": {"a", "a_test"},
+			},
+		},
+		{
+			name: "packages with differing import paths are all recorded regardless of order",
+			pkgs: []*build.Package{
+				{ImportPath: "github.com/org/b", Name: "b"},
+				{ImportPath: "github.com/org/a", Name: "a"},
+				{ImportPath: "github.com/org/b", Name: "other"},
+			},
+			want: map[string][]string{
+				"github.com/org/a": {"a"},
+				"github.com/org/b": {"b", "other"},
+			},
+		},
+		{
+			name: "import paths without named packages are omitted",
+			pkgs: []*build.Package{
+				{ImportPath: ".", Name: "testdata"},
+				{ImportPath: "github.com/org/unnamed"},
+				{ImportPath: "github.com/org/a", Name: "a"},
+			},
+			want: map[string][]string{
+				"github.com/org/a": {"a"},
+			},
+		},
+	} {
+		got := novendor.PkgNamesByImportPath(currCase.pkgs)
+		assert.Equal(t, currCase.want, got, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestRunWhy(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/a"; import _ "github.com/org/b";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a; import _ "github.com/org/c";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b; import _ "github.com/org/d";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/c/c.go",
+			Src:     `package c; import _ "github.com/org/d";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/d/d.go",
+			Src:     `package d; import _ "github.com/org/e";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/e/e.go",
+			Src:     `package e`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	projectPkg := path.Join(currPkgName, projectDir)
+	for i, currCase := range []struct {
+		name       string
+		importPath string
+		want       string
+		wantErr    string
+	}{
+		{
+			name:       "directly imported package",
+			importPath: "github.com/org/a",
+			want: fmt.Sprintf(`# github.com/org/a
+%s
+github.com/org/a
+`, projectPkg),
+		},
+		{
+			name:       "shortest chain to transitively imported package",
+			importPath: "github.com/org/e",
+			want: fmt.Sprintf(`# github.com/org/e
+%s
+github.com/org/b
+github.com/org/d
+github.com/org/e
+`, projectPkg),
+		},
+		{
+			name:       "unused package",
+			importPath: "github.com/org/unused",
+			want: `# github.com/org/unused
+(github.com/org/unused is not used by the project packages)
+`,
+		},
+		{
+			name:       "package that is not vendored",
+			importPath: "github.com/org/missing",
+			wantErr:    "github.com/org/missing is not a vendored package",
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err := novendor.RunWhy(projectDir, []string{projectDir + "/."}, currCase.importPath, novendor.Param{}, buf)
+		if currCase.wantErr != "" {
+			assert.EqualError(t, err, currCase.wantErr, "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorRecordSeparator(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "vendor/github.com/org/c/c.go",
+			Src:     `package c`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name            string
+		recordSeparator string
+		want            string
+	}{
+		{
+			name: "newline by default",
+			want: "github.com/org/a\ngithub.com/org/b\ngithub.com/org/c\n# 3 unused vendored package(s) across 1 vendor directories\n",
+		},
+		{
+			name:            "NUL separator",
+			recordSeparator: "\x00",
+			want:            "github.com/org/a\x00github.com/org/b\x00github.com/org/c\x00# 3 unused vendored package(s) across 1 vendor directories\n",
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			Summary:         true,
+			RecordSeparator: currCase.recordSeparator,
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorStrictReachability(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a; import _ "github.com/org/b";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name               string
+		strictReachability bool
+		want               string
+	}{
+		{
+			name: "provided vendored packages are treated as project packages by default",
+			want: "",
+		},
+		{
+			name:               "packages reachable only from provided vendored packages are unused",
+			strictReachability: true,
+			want: `github.com/org/a
+github.com/org/b
+`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/.", projectDir + "/vendor/github.com/org/a"}, novendor.Param{
+			StrictReachability: currCase.strictReachability,
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorStrictReachabilityVendorAncestor(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir("", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	// project root has an ancestor directory named "vendor"
+	gopathDir := path.Join(tmpDir, "vendor", "gp")
+	projectDir := path.Join(gopathDir, "src", "github.com", "org", "project")
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+		GOPATH:             gopathDir,
+		StrictReachability: true,
+	}, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `github.com/org/unused
+`, buf.String())
+}
+
+func TestConfigWithEnv(t *testing.T) {
+	baseConfig := novendor.Config{
+		PkgRegexps: []string{`github\.com/[^/]+/[^/]+`},
+		IgnorePkgs: []string{"./ignored"},
+	}
+	for i, currCase := range []struct {
+		name    string
+		env     map[string]string
+		want    novendor.Config
+		wantErr string
+	}{
+		{
+			name: "no environment variables",
+			want: baseConfig,
+		},
+		{
+			name: "empty environment variables are ignored",
+			env: map[string]string{
+				novendor.EnvPkgRegexp:      "",
+				novendor.EnvIgnorePkg:      " ",
+				novendor.EnvFullImportPath: "",
+			},
+			want: baseConfig,
+		},
+		{
+			name: "comma-separated values",
+			env: map[string]string{
+				novendor.EnvPkgRegexp:      `gitlab\.org/[^/]+/[^/]+, gopkg\.in/[^/]+`,
+				novendor.EnvIgnorePkg:      "./foo,./bar",
+				novendor.EnvFullImportPath: "true",
+			},
+			want: novendor.Config{
+				PkgRegexps:                []string{`gitlab\.org/[^/]+/[^/]+`, `gopkg\.in/[^/]+`},
+				IgnorePkgs:                []string{"./foo", "./bar"},
+				IncludeVendorInImportPath: true,
+			},
+		},
+		{
+			name: "newline-separated values",
+			env: map[string]string{
+				novendor.EnvIgnorePkg: "./foo\n./bar\n\n",
+			},
+			want: novendor.Config{
+				PkgRegexps: baseConfig.PkgRegexps,
+				IgnorePkgs: []string{"./foo", "./bar"},
+			},
+		},
+		{
+			name: "invalid boolean",
+			env: map[string]string{
+				novendor.EnvFullImportPath: "maybe",
+			},
+			wantErr: `invalid value for environment variable NOVENDOR_FULL_IMPORT_PATH: strconv.ParseBool: parsing "maybe": invalid syntax`,
+		},
+	} {
+		got, err := baseConfig.WithEnv(func(key string) (string, bool) {
+			val, ok := currCase.env[key]
+			return val, ok
+		})
+		if currCase.wantErr != "" {
+			assert.EqualError(t, err, currCase.wantErr, "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, got, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	negative := -1
+	for i, currCase := range []struct {
+		name    string
+		config  novendor.Config
+		wantErr string
+	}{
+		{
+			name: "valid configuration",
+			config: novendor.Config{
+				PkgRegexps:  []string{`github\.com/[^/]+/[^/]+`},
+				VendorHosts: []string{"internal.example.com"},
+				IgnorePkgs:  []string{"./foo", "bar/..", "/abs/path"},
+				GroupByRepo: true,
+				Platforms:   []string{"linux/amd64"},
+				Format:      novendor.FormatJSONL,
+			},
+		},
+		{
+			name: "empty configuration",
+		},
+		{
+			name: "regular expression that does not compile",
+			config: novendor.Config{
+				PkgRegexps: []string{`github\.com/[`},
+			},
+			wantErr: "invalid configuration: package regular expression \"github\\\\.com/[\" does not compile: error parsing regexp: missing closing ]: `[`",
+		},
+		{
+			name: "duplicate regular expressions",
+			config: novendor.Config{
+				PkgRegexps:           []string{`github\.com/[^/]+/[^/]+`},
+				AdditionalPkgRegexps: []string{`^github\.com/[^/]+/[^/]+`},
+			},
+			wantErr: `invalid configuration: package regular expression "^github\\.com/[^/]+/[^/]+" is specified more than once`,
+		},
+		{
+			name: "regular expression that matches the empty string",
+			config: novendor.Config{
+				PkgRegexps: []string{`.*`},
+			},
+			wantErr: `invalid configuration: package regular expression ".*" matches the empty string`,
+		},
+		{
+			name: "grouping by repository without regular expressions",
+			config: novendor.Config{
+				GroupByRepo: true,
+			},
+			wantErr: "invalid configuration: grouping by repository requires package regular expressions or vendor hosts",
+		},
+		{
+			name: "relative paths without full import path",
+			config: novendor.Config{
+				RelativePaths: true,
+			},
+			wantErr: "invalid configuration: relative paths are only printed when the full import path is included",
+		},
+		{
+			name: "ignored package outside of project",
+			config: novendor.Config{
+				IgnorePkgs: []string{"../other", "foo/../.."},
+			},
+			wantErr: `invalid configuration: ignored package "../other" is outside of the project directory; ignored package "foo/../.." is outside of the project directory`,
+		},
+		{
+			name: "build context override outside of project",
+			config: novendor.Config{
+				PerPkgContext: map[string]novendor.BuildContextOverride{
+					"../other": {},
+				},
+			},
+			wantErr: `invalid configuration: package "../other" with a build context override is outside of the project directory`,
+		},
+		{
+			name: "unknown format and invalid platform",
+			config: novendor.Config{
+				Format:    "xml",
+				Platforms: []string{"linux"},
+			},
+			wantErr: `invalid configuration: unknown format "xml"; platform must be of the form GOOS/GOARCH, was "linux"`,
+		},
+		{
+			name: "negative values",
+			config: novendor.Config{
+				MaxDepth:     -1,
+				MaxOpenFiles: -1,
+				MaxUnused:    &negative,
+			},
+			wantErr: "invalid configuration: maximum depth must be non-negative, was -1; maximum number of open files must be non-negative, was -1; maximum number of unused packages must be non-negative, was -1",
+		},
+		{
+			name: "header template that does not parse",
+			config: novendor.Config{
+				HeaderTemplate: "{{.Count",
+			},
+			wantErr: `invalid configuration: failed to parse header template: template: header:1: unclosed action`,
+		},
+		{
+			name: "invalid Go version",
+			config: novendor.Config{
+				GoVersion: "2.0",
+			},
+			wantErr: `invalid configuration: Go version must be of the form 1.N or go1.N, was "2.0"`,
+		},
+	} {
+		err := currCase.config.Validate()
+		if currCase.wantErr == "" {
+			assert.NoError(t, err, "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		assert.EqualError(t, err, currCase.wantErr, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorProjectImportPath(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+	projectImportPath := path.Join(currPkgName, projectDir)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "foo_test.go",
+			Src:     `package main; import _ "github.com/org/testonly";`,
+		},
+		{
+			RelPath: "lib/lib.go",
+			Src:     `package lib`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/testonly/testonly.go",
+			Src:     `package testonly`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: path.Join("vendor", projectImportPath, "lib/lib.go"),
+			Src:     `package lib`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name              string
+		projectImportPath string
+		want              string
+	}{
+		{
+			name: "vendored copies of project packages are reported by default",
+			want: fmt.Sprintf(`github.com/org/unused
+%s/lib
+`, projectImportPath),
+		},
+		{
+			name:              "vendored copies of project packages are excluded if project import path is specified",
+			projectImportPath: projectImportPath,
+			want: `github.com/org/unused
+`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/..."}, novendor.Param{
+			ProjectImportPath: currCase.projectImportPath,
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorCacheDir(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+	cacheDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	run := func(cacheDir string) (string, []string) {
+		var phases []string
+		buf := &bytes.Buffer{}
+		err := novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			CacheDir: cacheDir,
+			MetricsFn: func(phase string, d time.Duration) {
+				phases = append(phases, phase)
+			},
+		}, buf)
+		require.NoError(t, err)
+		return buf.String(), phases
+	}
+
+	want, _ := run("")
+	assert.Equal(t, "github.com/org/unused\n", want)
+
+	// first run populates the cache
+	got, phases := run(cacheDir)
+	assert.Equal(t, want, got)
+	assert.NotContains(t, phases, novendor.PhaseLoadVendorCache)
+
+	// second run loads the vendored packages from the cache
+	got, phases = run(cacheDir)
+	assert.Equal(t, want, got)
+	assert.Contains(t, phases, novendor.PhaseLoadVendorCache)
+
+	// adding a vendored package invalidates the cache
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "vendor/github.com/org/added/added.go",
+			Src:     `package added`,
+		},
+	})
+	require.NoError(t, err)
+	got, phases = run(cacheDir)
+	assert.Equal(t, "github.com/org/added\ngithub.com/org/unused\n", got)
+	assert.NotContains(t, phases, novendor.PhaseLoadVendorCache)
+
+	got, phases = run(cacheDir)
+	assert.Equal(t, "github.com/org/added\ngithub.com/org/unused\n", got)
+	assert.Contains(t, phases, novendor.PhaseLoadVendorCache)
+}
+
+func TestNovendorShowName(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/go-unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "vendor/github.com/org/multi/bar.go",
+			Src:     `package bar`,
+		},
+		{
+			RelPath: "vendor/github.com/org/multi/foo.go",
+			Src:     `package foo`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name     string
+		showName bool
+		want     string
+	}{
+		{
+			name: "package names are not printed by default",
+			want: `github.com/org/go-unused
+github.com/org/multi
+`,
+		},
+		{
+			name:     "package names are printed if show name is true",
+			showName: true,
+			want: `github.com/org/go-unused (package unused)
+github.com/org/multi (package bar, foo)
+`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			ShowName: currCase.showName,
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorModMode(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+	projectImportPath := path.Join(currPkgName, projectDir)
+	modCacheDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     fmt.Sprintf(`package main; import _ "github.com/org/a"; import _ "%s/lib";`, projectImportPath),
+		},
+		{
+			RelPath: "lib/lib.go",
+			Src:     `package lib; import _ "github.com/org/a/sub"; import _ "github.com/Org/c";`,
+		},
+		{
+			RelPath: "missing/missing.go",
+			Src:     `package missing; import _ "github.com/org/missing";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a; import _ "github.com/org/b";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/sub/sub.go",
+			Src:     `package sub`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "vendor/github.com/org/missing/missing.go",
+			Src:     `package missing`,
+		},
+	})
+	require.NoError(t, err)
+	err = ioutil.WriteFile(path.Join(projectDir, "go.mod"), []byte(fmt.Sprintf(`module %s
+
+require (
+	github.com/Org/c v1.0.0
+	github.com/org/a v1.0.0 // indirect
+)
+`, projectImportPath)), 0644)
+	require.NoError(t, err)
+
+	// version of github.com/org/a in the module cache no longer imports github.com/org/b
+	_, err = gofiles.Write(modCacheDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "github.com/org/a@v1.0.0/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "github.com/org/a@v1.0.0/sub/sub.go",
+			Src:     `package sub`,
+		},
+		{
+			RelPath: "github.com/!org/c@v1.0.0/c.go",
+			Src:     `package c`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name    string
+		modMode novendor.ModMode
+		pkgs    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "vendor directory is used by default",
+			pkgs: []string{"."},
+			want: `github.com/org/missing
+`,
+		},
+		{
+			name:    "vendor directory is used in vendor mode",
+			modMode: novendor.ModVendor,
+			pkgs:    []string{"."},
+			want: `github.com/org/missing
+`,
+		},
+		{
+			name:    "module cache is used in readonly mode",
+			modMode: novendor.ModReadonly,
+			pkgs:    []string{"."},
+			want: `github.com/org/b
+github.com/org/missing
+`,
+		},
+		{
+			name:    "module cache is used in mod mode",
+			modMode: novendor.ModMod,
+			pkgs:    []string{"."},
+			want: `github.com/org/b
+github.com/org/missing
+`,
+		},
+		{
+			name:    "import not provided by a required module is an error in readonly mode",
+			modMode: novendor.ModReadonly,
+			pkgs:    []string{".", "missing"},
+			wantErr: "package github.com/org/missing: no required module provides package",
+		},
+		{
+			name:    "import not provided by a required module is ignored in mod mode",
+			modMode: novendor.ModMod,
+			pkgs:    []string{".", "missing"},
+			want: `github.com/org/b
+github.com/org/missing
+`,
+		},
+		{
+			name:    "import not provided by a required module is resolved in vendor directory in vendor mode",
+			modMode: novendor.ModVendor,
+			pkgs:    []string{".", "missing"},
+		},
+	} {
+		var pkgs []string
+		for _, pkg := range currCase.pkgs {
+			pkgs = append(pkgs, path.Join(projectDir, pkg))
+		}
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, pkgs, novendor.Param{
+			ModMode:     currCase.modMode,
+			ModCacheDir: modCacheDir,
+		}, buf)
+		if currCase.wantErr != "" {
+			require.Error(t, err, "Case %d (%s)", i, currCase.name)
+			assert.Equal(t, novendor.ErrNoRequiredModule, errors.Cause(err), "Case %d (%s)", i, currCase.name)
+			assert.Contains(t, err.Error(), currCase.wantErr, "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorStrictSubpackages(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/lib/sub";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/lib/lib.go",
+			Src:     `package lib`,
+		},
+		{
+			RelPath: "vendor/github.com/org/lib/sub/sub.go",
+			Src:     `package sub`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/inner/inner.go",
+			Src:     `package inner`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name              string
+		strictSubpackages bool
+		want              string
+	}{
+		{
+			name: "parent package is considered used if subpackage is used",
+			want: `github.com/org/unused
+`,
+		},
+		{
+			name:              "parent package is reported if only subpackage is used and strict subpackages is true",
+			strictSubpackages: true,
+			want: `github.com/org/lib
+github.com/org/unused
+`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			PkgRegexps: []*regexp.Regexp{
+				regexp.MustCompile(`^github\.com/[^/]+/[^/]+`),
+			},
+			StrictSubpackages: currCase.strictSubpackages,
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorOnUnused(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+	projectImportPath := path.Join(currPkgName, projectDir)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused2/unused2.go",
+			Src:     `package unused2`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name   string
+		format novendor.Format
+	}{
+		{
+			name:   "text format",
+			format: novendor.FormatText,
+		},
+		{
+			name:   "JSON lines format",
+			format: novendor.FormatJSONL,
+		},
+	} {
+		var got [][3]string
+		var printed []string
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			Format: currCase.format,
+			OnUnused: func(importPath, vendorDir, fullPath string) {
+				// callbacks are invoked before output is written
+				assert.Equal(t, 0, buf.Len(), "Case %d (%s)", i, currCase.name)
+				got = append(got, [3]string{importPath, vendorDir, fullPath})
+				printed = append(printed, importPath)
+			},
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		vendorDir, err := filepath.Abs(path.Join(projectDir, "vendor"))
+		require.NoError(t, err)
+		assert.Equal(t, [][3]string{
+			{"github.com/org/unused", vendorDir, projectImportPath + "/vendor/github.com/org/unused"},
+			{"github.com/org/unused2", vendorDir, projectImportPath + "/vendor/github.com/org/unused2"},
+		}, got, "Case %d (%s)", i, currCase.name)
+
+		if currCase.format == novendor.FormatText {
+			assert.Equal(t, strings.Join(printed, "\n")+"\n", buf.String(), "Case %d (%s)", i, currCase.name)
+		}
+	}
+}
+
+func TestNovendorDirectOnly(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/a";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a; import _ "github.com/org/b";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b; import _ "github.com/org/c";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/c/c.go",
+			Src:     `package c`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name       string
+		directOnly bool
+		maxDepth   int
+		want       string
+	}{
+		{
+			name: "transitive imports are considered by default",
+			want: `github.com/org/unused
+`,
+		},
+		{
+			name:       "only direct imports are considered if direct only is true",
+			directOnly: true,
+			want: `github.com/org/b
+github.com/org/c
+github.com/org/unused
+`,
+		},
+		{
+			name:       "direct only takes precedence over maximum depth",
+			directOnly: true,
+			maxDepth:   2,
+			want: `github.com/org/b
+github.com/org/c
+github.com/org/unused
+`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			DirectOnly: currCase.directOnly,
+			MaxDepth:   currCase.maxDepth,
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorRedundantIgnores(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/ignored/ignored.go",
+			Src:     `package ignored`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	usedIgnore := path.Join(projectDir, "vendor/github.com/org/used")
+	unusedIgnore := path.Join(projectDir, "vendor/github.com/org/ignored")
+
+	for i, currCase := range []struct {
+		name   string
+		format novendor.Format
+		want   string
+	}{
+		{
+			name:   "text format",
+			format: novendor.FormatText,
+			want: fmt.Sprintf(`warning: ignore for %s is redundant: package is used
+`, usedIgnore),
+		},
+		{
+			name:   "JSON lines format",
+			format: novendor.FormatJSONL,
+			want: fmt.Sprintf(`warning: ignore for %s is redundant: package is used
+`, usedIgnore),
+		},
+	} {
+		result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, novendor.Param{
+			IgnorePkgs: []string{usedIgnore, unusedIgnore},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Empty(t, result.RedundantIgnores, "Case %d (%s)", i, currCase.name)
+
+		result, err = novendor.Analyze(projectDir, []string{projectDir + "/."}, novendor.Param{
+			IgnorePkgs:             []string{usedIgnore, unusedIgnore},
+			ReportRedundantIgnores: true,
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, []string{usedIgnore}, result.RedundantIgnores, "Case %d (%s)", i, currCase.name)
+
+		out := &bytes.Buffer{}
+		errOut := &bytes.Buffer{}
+		err = novendor.RunWithWriters(projectDir, []string{projectDir + "/."}, novendor.Param{
+			IgnorePkgs:             []string{usedIgnore, unusedIgnore},
+			ReportRedundantIgnores: true,
+			Format:                 currCase.format,
+		}, out, errOut)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Contains(t, out.String(), "github.com/org/unused", "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, errOut.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorRequireVendor(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name          string
+		files         []gofiles.GoFileSpec
+		requireVendor bool
+		want          string
+		wantNoVendor  bool
+	}{
+		{
+			name: "vendor directory present and all packages used",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main; import _ "github.com/org/used";`,
+				},
+				{
+					RelPath: "vendor/github.com/org/used/used.go",
+					Src:     `package used`,
+				},
+			},
+			requireVendor: true,
+		},
+		{
+			name: "vendor directory present with unused packages",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main; import _ "github.com/org/used";`,
+				},
+				{
+					RelPath: "vendor/github.com/org/used/used.go",
+					Src:     `package used`,
+				},
+				{
+					RelPath: "vendor/github.com/org/unused/unused.go",
+					Src:     `package unused`,
+				},
+			},
+			requireVendor: true,
+			want: `github.com/org/unused
+`,
+		},
+		{
+			name: "vendor directory absent",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main`,
+				},
+			},
+			requireVendor: true,
+			wantNoVendor:  true,
+		},
+		{
+			name: "vendor directory absent is not an error if vendor directory is not required",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main`,
+				},
+			},
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		_, err = gofiles.Write(projectDir, currCase.files)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			RequireVendor: currCase.requireVendor,
+		}, buf)
+		if currCase.wantNoVendor {
+			require.Error(t, err, "Case %d (%s)", i, currCase.name)
+			assert.Equal(t, novendor.ErrNoVendorDir, errors.Cause(err), "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestRunToFile(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused2/unused2.go",
+			Src:     `package unused2`,
+		},
+	})
+	require.NoError(t, err)
+	noVendorDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	zero := 0
+	for i, currCase := range []struct {
+		name       string
+		param      novendor.Param
+		pkgs       []string
+		wantErr    bool
+		wantNoFile bool
+	}{
+		{
+			name: "text format",
+		},
+		{
+			name: "JSON lines format",
+			param: novendor.Param{
+				Format: novendor.FormatJSONL,
+			},
+		},
+		{
+			name: "file is retained if maximum number of unused packages is exceeded",
+			param: novendor.Param{
+				MaxUnused: &zero,
+			},
+			wantErr: true,
+		},
+		{
+			name:       "file is removed if analysis fails",
+			pkgs:       []string{noVendorDir},
+			param:      novendor.Param{RequireVendor: true},
+			wantErr:    true,
+			wantNoFile: true,
+		},
+	} {
+		pkgs := currCase.pkgs
+		if pkgs == nil {
+			pkgs = []string{projectDir + "/."}
+		}
+		outputPath := path.Join(tmpDir, fmt.Sprintf("out-%d.txt", i))
+		// existing content is truncated
+		err = ioutil.WriteFile(outputPath, []byte("existing content\n"), 0644)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		buf := &bytes.Buffer{}
+		wantErr := novendor.Run(projectDir, pkgs, currCase.param, buf)
+		err = novendor.RunToFile(projectDir, pkgs, currCase.param, outputPath)
+		if currCase.wantErr {
+			require.Error(t, err, "Case %d (%s)", i, currCase.name)
+			assert.EqualError(t, err, wantErr.Error(), "Case %d (%s)", i, currCase.name)
+		} else {
+			require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		}
+
+		if currCase.wantNoFile {
+			_, err := os.Stat(outputPath)
+			assert.True(t, os.IsNotExist(err), "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		got, err := ioutil.ReadFile(outputPath)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.NotEqual(t, "", buf.String(), "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, buf.String(), string(got), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorReportShadowed(t *testing.T) {
+	gopathDir, cleanup, err := dirs.TempDir("", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir := path.Join(gopathDir, "src", "github.com", "org", "project")
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/shadowed"; import _ "github.com/org/project/inner";`,
+		},
+		{
+			RelPath: "inner/inner.go",
+			Src:     `package inner; import _ "github.com/org/shadowed"; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "inner/vendor/github.com/org/shadowed/shadowed.go",
+			Src:     `package shadowed`,
+		},
+		{
+			RelPath: "inner/vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+	})
+	require.NoError(t, err)
+	_, err = gofiles.Write(path.Join(gopathDir, "src"), []gofiles.GoFileSpec{
+		{
+			RelPath: "github.com/org/shadowed/shadowed.go",
+			Src:     `package shadowed`,
+		},
+		{
+			RelPath: "github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name           string
+		reportShadowed bool
+		want           string
+	}{
+		{
+			name: "shadowed packages are not reported by default",
+		},
+		{
+			name:           "package imported from GOPATH and vendored is reported",
+			reportShadowed: true,
+			want: fmt.Sprintf("warning: package github.com/org/shadowed is imported from %s but is also vendored in %s\n",
+				path.Join(gopathDir, "src", "github.com", "org", "shadowed"),
+				path.Join(projectDir, "inner", "vendor"),
+			),
+		},
+	} {
+		out := &bytes.Buffer{}
+		errOut := &bytes.Buffer{}
+		err = novendor.RunWithWriters(projectDir, []string{projectDir + "/..."}, novendor.Param{
+			GOPATH:         gopathDir,
+			ReportShadowed: currCase.reportShadowed,
+		}, out, errOut)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, "", out.String(), "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, errOut.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorQuiet(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name    string
+		files   []gofiles.GoFileSpec
+		format  novendor.Format
+		want    string
+		wantErr string
+	}{
+		{
+			name: "nothing is written if there are no unused packages",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main; import _ "github.com/org/used";`,
+				},
+				{
+					RelPath: "vendor/github.com/org/used/used.go",
+					Src:     `package used`,
+				},
+			},
+		},
+		{
+			name: "unused packages are written and error is returned",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main; import _ "github.com/org/used";`,
+				},
+				{
+					RelPath: "vendor/github.com/org/used/used.go",
+					Src:     `package used`,
+				},
+				{
+					RelPath: "vendor/github.com/org/unused/unused.go",
+					Src:     `package unused`,
+				},
+			},
+			want: `github.com/org/unused
+# 1 unused vendored package(s) across 1 vendor directories
+`,
+			wantErr: "1 unused vendored package(s): unused vendored packages",
+		},
+		{
+			name: "JSON lines output is written if there are unused packages",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main`,
+				},
+				{
+					RelPath: "vendor/github.com/org/unused/unused.go",
+					Src:     `package unused`,
+				},
+			},
+			format:  novendor.FormatJSONL,
+			want:    `"pkg":"github.com/org/unused"`,
+			wantErr: "1 unused vendored package(s): unused vendored packages",
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		_, err = gofiles.Write(projectDir, currCase.files)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			Quiet:   true,
+			Summary: true,
+			Format:  currCase.format,
+		}, buf)
+		if currCase.wantErr == "" {
+			require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		} else {
+			assert.EqualError(t, err, currCase.wantErr, "Case %d (%s)", i, currCase.name)
+			assert.Equal(t, novendor.ErrUnusedPkgs, errors.Cause(err), "Case %d (%s)", i, currCase.name)
+		}
+		if currCase.format == novendor.FormatJSONL {
+			// vendor directory in output is absolute
+			assert.Contains(t, buf.String(), currCase.want, "Case %d (%s)", i, currCase.name)
+		} else {
+			assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+		}
+	}
+}
+
+func TestDiffResults(t *testing.T) {
+	for i, currCase := range []struct {
+		name string
+		base *novendor.Result
+		head *novendor.Result
+		want *novendor.ResultDiff
+	}{
+		{
+			name: "newly unused package",
+			base: &novendor.Result{
+				ProjectDir:   "/base",
+				UnusedPkgs:   map[string][]string{},
+				UsedVendored: map[string][]string{"/base/vendor": {"github.com/org/project/vendor/github.com/org/a"}},
+			},
+			head: &novendor.Result{
+				ProjectDir:   "/head",
+				UnusedPkgs:   map[string][]string{"/head/vendor": {"github.com/org/project/vendor/github.com/org/a"}},
+				UsedVendored: map[string][]string{},
+			},
+			want: &novendor.ResultDiff{
+				NewlyUnused: []novendor.DiffPkg{{VendorDir: "vendor", ImportPath: "github.com/org/a"}},
+			},
+		},
+		{
+			name: "package that is no longer unused",
+			base: &novendor.Result{
+				ProjectDir: "/base",
+				UnusedPkgs: map[string][]string{"/base/vendor": {
+					"github.com/org/project/vendor/github.com/org/a",
+					"github.com/org/project/vendor/github.com/org/b",
+				}},
+			},
+			head: &novendor.Result{
+				ProjectDir:   "/head",
+				UsedVendored: map[string][]string{"/head/vendor": {"github.com/org/project/vendor/github.com/org/a"}},
+			},
+			want: &novendor.ResultDiff{
+				NewlyUsed: []novendor.DiffPkg{{VendorDir: "vendor", ImportPath: "github.com/org/a"}},
+				Removed:   []novendor.DiffPkg{{VendorDir: "vendor", ImportPath: "github.com/org/b"}},
+			},
+		},
+		{
+			name: "unchanged unused packages",
+			base: &novendor.Result{
+				ProjectDir: "/base",
+				UnusedPkgs: map[string][]string{
+					"/base/vendor":        {"github.com/org/project/vendor/github.com/org/a"},
+					"/base/nested/vendor": {"github.com/org/project/nested/vendor/github.com/org/b"},
+				},
+			},
+			head: &novendor.Result{
+				ProjectDir: "/head",
+				UnusedPkgs: map[string][]string{
+					"/head/vendor":        {"github.com/org/project/vendor/github.com/org/a"},
+					"/head/nested/vendor": {"github.com/org/project/nested/vendor/github.com/org/b"},
+				},
+			},
+			want: &novendor.ResultDiff{},
+		},
+		{
+			name: "same import path in different vendor directories",
+			base: &novendor.Result{
+				ProjectDir: "/base",
+				UnusedPkgs: map[string][]string{"/base/vendor": {"github.com/org/project/vendor/github.com/org/a"}},
+			},
+			head: &novendor.Result{
+				ProjectDir: "/head",
+				UnusedPkgs: map[string][]string{
+					"/head/vendor":        {"github.com/org/project/vendor/github.com/org/a"},
+					"/head/nested/vendor": {"github.com/org/project/nested/vendor/github.com/org/a"},
+				},
+			},
+			want: &novendor.ResultDiff{
+				NewlyUnused: []novendor.DiffPkg{{VendorDir: "nested/vendor", ImportPath: "github.com/org/a"}},
+			},
+		},
+	} {
+		got := novendor.DiffResults(currCase.base, currCase.head)
+		assert.Equal(t, currCase.want, got, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorReportVendoredTestDeps(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used_test.go",
+			Src:     `package used; import _ "github.com/org/testdep";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used_x_test.go",
+			Src:     `package used_test; import _ "github.com/org/xtestdep";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/testdep/testdep.go",
+			Src:     `package testdep; import _ "github.com/org/transitive";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/xtestdep/xtestdep.go",
+			Src:     `package xtestdep`,
+		},
+		{
+			RelPath: "vendor/github.com/org/transitive/transitive.go",
+			Src:     `package transitive`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused_test.go",
+			Src:     `package unused; import _ "github.com/org/unusedtestdep";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unusedtestdep/unusedtestdep.go",
+			Src:     `package unusedtestdep`,
+		},
+	})
+	require.NoError(t, err)
+
+	vendorPrefix := path.Join(currPkgName, projectDir, "vendor") + "/"
+	for i, currCase := range []struct {
+		name                   string
+		reportVendoredTestDeps bool
+		want                   []string
+	}{
+		{
+			name: "vendored test dependencies are not reported by default",
+		},
+		{
+			name:                   "unused packages reachable through tests of used vendored packages are reported",
+			reportVendoredTestDeps: true,
+			want: []string{
+				vendorPrefix + "github.com/org/testdep",
+				vendorPrefix + "github.com/org/transitive",
+				vendorPrefix + "github.com/org/xtestdep",
+			},
+		},
+	} {
+		result, err := novendor.Analyze(projectDir, []string{projectDir + "/..."}, novendor.Param{
+			ReportVendoredTestDeps: currCase.reportVendoredTestDeps,
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, result.VendoredTestDeps, "Case %d (%s)", i, currCase.name)
+
+		// vendored test dependencies are still reported as unused
+		vendorDir := path.Join(projectDir, "vendor")
+		absVendorDir, err := filepath.Abs(vendorDir)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, []string{
+			vendorPrefix + "github.com/org/testdep",
+			vendorPrefix + "github.com/org/transitive",
+			vendorPrefix + "github.com/org/unused",
+			vendorPrefix + "github.com/org/unusedtestdep",
+			vendorPrefix + "github.com/org/xtestdep",
+		}, result.UnusedPkgs[absVendorDir], "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestTransformImportPathLongestMatch(t *testing.T) {
+	for i, currCase := range []struct {
+		name       string
+		importPath string
+		regexps    []string
+		want       string
+	}{
+		{
+			name:       "more specific expression listed last wins",
+			importPath: "github.com/org/monorepo/modules/foo/inner",
+			regexps:    []string{`^github\.com/[^/]+/[^/]+`, `^github\.com/org/monorepo/modules/[^/]+`},
+			want:       "github.com/org/monorepo/modules/foo",
+		},
+		{
+			name:       "more specific expression listed first wins",
+			importPath: "github.com/org/monorepo/modules/foo/inner",
+			regexps:    []string{`^github\.com/org/monorepo/modules/[^/]+`, `^github\.com/[^/]+/[^/]+`},
+			want:       "github.com/org/monorepo/modules/foo",
+		},
+		{
+			name:       "broad expression is used if specific expression does not match",
+			importPath: "github.com/org/monorepo/other/inner",
+			regexps:    []string{`^github\.com/[^/]+/[^/]+`, `^github\.com/org/monorepo/modules/[^/]+`},
+			want:       "github.com/org/monorepo",
+		},
+		{
+			name:       "longest match is used for vendored import path",
+			importPath: "github.com/org/project/vendor/github.com/org/monorepo/modules/foo/inner",
+			regexps:    []string{`^github\.com/[^/]+/[^/]+`, `^github\.com/org/monorepo/modules/[^/]+`},
+			want:       "github.com/org/project/vendor/github.com/org/monorepo/modules/foo",
+		},
+		{
+			name:       "first expression is used for matches of the same length",
+			importPath: "a.io/b/c.io",
+			regexps:    []string{`^a\.io`, `c\.io$`},
+			want:       "a.io",
+		},
+		{
+			name:       "first expression is used for matches of the same length in reverse order",
+			importPath: "a.io/b/c.io",
+			regexps:    []string{`c\.io$`, `^a\.io`},
+			want:       "c.io",
+		},
+	} {
+		var regexps []*regexp.Regexp
+		for _, expr := range currCase.regexps {
+			regexps = append(regexps, regexp.MustCompile(expr))
+		}
+		got := novendor.TransformImportPath(currCase.importPath, regexps, "vendor")
+		assert.Equal(t, currCase.want, got, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorImportPathPkgs(t *testing.T) {
+	gopathDir, cleanup, err := dirs.TempDir("", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir := path.Join(gopathDir, "src", "github.com", "org", "project")
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "cmd/tool/main.go",
+			Src:     `package main; import _ "github.com/org/a";`,
+		},
+		{
+			RelPath: "lib/lib.go",
+			Src:     `package lib; import _ "github.com/org/b";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name       string
+		importPath string
+		dir        string
+	}{
+		{
+			name:       "import path of package",
+			importPath: "github.com/org/project/cmd/tool",
+			dir:        path.Join(projectDir, "cmd", "tool"),
+		},
+		{
+			name:       "import path pattern",
+			importPath: "github.com/org/project/...",
+			dir:        projectDir + "/...",
+		},
+	} {
+		param := novendor.Param{
+			GOPATH: gopathDir,
+		}
+		want, err := novendor.Analyze(projectDir, []string{currCase.dir}, param)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		got, err := novendor.Analyze(projectDir, []string{currCase.importPath}, param)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, want.UnusedPkgs, got.UnusedPkgs, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, want.UsedVendored, got.UsedVendored, "Case %d (%s)", i, currCase.name)
+	}
+
+	_, err = novendor.Analyze(projectDir, []string{"github.com/org/missing"}, novendor.Param{
+		GOPATH: gopathDir,
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to resolve import path github.com/org/missing")
+}
+
+func TestNovendorContinueOnWalkError(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("directory permissions are not enforced for root")
+	}
+
+	gopathDir, cleanup, err := dirs.TempDir("", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir := path.Join(gopathDir, "src", "github.com", "org", "project")
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unreadable/unreadable.go",
+			Src:     `package unreadable`,
+		},
+	})
+	require.NoError(t, err)
+	unreadableDir := path.Join(projectDir, "vendor", "github.com", "org", "unreadable")
+	require.NoError(t, os.Chmod(unreadableDir, 0))
+	defer func() {
+		_ = os.Chmod(unreadableDir, 0755)
+	}()
+
+	_, err = novendor.Analyze(projectDir, []string{projectDir + "/."}, novendor.Param{
+		GOPATH: gopathDir,
+	})
+	assert.Error(t, err, "analysis should fail by default")
+
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, novendor.Param{
+		GOPATH:              gopathDir,
+		ContinueOnWalkError: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.SkippedDirs))
+	assert.Equal(t, unreadableDir, result.SkippedDirs[0].Dir)
+	assert.True(t, os.IsPermission(result.SkippedDirs[0].Err), "unexpected error: %v", result.SkippedDirs[0].Err)
+
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	err = novendor.RunWithWriters(projectDir, []string{projectDir + "/."}, novendor.Param{
+		GOPATH:              gopathDir,
+		ContinueOnWalkError: true,
+	}, out, errOut)
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/org/unused\n", out.String())
+	assert.Contains(t, errOut.String(), "warning: skipped directory "+unreadableDir+": ")
+}
+
+func TestDiscoverVendorDirs(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/library.go",
+			Src:     `package library`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/vendor/github.com/org/nested/nested.go",
+			Src:     `package nested`,
+		},
+		{
+			RelPath: "a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "a/vendor/github.com/org/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "a/inner/inner.go",
+			Src:     `package inner`,
+		},
+		{
+			RelPath: "b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "b/vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "novendor/novendor.go",
+			Src:     `package novendor`,
+		},
+	})
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	for i, currCase := range []struct {
+		name string
+		pkgs []string
+		want []string
+	}{
+		{
+			name: "project directory is used if no packages are provided",
+			want: []string{
+				path.Join(wd, projectDir, "vendor"),
+			},
+		},
+		{
+			name: "vendor directories of all packages",
+			pkgs: []string{projectDir + "/..."},
+			want: []string{
+				path.Join(wd, projectDir, "a", "vendor"),
+				path.Join(wd, projectDir, "b", "vendor"),
+				path.Join(wd, projectDir, "vendor"),
+			},
+		},
+		{
+			name: "vendor directories of ancestor directories are included",
+			pkgs: []string{projectDir + "/a/inner"},
+			want: []string{
+				path.Join(wd, projectDir, "a", "vendor"),
+				path.Join(wd, projectDir, "vendor"),
+			},
+		},
+		{
+			name: "vendor directory of project is used for package without vendor directory",
+			pkgs: []string{projectDir + "/novendor"},
+			want: []string{
+				path.Join(wd, projectDir, "vendor"),
+			},
+		},
+	} {
+		got, err := novendor.DiscoverVendorDirs(projectDir, currCase.pkgs)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, got, "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorDuplicatePkgs(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "a/a.go",
+			Src:     `package a; import _ "github.com/org/library";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/library.go",
+			Src:     `package library`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	logBuf := &bytes.Buffer{}
+	buf := &bytes.Buffer{}
+	err = novendor.Run(projectDir, []string{
+		projectDir + "/...",
+		projectDir + "/a",
+		projectDir + "/./a/",
+		path.Join(wd, projectDir, "a"),
+	}, novendor.Param{
+		Logger: log.New(logBuf, "", 0),
+	}, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/org/unused\n", buf.String())
+
+	aDir := path.Join(wd, projectDir, "a")
+	assert.Equal(t, 1, strings.Count(logBuf.String(), fmt.Sprintf("examining package %s/%s/a in %s\n", currPkgName, projectDir, aDir)))
+}
+
+func TestNovendorReportTemplates(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "vendor/github.com/org/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "vendor/github.com/org/b/b.go",
+			Src:     `package b`,
+		},
+	})
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	for i, currCase := range []struct {
+		name    string
+		param   novendor.Param
+		want    string
+		wantErr string
+	}{
+		{
+			name: "no templates",
+			want: "github.com/org/a\ngithub.com/org/b\n",
+		},
+		{
+			name: "header and footer are rendered",
+			param: novendor.Param{
+				HeaderTemplate: "Vendor directories:{{range .VendorDirs}} {{.}}{{end}}\n",
+				FooterTemplate: "{{.Count}} unused packages",
+			},
+			want: fmt.Sprintf("Vendor directories: %s\ngithub.com/org/a\ngithub.com/org/b\n2 unused packages\n", path.Join(wd, projectDir, "vendor")),
+		},
+		{
+			name: "footer is rendered after summary",
+			param: novendor.Param{
+				Summary:        true,
+				FooterTemplate: "project: {{.ProjectDir}}",
+			},
+			want: fmt.Sprintf("github.com/org/a\ngithub.com/org/b\n# 2 unused vendored package(s) across 1 vendor directories\nproject: %s\n", path.Join(wd, projectDir)),
+		},
+		{
+			name: "templates are not rendered for other formats",
+			param: novendor.Param{
+				Format:         novendor.FormatDOT,
+				HeaderTemplate: "header",
+			},
+		},
+		{
+			name: "template that fails to execute",
+			param: novendor.Param{
+				FooterTemplate: "{{.Unknown}}",
+			},
+			wantErr: "failed to render footer template",
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err := novendor.Run(projectDir, []string{projectDir + "/."}, currCase.param, buf)
+		if currCase.wantErr != "" {
+			require.Error(t, err, "Case %d (%s)", i, currCase.name)
+			assert.Contains(t, err.Error(), currCase.wantErr, "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		if currCase.param.Format == novendor.FormatDOT {
+			assert.NotContains(t, buf.String(), "header", "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorCheckDirNames(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "gopkg.in/yaml.v2"; import _ "github.com/org/library";`,
+		},
+		{
+			// directory was renamed from "yaml.v2"
+			RelPath: "vendor/gopkg.in/yaml/yaml.go",
+			Src:     `package yaml // import "gopkg.in/yaml.v2"`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/library.go",
+			Src:     `package library // import "github.com/org/library"`,
+		},
+	})
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	yamlDir := path.Join(wd, projectDir, "vendor", "gopkg.in", "yaml")
+	for i, currCase := range []struct {
+		name          string
+		checkDirNames bool
+		want          []novendor.DirNameMismatch
+		wantOut       string
+	}{
+		{
+			name:    "directory names are not checked by default",
+			wantOut: "gopkg.in/yaml\n",
+		},
+		{
+			name:          "renamed directory is reported",
+			checkDirNames: true,
+			want: []novendor.DirNameMismatch{
+				{
+					Dir:                yamlDir,
+					ImportPath:         "gopkg.in/yaml",
+					ExpectedImportPath: "gopkg.in/yaml.v2",
+				},
+			},
+			wantOut: fmt.Sprintf("gopkg.in/yaml\nwarning: package gopkg.in/yaml in %s is in a directory whose name does not match import path gopkg.in/yaml.v2\n", yamlDir),
+		},
+	} {
+		param := novendor.Param{
+			CheckDirNames: currCase.checkDirNames,
+		}
+		result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, param)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, result.DirNameMismatches, "Case %d (%s)", i, currCase.name)
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.wantOut, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorFailOnMissingVendoring(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name    string
+		files   []gofiles.GoFileSpec
+		wantErr string
+	}{
+		{
+			name: "external imports without vendor directory",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main; import _ "github.com/org/library"; import _ "fmt";`,
+				},
+			},
+			wantErr: "import 1 package(s) outside of the project (github.com/org/library): external packages are imported but nothing is vendored",
+		},
+		{
+			name: "only standard library and project imports",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main; import _ "fmt"; import _ "{{index . "bar/bar.go"}}";`,
+				},
+				{
+					RelPath: "bar/bar.go",
+					Src:     `package bar`,
+				},
+			},
+		},
+		{
+			name: "external imports are vendored",
+			files: []gofiles.GoFileSpec{
+				{
+					RelPath: "foo.go",
+					Src:     `package main; import _ "github.com/org/library";`,
+				},
+				{
+					RelPath: "vendor/github.com/org/library/library.go",
+					Src:     `package library`,
+				},
+			},
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		_, err = gofiles.Write(projectDir, currCase.files)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		err = novendor.Run(projectDir, []string{projectDir + "/..."}, novendor.Param{
+			FailOnMissingVendoring: true,
+		}, ioutil.Discard)
+		if currCase.wantErr == "" {
+			require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+			continue
+		}
+		require.Error(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, novendor.ErrMissingVendoring, errors.Cause(err), "Case %d (%s)", i, currCase.name)
+		assert.Contains(t, err.Error(), currCase.wantErr, "Case %d (%s)", i, currCase.name)
+	}
+
+	// analysis does not fail by default
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/library";`,
+		},
+	})
+	require.NoError(t, err)
+	assert.NoError(t, novendor.Run(projectDir, []string{projectDir + "/..."}, novendor.Param{}, ioutil.Discard))
+}
+
+func TestNovendorAllowUnusedGrouped(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/a/a.go",
+			Src:     `package a`,
+		},
+		{
+			RelPath: "vendor/github.com/org/library/b/b.go",
+			Src:     `package b`,
+		},
+		{
+			RelPath: "vendor/github.com/org/other/other.go",
+			Src:     `package other`,
+		},
+	})
+	require.NoError(t, err)
+
+	groupRegexps := []*regexp.Regexp{regexp.MustCompile(`^github\.com/[^/]+/[^/]+`)}
+	for i, currCase := range []struct {
+		name        string
+		regexps     []*regexp.Regexp
+		allowUnused []string
+		want        string
+	}{
+		{
+			name:        "subpackage entry only allows subpackage without grouping",
+			allowUnused: []string{"github.com/org/library/a"},
+			want: `github.com/org/library/b
+github.com/org/other
+`,
+		},
+		{
+			name:        "subpackage entry allows grouped repository",
+			regexps:     groupRegexps,
+			allowUnused: []string{"github.com/org/library/a"},
+			want: `github.com/org/other
+`,
+		},
+		{
+			name:        "repository entry allows grouped repository",
+			regexps:     groupRegexps,
+			allowUnused: []string{"github.com/org/library"},
+			want: `github.com/org/other
+`,
+		},
+		{
+			name:        "grouped repository is reported without matching entry",
+			regexps:     groupRegexps,
+			allowUnused: []string{"github.com/org/unrelated/a"},
+			want: `github.com/org/library
+github.com/org/other
+`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			PkgRegexps:  currCase.regexps,
+			AllowUnused: currCase.allowUnused,
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorGitHubActionsFormat(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+		{
+			RelPath: "inner/inner.go",
+			Src:     `package inner`,
+		},
+		{
+			RelPath: "inner/vendor/gopkg.in/yaml.v2/yaml.go",
+			Src:     `package yaml`,
+		},
+	})
+	require.NoError(t, err)
+
+	zero := 0
+	for i, currCase := range []struct {
+		name      string
+		maxUnused *int
+		want      string
+		wantErr   bool
+	}{
+		{
+			name: "unused packages are reported as warnings",
+			want: `::warning file=inner/vendor/gopkg.in/yaml.v2::unused vendored package gopkg.in/yaml.v2
+::warning file=vendor/github.com/org/unused::unused vendored package github.com/org/unused
+`,
+		},
+		{
+			name:      "unused packages are reported as errors if they cause a failure",
+			maxUnused: &zero,
+			want: `::error file=inner/vendor/gopkg.in/yaml.v2::unused vendored package gopkg.in/yaml.v2
+::error file=vendor/github.com/org/unused::unused vendored package github.com/org/unused
+`,
+			wantErr: true,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/..."}, novendor.Param{
+			Format:    novendor.FormatGitHubActions,
+			MaxUnused: currCase.maxUnused,
+		}, buf)
+		if currCase.wantErr {
+			assert.Equal(t, novendor.ErrUnusedPkgs, errors.Cause(err), "Case %d (%s)", i, currCase.name)
+		} else {
+			require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		}
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorGoVersion(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name      string
+		goVersion string
+		want      string
+	}{
+		{
+			name:      "import gated by release tag is excluded from build if Go version is older",
+			goVersion: "1.4",
+			want: `used only by build-ignored files: github.com/org/go15
+`,
+		},
+		{
+			name:      "import gated by release tag is included in build if Go version matches",
+			goVersion: "go1.5",
+			want:      "",
+		},
+		{
+			name:      "patch release of newer Go version includes import gated by release tag",
+			goVersion: "1.6.2",
+			want:      "",
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main`,
+			},
+			{
+				RelPath: "foo_go15.go",
+				Src: `// +build go1.5
+
+package main; import _ "github.com/org/go15";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/go15/go15.go",
+				Src:     `package go15`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		param := novendor.Param{
+			OnlyBuildIgnored: true,
+			GoVersion:        currCase.goVersion,
+		}
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestRunMulti(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	var projects []novendor.ProjectSpec
+	for _, unused := range []string{"github.com/org/first", "github.com/org/second"} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err)
+		projectDir, err = filepath.Abs(projectDir)
+		require.NoError(t, err)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main; import _ "github.com/org/used";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/used/used.go",
+				Src:     `package used`,
+			},
+			{
+				RelPath: "vendor/" + unused + "/unused.go",
+				Src:     `package unused`,
+			},
+		})
+		require.NoError(t, err)
+		projects = append(projects, novendor.ProjectSpec{
+			ProjectDir: projectDir,
+		})
+	}
+	projects[1].Pkgs = []string{"."}
+
+	for i, currCase := range []struct {
+		name  string
+		param novendor.Param
+		want  func(projects []novendor.ProjectSpec) string
+	}{
+		{
+			name: "projects are analyzed independently",
+			want: func(projects []novendor.ProjectSpec) string {
+				return fmt.Sprintf(`project %s:
+github.com/org/first
+project %s:
+github.com/org/second
+`, projects[0].ProjectDir, projects[1].ProjectDir)
+			},
+		},
+		{
+			name: "projects are analyzed concurrently",
+			param: novendor.Param{
+				MaxConcurrentProjects: 2,
+			},
+			want: func(projects []novendor.ProjectSpec) string {
+				return fmt.Sprintf(`project %s:
+github.com/org/first
+project %s:
+github.com/org/second
+`, projects[0].ProjectDir, projects[1].ProjectDir)
+			},
+		},
+		{
+			name: "JSON lines include the project",
+			param: novendor.Param{
+				Format:                novendor.FormatJSONL,
+				MaxConcurrentProjects: 2,
+			},
+			want: func(projects []novendor.ProjectSpec) string {
+				return fmt.Sprintf(`{"vendorDir":"%s/vendor","pkg":"github.com/org/first","project":"%s"}
+{"vendorDir":"%s/vendor","pkg":"github.com/org/second","project":"%s"}
+`, projects[0].ProjectDir, projects[0].ProjectDir, projects[1].ProjectDir, projects[1].ProjectDir)
+			},
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err := novendor.RunMulti(projects, currCase.param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want(projects), buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+
+	maxUnused := 0
+	err = novendor.RunMulti(append(projects, novendor.ProjectSpec{
+		ProjectDir: filepath.Join(tmpDir, "missing"),
+	}), novendor.Param{
+		MaxUnused: &maxUnused,
+	}, ioutil.Discard)
+	require.Error(t, err)
+	assert.NotEqual(t, novendor.ErrUnusedPkgs, errors.Cause(err))
+	assert.Contains(t, err.Error(), "project "+filepath.Join(tmpDir, "missing"))
+}
+
+func TestNovendorStaleIgnores(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/ignored/ignored.go",
+			Src:     `package ignored`,
+		},
+		{
+			RelPath: "vendor/github.com/org/empty/README.md",
+			Src:     `empty`,
+		},
+	})
+	require.NoError(t, err)
+
+	existingIgnore := path.Join(projectDir, "vendor/github.com/org/ignored")
+	emptyIgnore := path.Join(projectDir, "vendor/github.com/org/empty")
+	missingIgnore := path.Join(projectDir, "vendor/github.com/org/removed")
+
+	for i, currCase := range []struct {
+		name   string
+		format novendor.Format
+	}{
+		{
+			name:   "text format",
+			format: novendor.FormatText,
+		},
+		{
+			name:   "JSON lines format",
+			format: novendor.FormatJSONL,
+		},
+	} {
+		param := novendor.Param{
+			IgnorePkgs:         []string{missingIgnore, existingIgnore, emptyIgnore},
+			ReportStaleIgnores: true,
+			Format:             currCase.format,
+		}
+		result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, param)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, []string{emptyIgnore, missingIgnore}, result.StaleIgnores, "Case %d (%s)", i, currCase.name)
+
+		out := &bytes.Buffer{}
+		errOut := &bytes.Buffer{}
+		err = novendor.RunWithWriters(projectDir, []string{projectDir + "/."}, param, out, errOut)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, fmt.Sprintf(`warning: ignore for %s is stale: package does not exist
+warning: ignore for %s is stale: package does not exist
+`, emptyIgnore, missingIgnore), errOut.String(), "Case %d (%s)", i, currCase.name)
+	}
+
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, novendor.Param{
+		IgnorePkgs: []string{missingIgnore},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result.StaleIgnores)
+}
+
+func TestNovendorCaseInsensitive(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	caseInsensitive := true
+	caseSensitive := false
+	for i, currCase := range []struct {
+		name            string
+		caseInsensitive *bool
+		want            string
+	}{
+		{
+			name:            "package whose vendored directory differs in case from the import is used",
+			caseInsensitive: &caseInsensitive,
+			want: `github.com/org/unused
+`,
+		},
+		{
+			name:            "package whose vendored directory differs in case from the import is unused if matching is case-sensitive",
+			caseInsensitive: &caseSensitive,
+			want: `Github.com/Org/Lib
+github.com/org/unused
+`,
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main; import _ "github.com/org/lib";`,
+			},
+			{
+				RelPath: "vendor/Github.com/Org/Lib/lib.go",
+				Src:     `package lib`,
+			},
+			{
+				RelPath: "vendor/github.com/org/unused/unused.go",
+				Src:     `package unused`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		// simulate a case-insensitive filesystem: links are not followed when determining the vendored packages, but
+		// allow the import to resolve to the directory whose name differs in case
+		require.NoError(t, os.Symlink(path.Join("..", "..", "Github.com", "Org", "Lib"), path.Join(projectDir, "vendor", "github.com", "org", "lib")), "Case %d (%s)", i, currCase.name)
+
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/."}, novendor.Param{
+			CaseInsensitive: currCase.caseInsensitive,
+		}, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name      string
+		dryRun    bool
+		wantOut   string
+		wantFiles []string
+	}{
+		{
+			name:   "dry run does not remove packages",
+			dryRun: true,
+			wantOut: `would remove vendor/github.com/org/lib
+would remove vendor/github.com/org/unused
+would remove vendor/github.com/org/unused/nested
+would remove vendor/github.com/other/empty/parent/pkg
+`,
+			wantFiles: []string{
+				"foo.go",
+				"vendor/github.com/org/allowed/allowed.go",
+				"vendor/github.com/org/lib/lib.go",
+				"vendor/github.com/org/lib/sub/sub.go",
+				"vendor/github.com/org/unused/LICENSE",
+				"vendor/github.com/org/unused/nested/nested.go",
+				"vendor/github.com/org/unused/unused.go",
+				"vendor/github.com/other/empty/parent/pkg/pkg.go",
+			},
+		},
+		{
+			name: "unused packages are removed",
+			wantOut: `removed vendor/github.com/org/lib
+removed vendor/github.com/org/unused
+removed vendor/github.com/org/unused/nested
+removed vendor/github.com/other/empty/parent/pkg
+`,
+			wantFiles: []string{
+				"foo.go",
+				"vendor/github.com/org/allowed/allowed.go",
+				"vendor/github.com/org/lib/sub/sub.go",
+			},
+		},
+	} {
+		projectDir, err := ioutil.TempDir(tmpDir, "")
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+			{
+				RelPath: "foo.go",
+				Src:     `package main; import _ "github.com/org/lib/sub";`,
+			},
+			{
+				RelPath: "vendor/github.com/org/allowed/allowed.go",
+				Src:     `package allowed`,
+			},
+			{
+				RelPath: "vendor/github.com/org/lib/lib.go",
+				Src:     `package lib`,
+			},
+			{
+				RelPath: "vendor/github.com/org/lib/sub/sub.go",
+				Src:     `package sub`,
+			},
+			{
+				RelPath: "vendor/github.com/org/unused/LICENSE",
+				Src:     `license`,
+			},
+			{
+				RelPath: "vendor/github.com/org/unused/nested/nested.go",
+				Src:     `package nested`,
+			},
+			{
+				RelPath: "vendor/github.com/org/unused/unused.go",
+				Src:     `package unused`,
+			},
+			{
+				RelPath: "vendor/github.com/other/empty/parent/pkg/pkg.go",
+				Src:     `package pkg`,
+			},
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+
+		buf := &bytes.Buffer{}
+		err = novendor.RunPrune(projectDir, []string{projectDir + "/."}, novendor.Param{
+			AllowUnused: []string{"github.com/org/allowed"},
+		}, currCase.dryRun, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.wantOut, buf.String(), "Case %d (%s)", i, currCase.name)
+
+		var gotFiles []string
+		err = filepath.Walk(projectDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				relPath, err := filepath.Rel(projectDir, path)
+				if err != nil {
+					return err
+				}
+				gotFiles = append(gotFiles, filepath.ToSlash(relPath))
+			}
+			return nil
+		})
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.wantFiles, gotFiles, "Case %d (%s)", i, currCase.name)
+		_, err = os.Stat(filepath.Join(projectDir, "vendor", "github.com", "other"))
+		assert.Equal(t, !currCase.dryRun, os.IsNotExist(err), "Case %d (%s)", i, currCase.name)
+	}
+}
+
+func TestNovendorUnusedPkgDirsAndGroupKeys(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir(".", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	projectDir, err := ioutil.TempDir(tmpDir, "")
+	require.NoError(t, err)
+	projectDir, err = filepath.Abs(projectDir)
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "github.com/org/used";`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/used.go",
+			Src:     `package used`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/sub/sub.go",
+			Src:     `package sub`,
+		},
+		{
+			RelPath: "vendor/other.org/lib/lib.go",
+			Src:     `package lib`,
+		},
+	})
+	require.NoError(t, err)
+
+	result, err := novendor.Analyze(projectDir, []string{projectDir + "/."}, novendor.Param{
+		PkgRegexps: []*regexp.Regexp{regexp.MustCompile(`github\.com/[^/]+/[^/]+`)},
+		// analyze at the granularity of individual packages so that the group key differs from the import path
+		GroupByRepo: true,
+	})
+	require.NoError(t, err)
+
+	vendorDir := path.Join(projectDir, "vendor")
+	vendorImportPath := path.Join(currPkgName, tmpDir, path.Base(projectDir), "vendor")
+	assert.Equal(t, map[string]string{
+		vendorImportPath + "/github.com/org/unused/sub": path.Join(vendorDir, "github.com/org/unused/sub"),
+		vendorImportPath + "/other.org/lib":             path.Join(vendorDir, "other.org/lib"),
+	}, result.UnusedPkgDirs)
+	assert.Equal(t, map[string]string{
+		vendorImportPath + "/github.com/org/unused/sub": "github.com/org/unused",
+		vendorImportPath + "/other.org/lib":             "other.org/lib",
+	}, result.GroupKeys)
+}
+
+func TestNovendorVendoredModules(t *testing.T) {
+	// project is not in a GOPATH, so imports can only be resolved using module semantics
+	projectDir, cleanup, err := dirs.TempDir("", "")
+	defer cleanup()
+	require.NoError(t, err)
+
+	_, err = gofiles.Write(projectDir, []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package main; import _ "example.com/project/lib";`,
+		},
+		{
+			RelPath: "lib/lib.go",
+			Src:     `package lib; import _ "github.com/org/used/sub";`,
+		},
+		{
+			RelPath: "nested/nested.go",
+			Src:     `package nested`,
+		},
+		{
+			RelPath: "nested/vendor/github.com/org/nested/nested.go",
+			Src:     `package nested`,
+		},
+		{
+			RelPath: "vendor/github.com/org/used/sub/sub.go",
+			Src:     `package sub; import _ "golang.org/x/dep";`,
+		},
+		{
+			RelPath: "vendor/golang.org/x/dep/dep.go",
+			Src:     `package dep`,
+		},
+		{
+			RelPath: "vendor/github.com/org/unused/unused.go",
+			Src:     `package unused`,
+		},
+	})
+	require.NoError(t, err)
+	err = ioutil.WriteFile(path.Join(projectDir, "go.mod"), []byte(`module example.com/project
+
+require (
+	github.com/org/unused v1.0.0
+	github.com/org/used v1.0.0
+)
+`), 0644)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(path.Join(projectDir, "vendor", "modules.txt"), []byte(`# github.com/org/unused v1.0.0
+## explicit
+github.com/org/unused
+# github.com/org/used v1.0.0
+## explicit
+github.com/org/used/sub
+# golang.org/x/dep v0.1.0
+golang.org/x/dep
+`), 0644)
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		name  string
+		param novendor.Param
+		want  string
+	}{
+		{
+			name: "vendored modules are resolved using module semantics",
+			want: `github.com/org/unused
+`,
+		},
+		{
+			name: "vendored packages are identified by the import path of the main module",
+			param: novendor.Param{
+				IncludeVendorInImportPath: true,
+			},
+			want: `example.com/project/vendor/github.com/org/unused
+`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err = novendor.Run(projectDir, []string{projectDir + "/..."}, currCase.param, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, buf.String(), "Case %d (%s)", i, currCase.name)
+	}
+}
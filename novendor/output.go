@@ -0,0 +1,564 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// SummaryPrefix is the prefix of the summary line printed by Run.
+const SummaryPrefix = "# "
+
+// WriteResult writes the provided result to the provided writer in the format specified by param. Warnings in the
+// result are not written. If the format is FormatJSONL, only the JSON lines are written. Returns an error if the JSON
+// lines cannot be written.
+func WriteResult(result *Result, param Param, w io.Writer) error {
+	errOut := w
+	if param.Format == FormatJSONL {
+		errOut = ioutil.Discard
+	}
+	return writeResult(result, param, w, errOut)
+}
+
+// writeResult writes the unused packages in the provided result (or the graph, if the format is FormatDOT) to out and
+// all other output to errOut. Warnings in the result are not written. Returns an error if the format is FormatJSONL
+// and the JSON lines cannot be written: errors writing the other formats are ignored.
+func writeResult(result *Result, param Param, out, errOut io.Writer) error {
+	if param.Format == FormatDOT {
+		writeDOT(result, param, out)
+		return nil
+	}
+	if param.Format == FormatGitHubActions {
+		writeGitHubActions(result, param, out)
+		return nil
+	}
+	if param.Format == FormatJSONL {
+		if err := writeJSONLines(result, param, out); err != nil {
+			return err
+		}
+		writeIgnoreWarnings(result, errOut)
+		return nil
+	}
+
+	numUnused := 0
+	var lines []string
+	// iterate over vendor directories in sorted order so that output is deterministic
+	for _, vendorDir := range sortedKeys(result.UnusedPkgs) {
+		v := result.UnusedPkgs[vendorDir]
+		numUnused += len(v)
+		if param.GroupByRepo || param.SortByVendorDir {
+			continue
+		}
+		for _, importPath := range v {
+			lines = append(lines, outputLine(result, vendorDir, importPath, param))
+		}
+	}
+
+	if param.SortByVendorDir && !param.GroupByRepo {
+		writeByVendorDir(result, param, out)
+	} else {
+		if param.GroupByRepo {
+			lines = groupedByRepo(result, param)
+		}
+		sort.Strings(lines)
+		if param.Dedupe && !param.IncludeVendorInImportPath && !param.GroupByRepo {
+			lines = dedupeSorted(lines)
+		}
+
+		for _, pkg := range lines {
+			fmt.Fprint(out, pkg, param.recordSeparator())
+		}
+	}
+
+	if param.ShowImporters {
+		for _, pkg := range sortedKeys(result.Importers) {
+			fmt.Fprintf(errOut, "used: %s (imported by %s)\n", outputImportPath(pkg, param.IncludeVendorInImportPath, param.vendorDirName()), strings.Join(result.Importers[pkg], ", "))
+		}
+	}
+
+	if param.ShowUsed && !param.ShowImporters {
+		for _, vendorDir := range sortedKeys(result.UsedVendored) {
+			for _, pkg := range result.UsedVendored[vendorDir] {
+				fmt.Fprintf(errOut, "used: %s\n", outputImportPath(pkg, param.IncludeVendorInImportPath, param.vendorDirName()))
+			}
+		}
+	}
+
+	for _, pkg := range result.UsedOnlyByIgnoredPkgs {
+		fmt.Fprintf(errOut, "used only by ignored packages: %s\n", outputImportPath(pkg, param.IncludeVendorInImportPath, param.vendorDirName()))
+	}
+
+	writeIgnoreWarnings(result, errOut)
+
+	for _, pkg := range result.BlankOnlyPkgs {
+		fmt.Fprintf(errOut, "used only by blank imports: %s\n", outputImportPath(pkg, param.IncludeVendorInImportPath, param.vendorDirName()))
+	}
+
+	for _, pkg := range result.OnlyBuildIgnoredPkgs {
+		fmt.Fprintf(errOut, "used only by build-ignored files: %s\n", outputImportPath(pkg, param.IncludeVendorInImportPath, param.vendorDirName()))
+	}
+
+	for _, pkg := range result.VendoredTestDeps {
+		fmt.Fprintf(errOut, "used only by tests of vendored packages: %s\n", outputImportPath(pkg, param.IncludeVendorInImportPath, param.vendorDirName()))
+	}
+
+	if param.ReportEmpty {
+		for _, vendorDir := range sortedKeys(result.EmptyDirs) {
+			for _, dir := range result.EmptyDirs[vendorDir] {
+				if !param.IncludeVendorInImportPath {
+					dir = strings.TrimPrefix(filepath.ToSlash(dir), filepath.ToSlash(vendorDir)+"/")
+				} else {
+					dir = displayPath(dir, param)
+				}
+				fmt.Fprintf(errOut, "empty: %s\n", dir)
+			}
+		}
+	}
+
+	for _, mismatch := range result.ImportCommentMismatches {
+		fmt.Fprintf(errOut, "warning: package %s in %s has import comment %q\n", mismatch.ImportPath, displayPath(mismatch.Dir, param), mismatch.ImportComment)
+	}
+
+	for _, mismatch := range result.DirNameMismatches {
+		fmt.Fprintf(errOut, "warning: package %s in %s is in a directory whose name does not match import path %s\n", mismatch.ImportPath, displayPath(mismatch.Dir, param), mismatch.ExpectedImportPath)
+	}
+
+	for _, pkg := range result.StdlibShadows {
+		fmt.Fprintf(errOut, "warning: vendored package %s shadows the standard library\n", outputImportPath(pkg, param.IncludeVendorInImportPath, param.vendorDirName()))
+	}
+
+	for _, mismatch := range result.VersionMismatches {
+		fmt.Fprintf(errOut, "warning: package %s is vendored with different contents in %s\n", mismatch.ImportPath, strings.Join(displayPaths(mismatch.Dirs, param), ", "))
+	}
+
+	for _, shadowed := range result.ShadowedPkgs {
+		fmt.Fprintf(errOut, "warning: package %s is imported from %s but is also vendored in %s\n", shadowed.ImportPath, displayPath(shadowed.Dir, param), strings.Join(displayPaths(shadowed.VendorDirs, param), ", "))
+	}
+
+	for _, pkg := range result.UnbuildablePkgs {
+		fmt.Fprintf(errOut, "warning: vendored package %s cannot be built on any platform\n", outputImportPath(pkg, param.IncludeVendorInImportPath, param.vendorDirName()))
+	}
+
+	for _, pkg := range result.VendoredMainPkgs {
+		fmt.Fprintf(errOut, "warning: vendored package %s is a main package\n", outputImportPath(pkg, param.IncludeVendorInImportPath, param.vendorDirName()))
+	}
+
+	for _, notVendored := range result.NotVendoredImports {
+		for _, pos := range notVendored.Positions {
+			fmt.Fprintf(errOut, "%s: import of %s is not vendored\n", pos, notVendored.ImportPath)
+		}
+	}
+
+	for _, pkg := range result.StdlibImports {
+		fmt.Fprintf(errOut, "stdlib: %s\n", pkg)
+	}
+
+	if param.Stats {
+		for _, vendorDir := range sortedStatsKeys(result.Stats) {
+			stats := result.Stats[vendorDir]
+			fmt.Fprintf(errOut, "stats: %s: %d vendored, %d unused\n", displayPath(vendorDir, param), stats.TotalVendored, stats.TotalUnused)
+		}
+	}
+
+	if param.Summary {
+		fmt.Fprintf(errOut, "%s%d unused vendored package(s) across %d vendor directories\n", SummaryPrefix, numUnused, len(result.UnusedPkgs))
+	}
+	return nil
+}
+
+// ReportTemplateData is the data with which Param.HeaderTemplate and Param.FooterTemplate are executed.
+type ReportTemplateData struct {
+	// Count is the number of unused packages.
+	Count int
+	// ProjectDir is the absolute path of the project directory that was analyzed.
+	ProjectDir string
+	// VendorDirs are the sorted paths of the vendor directories that were analyzed.
+	VendorDirs []string
+}
+
+// parseReportTemplate parses the provided header or footer template. Returns nil if the template is empty.
+func parseReportTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s template", name)
+	}
+	return tmpl, nil
+}
+
+// renderReportTemplates returns the rendered header and footer templates of the provided parameters for the provided
+// result. The templates are only rendered if the format is FormatText: for all other formats, empty strings are
+// returned.
+func renderReportTemplates(result *Result, param Param) (string, string, error) {
+	if param.Format != "" && param.Format != FormatText {
+		return "", "", nil
+	}
+	data := ReportTemplateData{
+		Count:      numUnusedPkgs(result),
+		ProjectDir: result.ProjectDir,
+		VendorDirs: sortedKeys(result.UnusedPkgs),
+	}
+	header, err := renderReportTemplate("header", param.HeaderTemplate, data)
+	if err != nil {
+		return "", "", err
+	}
+	footer, err := renderReportTemplate("footer", param.FooterTemplate, data)
+	if err != nil {
+		return "", "", err
+	}
+	return header, footer, nil
+}
+
+// renderReportTemplate renders the provided header or footer template with the provided data. A newline is appended to
+// the output if it is not empty and does not end in a newline.
+func renderReportTemplate(name, text string, data ReportTemplateData) (string, error) {
+	tmpl, err := parseReportTemplate(name, text)
+	if err != nil || tmpl == nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", errors.Wrapf(err, "failed to render %s template", name)
+	}
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteString("\n")
+	}
+	return buf.String(), nil
+}
+
+// JSONLine is a single line of the output written when the format is FormatJSONL.
+type JSONLine struct {
+	// VendorDir is the path of the vendor directory that contains the unused package.
+	VendorDir string `json:"vendorDir"`
+	// Pkg is the unused package as determined by the output parameters (for example, the import path of the package
+	// without the vendor directory).
+	Pkg string `json:"pkg"`
+	// Project is the absolute path of the project directory that contains the vendor directory. Only set in the output
+	// written by RunMulti.
+	Project string `json:"project,omitempty"`
+	// Count is the number of unused packages in the repository Pkg. Only set if Param.GroupByRepo is true.
+	Count int `json:"count,omitempty"`
+}
+
+// writeIgnoreWarnings writes a warning to the provided writer for each redundant and stale entry of Param.IgnorePkgs in
+// the provided result.
+func writeIgnoreWarnings(result *Result, w io.Writer) {
+	for _, ignorePkg := range result.RedundantIgnores {
+		fmt.Fprintf(w, "warning: ignore for %s is redundant: package is used\n", ignorePkg)
+	}
+	for _, ignorePkg := range result.StaleIgnores {
+		fmt.Fprintf(w, "warning: ignore for %s is stale: package does not exist\n", ignorePkg)
+	}
+}
+
+// writeJSONLines writes a JSON line for each unused package in the provided result to the provided writer, sorted by
+// vendor directory and then by package. If param.GroupByRepo is true, a line is written for each repository that
+// contains unused packages instead. If param.Dedupe is true, only the first line for each package is written.
+func writeJSONLines(result *Result, param Param, w io.Writer) error {
+	var project string
+	if param.jsonlProject {
+		project = result.ProjectDir
+	}
+	var lines []JSONLine
+	for _, vendorDir := range sortedKeys(result.UnusedPkgs) {
+		var vendorDirLines []JSONLine
+		if param.GroupByRepo {
+			repos, counts := unusedRepos(result.UnusedPkgs[vendorDir], param)
+			for _, repo := range repos {
+				vendorDirLines = append(vendorDirLines, JSONLine{
+					VendorDir: displayPath(vendorDir, param),
+					Pkg:       outputPath(result, vendorDir, repo, param),
+					Project:   project,
+					Count:     counts[repo],
+				})
+			}
+		} else {
+			for _, importPath := range result.UnusedPkgs[vendorDir] {
+				vendorDirLines = append(vendorDirLines, JSONLine{
+					VendorDir: displayPath(vendorDir, param),
+					Pkg:       outputPath(result, vendorDir, importPath, param),
+					Project:   project,
+				})
+			}
+		}
+		sort.SliceStable(vendorDirLines, func(i, j int) bool {
+			return vendorDirLines[i].Pkg < vendorDirLines[j].Pkg
+		})
+		lines = append(lines, vendorDirLines...)
+	}
+	if param.Dedupe && !param.IncludeVendorInImportPath && !param.GroupByRepo {
+		lines = dedupeJSONLines(lines)
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, line := range lines {
+		if err := encoder.Encode(line); err != nil {
+			return errors.Wrapf(err, "failed to write output")
+		}
+	}
+	return nil
+}
+
+// dedupeJSONLines returns the provided lines without the lines whose package is the same as that of an earlier line.
+func dedupeJSONLines(in []JSONLine) []JSONLine {
+	var out []JSONLine
+	seen := make(map[string]struct{})
+	for _, line := range in {
+		if _, ok := seen[line.Pkg]; ok {
+			continue
+		}
+		seen[line.Pkg] = struct{}{}
+		out = append(out, line)
+	}
+	return out
+}
+
+func sortedStatsKeys(in map[string]VendorDirStats) []string {
+	var out []string
+	for k := range in {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// writeDOT writes the import graph and unused packages of the provided result to the provided writer as a Graphviz DOT
+// graph. The nodes of the graph are packages and the edges are imports. Used vendored packages are colored green and
+// unused vendored packages are colored red. Nodes are identified by their full import path and labeled with the import
+// path as determined by outputImportPath.
+func writeDOT(result *Result, param Param, w io.Writer) {
+	colors := make(map[string]string)
+	for pkg, imports := range result.Imports {
+		for _, currPkg := range append([]string{pkg}, imports...) {
+			colors[currPkg] = ""
+			if strings.Contains(currPkg, "/"+param.vendorDirName()+"/") {
+				colors[currPkg] = "green"
+			}
+		}
+	}
+	for _, unused := range result.UnusedPkgs {
+		for _, pkg := range unused {
+			colors[pkg] = "red"
+		}
+	}
+
+	var nodes []string
+	for pkg := range colors {
+		nodes = append(nodes, pkg)
+	}
+	sort.Strings(nodes)
+
+	fmt.Fprintln(w, "digraph novendor {")
+	for _, pkg := range nodes {
+		attrs := fmt.Sprintf("label=%q", outputImportPath(pkg, param.IncludeVendorInImportPath, param.vendorDirName()))
+		if color := colors[pkg]; color != "" {
+			attrs += ", color=" + color
+		}
+		fmt.Fprintf(w, "\t%q [%s];\n", pkg, attrs)
+	}
+	for _, pkg := range sortedKeys(result.Imports) {
+		for _, imported := range result.Imports[pkg] {
+			fmt.Fprintf(w, "\t%q -> %q;\n", pkg, imported)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// writeGitHubActions writes a GitHub Actions workflow command that creates an annotation for each unused package in
+// the provided result to the provided writer. The annotations are errors if the unused packages cause the Run functions
+// to return an error and warnings otherwise.
+func writeGitHubActions(result *Result, param Param, w io.Writer) {
+	level := "warning"
+	if checkUnused(numUnusedPkgs(result), param) != nil {
+		level = "error"
+	}
+	for _, vendorDir := range sortedKeys(result.UnusedPkgs) {
+		for _, importPath := range result.UnusedPkgs[vendorDir] {
+			vendoredPath := outputImportPath(importPath, false, param.vendorDirName())
+			properties := ""
+			pkgDir := filepath.Join(vendorDir, filepath.FromSlash(vendoredPath))
+			if relPath, err := filepath.Rel(result.ProjectDir, pkgDir); err == nil && !strings.HasPrefix(relPath, "..") {
+				properties = " file=" + escapeGitHubActionsProperty(filepath.ToSlash(relPath))
+			}
+			fmt.Fprintf(w, "::%s%s::%s\n", level, properties, escapeGitHubActionsData("unused vendored package "+outputImportPath(importPath, param.IncludeVendorInImportPath, param.vendorDirName())))
+		}
+	}
+}
+
+// escapeGitHubActionsData escapes the provided message of a GitHub Actions workflow command.
+func escapeGitHubActionsData(data string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(data)
+}
+
+// escapeGitHubActionsProperty escapes the provided property value of a GitHub Actions workflow command.
+func escapeGitHubActionsProperty(value string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(value)
+}
+
+// dedupeSorted returns the provided sorted slice with adjacent duplicate elements removed.
+func dedupeSorted(in []string) []string {
+	var out []string
+	for i, curr := range in {
+		if i > 0 && curr == in[i-1] {
+			continue
+		}
+		out = append(out, curr)
+	}
+	return out
+}
+
+// groupedByRepo returns one line for each repository that contains unused packages in the provided result, where the
+// repository of a package is determined by normalizing its import path using param.PkgRegexps. Each line is the
+// repository followed by the number of unused packages in it: for example, "github.com/org/library (4 unused
+// subpackages)".
+func groupedByRepo(result *Result, param Param) []string {
+	var out []string
+	for _, vendorDir := range sortedKeys(result.UnusedPkgs) {
+		repos, repoCounts := unusedRepos(result.UnusedPkgs[vendorDir], param)
+		for _, repo := range repos {
+			count := repoCounts[repo]
+			noun := "subpackages"
+			if count == 1 {
+				noun = "subpackage"
+			}
+			out = append(out, fmt.Sprintf("%s (%d unused %s)", outputPath(result, vendorDir, repo, param), count, noun))
+		}
+	}
+	return out
+}
+
+// unusedRepos returns the repositories of the provided unused import paths in the order in which they first occur and
+// the number of unused packages in each repository, where the repository of a package is determined by normalizing its
+// import path using param.PkgRegexps.
+func unusedRepos(importPaths []string, param Param) ([]string, map[string]int) {
+	var repos []string
+	repoCounts := make(map[string]int)
+	for _, importPath := range importPaths {
+		repo := transformImportPath(importPath, param.PkgRegexps, param.vendorDirName())
+		if _, ok := repoCounts[repo]; !ok {
+			repos = append(repos, repo)
+		}
+		repoCounts[repo]++
+	}
+	return repos, repoCounts
+}
+
+// writeByVendorDir writes the unused packages in the provided result to the provided writer grouped by vendor directory.
+// Vendor directories are written in sorted order, the packages in each vendor directory are sorted and an empty record
+// (a blank line, unless param.RecordSeparator is set) is written between the groups. If param.Dedupe is true,
+// duplicates are only removed within a vendor directory.
+func writeByVendorDir(result *Result, param Param, w io.Writer) {
+	wroteGroup := false
+	for _, vendorDir := range sortedKeys(result.UnusedPkgs) {
+		var lines []string
+		for _, importPath := range result.UnusedPkgs[vendorDir] {
+			lines = append(lines, outputLine(result, vendorDir, importPath, param))
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		sort.Strings(lines)
+		if param.Dedupe && !param.IncludeVendorInImportPath {
+			lines = dedupeSorted(lines)
+		}
+
+		if wroteGroup {
+			fmt.Fprint(w, param.recordSeparator())
+		}
+		for _, pkg := range lines {
+			fmt.Fprint(w, pkg, param.recordSeparator())
+		}
+		wroteGroup = true
+	}
+}
+
+// outputLine returns the line that should be printed for the provided unused import path in the provided vendor
+// directory: the path returned by outputPath followed by the names declared by the package if param.ShowName is true.
+func outputLine(result *Result, vendorDir, importPath string, param Param) string {
+	line := outputPath(result, vendorDir, importPath, param)
+	if names := result.PkgNames[importPath]; param.ShowName && len(names) > 0 {
+		line += fmt.Sprintf(" (package %s)", strings.Join(names, ", "))
+	}
+	return line
+}
+
+// outputPath returns the path that should be printed for the provided unused import path in the provided vendor
+// directory. If param.AbsPaths is true, the returned path is the absolute path of the directory of the package. If
+// param.IncludeVendorInImportPath and param.RelativePaths are both true, the returned path is the directory of the
+// package relative to the project directory. Otherwise, the import path is returned as determined by outputImportPath.
+func outputPath(result *Result, vendorDir, importPath string, param Param) string {
+	pkgDir, ok := result.UnusedPkgDirs[importPath]
+	if !ok {
+		pkgDir = filepath.Join(vendorDir, filepath.FromSlash(outputImportPath(importPath, false, param.vendorDirName())))
+	}
+	if param.AbsPaths {
+		return displayPath(pkgDir, param)
+	}
+	if !param.IncludeVendorInImportPath || !param.RelativePaths {
+		return outputImportPath(importPath, param.IncludeVendorInImportPath, param.vendorDirName())
+	}
+	relPath, err := filepath.Rel(result.ProjectDir, pkgDir)
+	if err != nil {
+		return pkgDir
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// displayPath returns the provided absolute path as it should be written in the output. If param.displayDir is set and
+// the path is within it, the slash-separated path relative to param.displayDir is returned. Otherwise, the path is
+// returned unmodified.
+func displayPath(path string, param Param) string {
+	if param.displayDir == "" {
+		return path
+	}
+	relPath, err := filepath.Rel(param.displayDir, path)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// displayPaths returns the result of calling displayPath on each of the provided paths.
+func displayPaths(paths []string, param Param) []string {
+	out := make([]string, len(paths))
+	for i, path := range paths {
+		out[i] = displayPath(path, param)
+	}
+	return out
+}
+
+// outputImportPath returns the import path that should be printed for the provided import path. If includeVendor is
+// false, the portion of the path up to and including the last vendor directory (as determined by splitVendorPrefix) is
+// removed. Any backslashes in the import path are converted to forward slashes.
+func outputImportPath(importPath string, includeVendor bool, vendorDirName string) string {
+	importPath = toSlashImportPath(importPath)
+	if includeVendor {
+		return importPath
+	}
+	_, importPath = splitVendorPrefix(importPath, vendorDirName)
+	return importPath
+}
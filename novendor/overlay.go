@@ -0,0 +1,116 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"bytes"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// normalizedOverlay returns a copy of the provided overlay whose keys are cleaned absolute paths. Relative paths are
+// resolved against the working directory. Returns nil if the provided overlay is empty.
+func normalizedOverlay(overlay map[string][]byte) map[string][]byte {
+	if len(overlay) == 0 {
+		return nil
+	}
+	out := make(map[string][]byte, len(overlay))
+	for filename, content := range overlay {
+		if absFilename, err := filepath.Abs(filename); err == nil {
+			filename = absFilename
+		}
+		out[filepath.Clean(filename)] = content
+	}
+	return out
+}
+
+// setOverlay configures the provided build context to use the contents of the files in the provided overlay (whose keys
+// are cleaned absolute paths) instead of the contents of the files on disk. Files in the overlay that do not exist on
+// disk are added to the listings of their directories.
+func setOverlay(ctx *build.Context, overlay map[string][]byte) {
+	if len(overlay) == 0 {
+		return
+	}
+	ctx.OpenFile = func(path string) (io.ReadCloser, error) {
+		if content, ok := overlayContent(overlay, path); ok {
+			return ioutil.NopCloser(bytes.NewReader(content)), nil
+		}
+		return os.Open(path)
+	}
+	ctx.ReadDir = func(dir string) ([]os.FileInfo, error) {
+		fis, err := ioutil.ReadDir(dir)
+		overlayFiles := make(map[string]os.FileInfo)
+		for filename, content := range overlay {
+			if filepath.Dir(filename) == filepath.Clean(dir) {
+				overlayFiles[filepath.Base(filename)] = overlayFileInfo{
+					name: filepath.Base(filename),
+					size: int64(len(content)),
+				}
+			}
+		}
+		if len(overlayFiles) == 0 {
+			return fis, err
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		var out []os.FileInfo
+		for _, fi := range fis {
+			if overlayFI, ok := overlayFiles[fi.Name()]; ok && !fi.IsDir() {
+				fi = overlayFI
+				delete(overlayFiles, fi.Name())
+			}
+			out = append(out, fi)
+		}
+		for _, fi := range overlayFiles {
+			out = append(out, fi)
+		}
+		sort.Slice(out, func(i, j int) bool {
+			return out[i].Name() < out[j].Name()
+		})
+		return out, nil
+	}
+}
+
+// overlayContent returns the content of the provided file in the provided overlay and true if the overlay contains the
+// file. Returns false if the overlay does not contain the file.
+func overlayContent(overlay map[string][]byte, filename string) ([]byte, bool) {
+	if len(overlay) == 0 {
+		return nil, false
+	}
+	if absFilename, err := filepath.Abs(filename); err == nil {
+		filename = absFilename
+	}
+	content, ok := overlay[filepath.Clean(filename)]
+	return content, ok
+}
+
+// overlayFileInfo is the os.FileInfo for a file in an overlay.
+type overlayFileInfo struct {
+	name string
+	size int64
+}
+
+func (fi overlayFileInfo) Name() string       { return fi.name }
+func (fi overlayFileInfo) Size() int64        { return fi.size }
+func (fi overlayFileInfo) Mode() os.FileMode  { return 0644 }
+func (fi overlayFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi overlayFileInfo) IsDir() bool        { return false }
+func (fi overlayFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,169 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Prune removes the unused vendored packages of the provided packages (as determined by Analyze using the provided
+// parameters) from their vendor directories and returns the sorted absolute paths of the directories of the removed
+// packages. Packages that are not reported as unused (for example, because of param.AllowUnused or param.IgnorePkgs)
+// are never removed. The directory of an unused package is removed along with its contents unless one of its
+// subdirectories contains a package that is not removed, in which case only the Go files of the package are removed.
+// Parent directories within the vendor directory that are empty once the packages are removed are also removed. If
+// dryRun is true, nothing is removed and the directories of the packages that would be removed are returned.
+func Prune(projectDir string, pkgs []string, param Param, dryRun bool) ([]string, error) {
+	analysis, err := unusedVendoredPackages(context.Background(), projectDir, pkgs, param)
+	if err != nil {
+		return nil, err
+	}
+
+	var prunedDirs []string
+	for _, vendorDir := range sortedVendorDirs(analysis.unused) {
+		unused := combineMaps(nil, analysis.unused[vendorDir])
+		filterUnused(unused, param)
+
+		// packages that remain in the vendor directory: the packages that are used and the unused packages that are
+		// not reported
+		var retained []string
+		for pkg := range analysis.vendored[vendorDir] {
+			if _, ok := analysis.unused[vendorDir][pkg]; !ok || isAllowedUnused(pkg, param) || hasIgnoredPrefix(pkg, param) {
+				retained = append(retained, pkg)
+			}
+		}
+
+		for _, pkg := range sortedVals(unused) {
+			pkgDir := filepath.Join(vendorDir, filepath.FromSlash(outputImportPath(pkg, false, param.vendorDirName())))
+			if param.Logger != nil {
+				param.Logger.Printf("removing unused package %s in %s", pkg, pkgDir)
+			}
+			prunedDirs = append(prunedDirs, pkgDir)
+			if dryRun {
+				continue
+			}
+			if err := removePkg(pkgDir, hasSubpackage(pkg, retained)); err != nil {
+				return nil, err
+			}
+			if err := removeEmptyParents(pkgDir, vendorDir); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(prunedDirs)
+	return prunedDirs, nil
+}
+
+// RunPrune removes the unused vendored packages of the provided packages using Prune and writes a line for each
+// removed package to the provided writer. Paths are written relative to the project directory. If dryRun is true,
+// nothing is removed and the lines state the packages that would be removed.
+func RunPrune(projectDir string, pkgs []string, param Param, dryRun bool, w io.Writer) error {
+	prunedDirs, err := Prune(projectDir, pkgs, param, dryRun)
+	if err != nil {
+		return err
+	}
+	absProjectDir, err := filepath.Abs(projectDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to determine absolute path of project directory %s", projectDir)
+	}
+	action := "removed"
+	if dryRun {
+		action = "would remove"
+	}
+	for _, dir := range prunedDirs {
+		if relPath, err := filepath.Rel(absProjectDir, dir); err == nil && !strings.HasPrefix(relPath, "..") {
+			dir = filepath.ToSlash(relPath)
+		}
+		fmt.Fprintf(w, "%s %s\n", action, dir)
+	}
+	return nil
+}
+
+// sortedVendorDirs returns the sorted vendor directories of the provided map.
+func sortedVendorDirs(in map[string]map[string]struct{}) []string {
+	var out []string
+	for k := range in {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// hasSubpackage returns true if any of the provided import paths is a subpackage of the provided import path.
+func hasSubpackage(importPath string, importPaths []string) bool {
+	for _, curr := range importPaths {
+		if strings.HasPrefix(curr, importPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// removePkg removes the package in the provided directory. If goFilesOnly is true, only the Go files directly within the
+// directory are removed. Otherwise, the directory and all of its contents are removed.
+func removePkg(pkgDir string, goFilesOnly bool) error {
+	if !goFilesOnly {
+		if err := os.RemoveAll(pkgDir); err != nil {
+			return errors.Wrapf(err, "failed to remove directory %s", pkgDir)
+		}
+		return nil
+	}
+	files, err := ioutil.ReadDir(pkgDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read directory %s", pkgDir)
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".go") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(pkgDir, file.Name())); err != nil {
+			return errors.Wrapf(err, "failed to remove file %s", filepath.Join(pkgDir, file.Name()))
+		}
+	}
+	return nil
+}
+
+// removeEmptyParents removes the provided directory (if it exists and is empty) and each of its ancestors within the
+// provided vendor directory that are empty once their children are removed. The vendor directory itself is never
+// removed.
+func removeEmptyParents(dir, vendorDir string) error {
+	for dir != vendorDir && strings.HasPrefix(dir, vendorDir+string(filepath.Separator)) {
+		files, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			dir = filepath.Dir(dir)
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read directory %s", dir)
+		}
+		if len(files) > 0 {
+			return nil
+		}
+		if err := os.Remove(dir); err != nil {
+			return errors.Wrapf(err, "failed to remove directory %s", dir)
+		}
+		dir = filepath.Dir(dir)
+	}
+	return nil
+}
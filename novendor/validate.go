@@ -0,0 +1,142 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Validate returns an error if the configuration is not internally consistent. The following are reported:
+//
+//   - grouping expressions (PkgRegexps, AdditionalPkgRegexps and VendorHosts) that do not compile, that are specified
+//     more than once or that match the empty string (and would therefore group every package into the same group)
+//   - GroupByRepo without any grouping expressions
+//   - RelativePaths without IncludeVendorInImportPath (relative paths are only printed for full import paths)
+//   - relative paths in IgnorePkgs and PerPkgContext that refer to a location outside of the project directory
+//   - an unknown Format or ModMode or a platform that is not of the form GOOS/GOARCH
+//   - a HeaderTemplate or FooterTemplate that does not parse
+//   - a negative MaxDepth, MaxOpenFiles or MaxUnused
+//
+// All of the problems are reported in a single error.
+func (c Config) Validate() error {
+	var problems []string
+
+	var pkgRegexps []string
+	pkgRegexps = append(pkgRegexps, c.PkgRegexps...)
+	pkgRegexps = append(pkgRegexps, c.AdditionalPkgRegexps...)
+	for _, host := range c.VendorHosts {
+		pkgRegexps = append(pkgRegexps, vendorHostRegexp(host))
+	}
+	seenRegexps := make(map[string]struct{})
+	for _, expr := range pkgRegexps {
+		normalizedExpr := expr
+		if !strings.HasPrefix(normalizedExpr, "^") {
+			normalizedExpr = "^" + normalizedExpr
+		}
+		reg, err := regexp.Compile(normalizedExpr)
+		if err != nil {
+			problems = append(problems, errors.Wrapf(err, "package regular expression %q does not compile", expr).Error())
+			continue
+		}
+		if _, ok := seenRegexps[normalizedExpr]; ok {
+			problems = append(problems, errors.Errorf("package regular expression %q is specified more than once", expr).Error())
+		}
+		seenRegexps[normalizedExpr] = struct{}{}
+		if reg.MatchString("") {
+			problems = append(problems, errors.Errorf("package regular expression %q matches the empty string", expr).Error())
+		}
+	}
+	if c.GroupByRepo && len(pkgRegexps) == 0 {
+		problems = append(problems, "grouping by repository requires package regular expressions or vendor hosts")
+	}
+	if c.RelativePaths && !c.IncludeVendorInImportPath {
+		problems = append(problems, "relative paths are only printed when the full import path is included")
+	}
+
+	for _, pkg := range c.IgnorePkgs {
+		if isOutsideProject(pkg) {
+			problems = append(problems, errors.Errorf("ignored package %q is outside of the project directory", pkg).Error())
+		}
+	}
+	var perPkgContextPkgs []string
+	for pkg := range c.PerPkgContext {
+		perPkgContextPkgs = append(perPkgContextPkgs, pkg)
+	}
+	sort.Strings(perPkgContextPkgs)
+	for _, pkg := range perPkgContextPkgs {
+		if isOutsideProject(pkg) {
+			problems = append(problems, errors.Errorf("package %q with a build context override is outside of the project directory", pkg).Error())
+		}
+	}
+
+	switch c.Format {
+	case "", FormatText, FormatDOT, FormatJSONL, FormatGitHubActions:
+	default:
+		problems = append(problems, errors.Errorf("unknown format %q", c.Format).Error())
+	}
+	switch c.ModMode {
+	case "", ModVendor, ModReadonly, ModMod:
+	default:
+		problems = append(problems, errors.Errorf("unknown module mode %q", c.ModMode).Error())
+	}
+	if _, err := parseReportTemplate("header", c.HeaderTemplate); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := parseReportTemplate("footer", c.FooterTemplate); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if c.GoVersion != "" {
+		if _, err := goReleaseTags(c.GoVersion); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, platform := range c.Platforms {
+		parts := strings.Split(platform, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			problems = append(problems, errors.Errorf("platform must be of the form GOOS/GOARCH, was %q", platform).Error())
+		}
+	}
+
+	if c.MaxDepth < 0 {
+		problems = append(problems, errors.Errorf("maximum depth must be non-negative, was %d", c.MaxDepth).Error())
+	}
+	if c.MaxOpenFiles < 0 {
+		problems = append(problems, errors.Errorf("maximum number of open files must be non-negative, was %d", c.MaxOpenFiles).Error())
+	}
+	if c.MaxUnused != nil && *c.MaxUnused < 0 {
+		problems = append(problems, errors.Errorf("maximum number of unused packages must be non-negative, was %d", *c.MaxUnused).Error())
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+}
+
+// isOutsideProject returns true if the provided package path is a relative path that refers to a location outside of
+// the directory against which it is resolved.
+func isOutsideProject(pkgPath string) bool {
+	if filepath.IsAbs(pkgPath) {
+		return false
+	}
+	cleaned := path.Clean(filepath.ToSlash(pkgPath))
+	return cleaned == ".." || strings.HasPrefix(cleaned, "../")
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package novendor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Why returns the shortest import chain from one of the provided packages to the vendored package with the provided
+// import path. The first element of the returned chain is the import path of a project package and the last element is
+// the import path (including the vendor directory) of the vendored package. The vendored import path is matched in the
+// same manner as IsVendoredPackageUsed. If there are multiple shortest chains, the one that is first when the packages
+// are considered in sorted order is returned. Returns nil if the package is not used by the provided packages and an
+// error if the import path does not match any vendored package. The chain is analogous to the output of "go mod why".
+func Why(projectDir string, pkgs []string, vendoredImportPath string, param Param) ([]string, error) {
+	// grouping only affects output, but it also disables normalization during the analysis
+	param.GroupByRepo = false
+	param.collectImports = true
+	analysis, err := unusedVendoredPackages(context.Background(), projectDir, pkgs, param)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]struct{})
+	for _, vendored := range analysis.vendored {
+		for pkg := range vendored {
+			if matchesVendoredImportPath(pkg, vendoredImportPath, param) {
+				targets[pkg] = struct{}{}
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errors.Errorf("%s is not a vendored package", vendoredImportPath)
+	}
+
+	// breadth-first search from the project packages: parents records the package through which each package was
+	// first reached
+	parents := make(map[string]string)
+	queue := append([]string{}, analysis.projectPkgs...)
+	for _, pkg := range queue {
+		parents[pkg] = ""
+	}
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+		if matchesVendoredImportPath(curr, vendoredImportPath, param) && isVendoredTarget(curr, targets, param) {
+			var chain []string
+			for pkg := curr; pkg != ""; pkg = parents[pkg] {
+				chain = append([]string{pkg}, chain...)
+			}
+			return chain, nil
+		}
+		var imports []string
+		for imported := range analysis.imports[curr] {
+			imports = append(imports, imported)
+		}
+		sort.Strings(imports)
+		for _, imported := range imports {
+			if _, ok := parents[imported]; ok {
+				continue
+			}
+			parents[imported] = curr
+			queue = append(queue, imported)
+		}
+	}
+	return nil, nil
+}
+
+// RunWhy determines the shortest import chain from one of the provided packages to the vendored package with the
+// provided import path using Why and writes it to the provided writer. The output starts with a line consisting of "# "
+// followed by the provided import path. It is followed by one line for each package in the chain or, if the package is
+// not used, a line stating that it is not used.
+func RunWhy(projectDir string, pkgs []string, vendoredImportPath string, param Param, w io.Writer) error {
+	chain, err := Why(projectDir, pkgs, vendoredImportPath, param)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s%s\n", SummaryPrefix, vendoredImportPath)
+	if chain == nil {
+		fmt.Fprintf(w, "(%s is not used by the project packages)\n", vendoredImportPath)
+		return nil
+	}
+	for _, pkg := range chain {
+		fmt.Fprintln(w, outputImportPath(pkg, param.IncludeVendorInImportPath, param.vendorDirName()))
+	}
+	return nil
+}
+
+// matchesVendoredImportPath returns true if the provided import path (including the vendor directory) matches the
+// provided vendored import path, which may or may not include the vendor directory and may be normalized using
+// param.PkgRegexps.
+func matchesVendoredImportPath(importPath, vendoredImportPath string, param Param) bool {
+	normalizedPath := transformImportPath(vendoredImportPath, param.PkgRegexps, param.vendorDirName())
+	normalizedImportPath := transformImportPath(importPath, param.PkgRegexps, param.vendorDirName())
+	for _, candidate := range []string{importPath, normalizedImportPath} {
+		if candidate == normalizedPath || outputImportPath(candidate, false, param.vendorDirName()) == normalizedPath {
+			return true
+		}
+	}
+	return false
+}
+
+// isVendoredTarget returns true if the provided import path (including the vendor directory) is in the provided set of
+// vendored targets or, if param.PkgRegexps is used to group packages, is in the group of one of the targets.
+func isVendoredTarget(importPath string, targets map[string]struct{}, param Param) bool {
+	if _, ok := targets[importPath]; ok {
+		return true
+	}
+	_, ok := targets[transformImportPath(importPath, param.PkgRegexps, param.vendorDirName())]
+	return ok
+}